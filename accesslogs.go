@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type ingestingLogsKey struct{}
+
+// ingestingLogs tells whether secure runs for ingest-logs, the images to secure being
+// the ones the access logs show served without a signature.
+func ingestingLogs(ctx context.Context) bool {
+	v, _ := ctx.Value(ingestingLogsKey{}).(bool)
+	return v
+}
+
+// ingestLogsCmd secures the images the access logs show served without a signed URL, the
+// images actually hit publicly, taking the flags of secure along with the access logs.
+func ingestLogsCmd(ctx context.Context, args []string) error {
+	return secureCmd(context.WithValue(ctx, ingestingLogsKey{}, true), args)
+}
+
+// accessLogsConfig holds the flags of the access logs read by ingest-logs.
+type accessLogsConfig struct {
+	files  string
+	format string
+}
+
+func (c *accessLogsConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.files, "access-logs", "", "comma separated access log files to read, gzipped or not, or - for stdin")
+	fs.StringVar(&c.format, "access-log-format", "auto", "format of the access logs, logpush for the json lines of the http requests of cloudflare logpush, combined for the combined log format of the cdns and web servers, or auto to tell them apart line by line")
+}
+
+func (c *accessLogsConfig) validate() error {
+	if c.files == "" {
+		return errors.New("-access-logs is required")
+	}
+
+	switch c.format {
+	case "auto", "logpush", "combined":
+		return nil
+	default:
+		return fmt.Errorf("invalid -access-log-format '%s', expected auto, logpush or combined", c.format)
+	}
+}
+
+// read returns the ids of the images the access logs show served without a signature, once
+// each, leaving out the delivery URLs of another account hash than accountHash, if given.
+func (c *accessLogsConfig) read(accountHash string) ([]string, error) {
+	var (
+		ids      []string
+		seen     = map[string]bool{}
+		requests int
+	)
+
+	for _, name := range splitList(c.files) {
+		err := c.readFile(name, func(u *url.URL) {
+			requests++
+			if u.Query().Has("sig") {
+				return
+			}
+
+			hash, id, err := parseDeliveryURL(u.String())
+			if err != nil || (accountHash != "" && hash != accountHash) || seen[id] {
+				return
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read access log %s: %s", name, err)
+		}
+	}
+
+	slog.Info("access logs read", "requests_served", requests, "unsigned_images", len(ids))
+	return ids, nil
+}
+
+// readFile calls fn with the URL of every request served in the access log.
+func (c *accessLogsConfig) readFile(name string, fn func(u *url.URL)) error {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		format := c.format
+		if format == "auto" {
+			format = "combined"
+			if strings.HasPrefix(line, "{") {
+				format = "logpush"
+			}
+		}
+
+		parse := parseCombinedLine
+		if format == "logpush" {
+			parse = parseLogpushLine
+		}
+
+		u, served, err := parse(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", n, err)
+		}
+
+		if u != nil && served {
+			fn(u)
+		}
+	}
+	return scanner.Err()
+}
+
+// logpushRequest is the part of a line of the http requests dataset of Logpush used here.
+type logpushRequest struct {
+	ClientRequestHost  string `json:"ClientRequestHost"`
+	ClientRequestURI   string `json:"ClientRequestURI"`
+	EdgeResponseStatus int    `json:"EdgeResponseStatus"`
+}
+
+// parseLogpushLine returns the URL of the request of a line of Logpush and whether it was
+// served, the lines without the status of the response being taken as served.
+func parseLogpushLine(line string) (*url.URL, bool, error) {
+	var req logpushRequest
+	if err := json.Unmarshal([]byte(line), &req); err != nil {
+		return nil, false, fmt.Errorf("invalid logpush line: %s", err)
+	}
+
+	if req.ClientRequestHost == "" || req.ClientRequestURI == "" {
+		return nil, false, nil
+	}
+
+	u, err := url.Parse("https://" + req.ClientRequestHost + req.ClientRequestURI)
+	if err != nil {
+		return nil, false, nil
+	}
+	return u, req.EdgeResponseStatus < 400, nil
+}
+
+// parseCombinedLine returns the URL of the request of a line in the common or the combined
+// log format, and whether it was served. As the lines don't tell the host, the requests are
+// for the images when their target is a delivery URL or under /cdn-cgi/imagedelivery.
+// The lines which aren't of a request are skipped, the logs mixing them with others.
+func parseCombinedLine(line string) (*url.URL, bool, error) {
+	// "GET /cdn-cgi/imagedelivery/<hash>/<id>/public HTTP/1.1" 200 1234
+	_, rest, ok := strings.Cut(line, `"`)
+	if !ok {
+		return nil, false, nil
+	}
+
+	request, rest, ok := strings.Cut(rest, `"`)
+	if !ok {
+		return nil, false, nil
+	}
+
+	parts := strings.Fields(request)
+	if len(parts) < 2 {
+		return nil, false, nil
+	}
+
+	// The host is a placeholder, the targets relative to the zone being under /cdn-cgi/imagedelivery.
+	target := parts[1]
+	if !isURL(target) {
+		target = "https://zone.invalid" + target
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	// The common log format leaves the status out when unknown.
+	if fields := strings.Fields(rest); len(fields) > 0 {
+		if status, err := strconv.Atoi(fields[0]); err == nil {
+			return u, status < 400, nil
+		}
+	}
+	return u, true, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// adaptiveDecreaseInterval is the minimum time between two decreases of the concurrency,
+// so that a burst of rejections from the same overload halves it only once.
+const adaptiveDecreaseInterval = time.Second
+
+// adaptiveLimiter adapts the number of changes applied concurrently, AIMD style: it grows
+// by one every limit successful responses, and halves on a 429 or 5xx response, between 1
+// and the configured concurrency. Its methods are no-ops on a nil adaptiveLimiter.
+type adaptiveLimiter struct {
+	max int
+
+	mu           sync.Mutex
+	cond         *sync.Cond
+	limit        float64
+	inFlight     int
+	lastDecrease time.Time
+}
+
+func newAdaptiveLimiter(max int) *adaptiveLimiter {
+	l := adaptiveLimiter{max: max, limit: 1}
+	l.cond = sync.NewCond(&l.mu)
+	return &l
+}
+
+// acquire waits for the concurrency to allow one more change in flight.
+func (l *adaptiveLimiter) acquire() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for l.inFlight >= int(l.limit) {
+		l.cond.Wait()
+	}
+	l.inFlight++
+}
+
+func (l *adaptiveLimiter) release() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	l.cond.Broadcast()
+}
+
+// transport wraps an http transport to adapt the concurrency to the responses of the API.
+func (l *adaptiveLimiter) transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		l.feedback(resp.StatusCode)
+		return resp, nil
+	})
+}
+
+func (l *adaptiveLimiter) feedback(statusCode int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	prev := int(l.limit)
+	switch {
+	case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		if time.Since(l.lastDecrease) < adaptiveDecreaseInterval {
+			return
+		}
+		l.limit = max(l.limit/2, 1)
+		l.lastDecrease = time.Now()
+	case statusCode < 400:
+		l.limit = min(l.limit+1/l.limit, float64(l.max))
+	}
+
+	if int(l.limit) != prev {
+		slog.Debug("concurrency adapted", "concurrency", int(l.limit), "status_code", statusCode)
+		l.cond.Broadcast()
+	}
+}
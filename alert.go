@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const (
+	pagerDutyRoutingKeyEnv = "SECURECLOUDFLAREIMG_PAGERDUTY_ROUTING_KEY"
+	opsgenieAPIKeyEnv      = "SECURECLOUDFLAREIMG_OPSGENIE_API_KEY"
+)
+
+var (
+	pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	opsgenieAlertsURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// alerter raises an on-call alert while images remain unprotected after a run,
+// and resolves it once a run leaves none. The alert is deduplicated per account,
+// so repeated runs update the same alert rather than opening new ones.
+type alerter interface {
+	trigger(ctx context.Context, sum *runSummary) error
+	resolve(ctx context.Context, sum *runSummary) error
+}
+
+func newAlerter(kind string) (alerter, error) {
+	switch kind {
+	case "pagerduty":
+		key := os.Getenv(pagerDutyRoutingKeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("%s is required to alert with pagerduty", pagerDutyRoutingKeyEnv)
+		}
+		return &pagerDuty{routingKey: key}, nil
+	case "opsgenie":
+		key := os.Getenv(opsgenieAPIKeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("%s is required to alert with opsgenie", opsgenieAPIKeyEnv)
+		}
+		return &opsgenie{apiKey: key}, nil
+	default:
+		return nil, fmt.Errorf("invalid -alert '%s', expected pagerduty or opsgenie", kind)
+	}
+}
+
+// alert triggers or resolves the alert depending on what the run left unprotected.
+// Interrupted runs don't resolve, since they didn't check what remains.
+func alert(ctx context.Context, a alerter, sum *runSummary) error {
+	if sum.RemainingUnprotected > 0 {
+		return a.trigger(ctx, sum)
+	}
+
+	if sum.Interrupted {
+		return nil
+	}
+	return a.resolve(ctx, sum)
+}
+
+func alertKey(sum *runSummary) string {
+	return "securecloudflareimg-unprotected-" + sum.AccountID
+}
+
+func alertMessage(sum *runSummary) string {
+	return fmt.Sprintf("%d Cloudflare images left unprotected on account %s", sum.RemainingUnprotected, sum.AccountID)
+}
+
+// pagerDuty sends events to the PagerDuty Events API v2.
+type pagerDuty struct {
+	routingKey string
+}
+
+func (p *pagerDuty) trigger(ctx context.Context, sum *runSummary) error {
+	return p.send(ctx, map[string]any{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    alertKey(sum),
+		"payload": map[string]any{
+			"summary":        alertMessage(sum),
+			"source":         "securecloudflareimg",
+			"severity":       "error",
+			"custom_details": sum,
+		},
+	})
+}
+
+func (p *pagerDuty) resolve(ctx context.Context, sum *runSummary) error {
+	return p.send(ctx, map[string]any{
+		"routing_key":  p.routingKey,
+		"event_action": "resolve",
+		"dedup_key":    alertKey(sum),
+	})
+}
+
+func (p *pagerDuty) send(ctx context.Context, event map[string]any) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not encode pagerduty event: %s", err)
+	}
+	return postJSON(ctx, pagerDutyEventsURL, body, nil)
+}
+
+// opsgenie creates and closes alerts with the Opsgenie Alert API.
+type opsgenie struct {
+	apiKey string
+}
+
+func (o *opsgenie) trigger(ctx context.Context, sum *runSummary) error {
+	details, err := json.Marshal(sum)
+	if err != nil {
+		return fmt.Errorf("could not encode opsgenie alert: %s", err)
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"message":     alertMessage(sum),
+		"alias":       alertKey(sum),
+		"description": string(details),
+		"source":      "securecloudflareimg",
+		"priority":    "P2",
+	})
+	if err != nil {
+		return fmt.Errorf("could not encode opsgenie alert: %s", err)
+	}
+	return postJSON(ctx, opsgenieAlertsURL, body, o.header())
+}
+
+func (o *opsgenie) resolve(ctx context.Context, sum *runSummary) error {
+	u := fmt.Sprintf("%s/%s/close?identifierType=alias", opsgenieAlertsURL, url.PathEscape(alertKey(sum)))
+	return postJSON(ctx, u, []byte(`{"source":"securecloudflareimg"}`), o.header())
+}
+
+func (o *opsgenie) header() http.Header {
+	return http.Header{"Authorization": []string{"GenieKey " + o.apiKey}}
+}
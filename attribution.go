@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// attributeUploads sets who uploaded each offending image of the report, from the earliest entry
+// of the audit log of the account about the image, going through the audit log from the upload of
+// the oldest offending image. The images without an entry, the audit log being kept for a limited
+// time, are left unattributed.
+func attributeUploads(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI, r *securityReport) error {
+	r.Attributed = true
+	if len(r.Offending) == 0 {
+		return nil
+	}
+
+	pending := make(map[string]int, len(r.Offending))
+	since := time.Now()
+	for i, img := range r.Offending {
+		pending[img.ID] = i
+		if img.Uploaded.Before(since) {
+			since = img.Uploaded
+		}
+	}
+
+	// Leave some room for the clocks of the audit log and of the images not agreeing.
+	for l, err := range cli.AuditLogs(ctx, since.Add(-time.Minute)) {
+		if err != nil {
+			return fmt.Errorf("failed to read the audit log: %s", err)
+		}
+
+		i, ok := pending[l.Resource.ID]
+		if !ok {
+			continue
+		}
+
+		r.Offending[i].UploadedBy = uploadedBy(l)
+		delete(pending, l.Resource.ID)
+		if len(pending) == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// uploadedBy describes the actor of the audit log entry: the email of the user or the id
+// of the API token, where from and through which interface.
+func uploadedBy(l cloudflareclient.AuditLog) string {
+	who := l.Actor.Email
+	if who == "" {
+		who = strings.TrimSpace(l.Actor.Type + " " + l.Actor.ID)
+	}
+
+	if l.Actor.IP != "" {
+		who += " from " + l.Actor.IP
+	}
+
+	if l.Interface != "" {
+		who += " via " + l.Interface
+	}
+	return who
+}
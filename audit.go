@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+// auditLog appends a JSON line to a file for every change attempted. Each line carries
+// the hash of the previous one, chaining them so that editing or removing a line is evident.
+// Its methods are no-ops on a nil auditLog.
+type auditLog struct {
+	actor string
+
+	mu       sync.Mutex
+	f        *os.File
+	prevHash string
+}
+
+// auditRecord is a line of the audit log.
+type auditRecord struct {
+	Time    time.Time `json:"time"`
+	ImageID string    `json:"image_id"`
+	// Action is delete for the images deleted, empty for the updates of requireSignedURLs.
+	Action string `json:"action,omitempty"`
+	// Old and New are the values of requireSignedURLs before and after the change,
+	// both true for the images deleted, secured during their quarantine.
+	Old    bool   `json:"old_require_signed_urls"`
+	New    bool   `json:"new_require_signed_urls"`
+	Actor  string `json:"actor"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// PrevHash is the hash of the previous line, and Hash the sha256 of this line
+	// encoded without it.
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// openAuditLog opens the audit log for appending, continuing the chain of hashes of its last line.
+func openAuditLog(name, actor string) (*auditLog, error) {
+	prevHash, err := lastAuditHash(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %s", err)
+	}
+	return &auditLog{actor: actor, f: f, prevHash: prevHash}, nil
+}
+
+// lastAuditHash returns the hash of the last line of the audit log, empty if there is none yet.
+func lastAuditHash(name string) (string, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read audit log: %s", err)
+	}
+	defer f.Close()
+
+	var last []byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read audit log: %s", err)
+	}
+
+	if last == nil {
+		return "", nil
+	}
+
+	var rec auditRecord
+	if err := json.Unmarshal(last, &rec); err != nil || rec.Hash == "" {
+		return "", fmt.Errorf("the last line of audit log %s is not an audit record", name)
+	}
+	return rec.Hash, nil
+}
+
+// defaultAuditActor identifies who runs the tool, as user@host.
+func defaultAuditActor() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// record is an applier observer appending the outcome of the change.
+// A change that can't be recorded is logged rather than stopping the run.
+func (l *auditLog) record(c change, err error) {
+	// Nothing changed on the images secured already.
+	if l == nil || c.alreadySecured {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rec := auditRecord{
+		Time:     time.Now().UTC(),
+		ImageID:  c.ImageID,
+		Old:      !c.RequireSignedURLs,
+		New:      c.RequireSignedURLs,
+		Actor:    l.actor,
+		Result:   "ok",
+		PrevHash: l.prevHash,
+	}
+	if c.delete {
+		rec.Action, rec.Old, rec.New = "delete", true, true
+	}
+	if err != nil {
+		rec.Result, rec.Error = "failed", err.Error()
+	}
+
+	hash, err := rec.hash()
+	if err != nil {
+		slog.Error("failed to encode audit record", "image_id", c.ImageID, "error", err)
+		return
+	}
+	rec.Hash = hash
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		slog.Error("failed to encode audit record", "image_id", c.ImageID, "error", err)
+		return
+	}
+
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		slog.Error("failed to write audit record", "image_id", c.ImageID, "error", err)
+		return
+	}
+	l.prevHash = rec.Hash
+}
+
+// hash returns the sha256 of the record encoded without its hash.
+func (rec auditRecord) hash() (string, error) {
+	rec.Hash = ""
+	unhashed, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(unhashed)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// errAuditTampered is the error of an audit log whose chain of hashes is broken.
+var errAuditTampered = errors.New("audit log tampered with")
+
+// verifyAuditLog recomputes the chain of hashes of the audit log, returning the number of
+// records and the hash of the last one. A line edited breaks its own hash, and a line removed
+// or moved breaks the chain at the line after it. Removing the last lines leaves the chain
+// intact, the hash of the last record is to be compared with one kept elsewhere to tell.
+func verifyAuditLog(r io.Reader) (records int, lastHash string, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec auditRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return records, lastHash, fmt.Errorf("%w: line %d is not an audit record: %s", errAuditTampered, n, err)
+		}
+
+		if rec.PrevHash != lastHash {
+			return records, lastHash, fmt.Errorf("%w: line %d doesn't follow the previous record, a line was removed or moved before it", errAuditTampered, n)
+		}
+
+		hash, err := rec.hash()
+		if err != nil {
+			return records, lastHash, fmt.Errorf("failed to encode audit record of line %d: %s", n, err)
+		}
+
+		// The line is checked as written, for a field added or reformatted to be caught as well.
+		canonical, err := json.Marshal(rec)
+		if err != nil {
+			return records, lastHash, fmt.Errorf("failed to encode audit record of line %d: %s", n, err)
+		}
+
+		if hash != rec.Hash || !bytes.Equal(canonical, line) {
+			return records, lastHash, fmt.Errorf("%w: line %d doesn't match its hash, it was edited", errAuditTampered, n)
+		}
+
+		records++
+		lastHash = rec.Hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return records, lastHash, fmt.Errorf("failed to read audit log: %s", err)
+	}
+	return records, lastHash, nil
+}
+
+// auditCmd works with the audit logs written with -audit-log.
+func auditCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "verify" {
+		return errors.New("usage: audit verify [flags] <audit log>")
+	}
+	return verifyAuditCmd(ctx, args[1:])
+}
+
+// verifyAuditCmd recomputes the chain of hashes of an audit log, failing at the first record
+// edited, removed or moved. It prints the hash of the last record, to keep it elsewhere and
+// tell the last records being removed.
+func verifyAuditCmd(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+
+	var opts options
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("usage: audit verify [flags] <audit log>")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %s", err)
+	}
+	defer f.Close()
+
+	records, lastHash, err := verifyAuditLog(f)
+	if err != nil {
+		fmt.Printf("chain broken after %d valid records\n", records)
+		return err
+	}
+
+	if records == 0 {
+		fmt.Println("audit log is empty")
+		return nil
+	}
+	fmt.Printf("chain intact, %d records, last hash %s\n", records, lastHash)
+	return nil
+}
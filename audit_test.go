@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeAuditLog records the outcome of changes to the image ids in a new audit log, returning its name.
+func writeAuditLog(t *testing.T, ids ...string) string {
+	t.Helper()
+
+	name := filepath.Join(t.TempDir(), "audit.jsonl")
+	appendAuditLog(t, name, ids...)
+	return name
+}
+
+// appendAuditLog opens the audit log as a new run would, recording the changes to the image ids.
+func appendAuditLog(t *testing.T, name string, ids ...string) {
+	t.Helper()
+
+	l, err := openAuditLog(name, "tester@host")
+	if err != nil {
+		t.Fatalf("openAuditLog: %s", err)
+	}
+	defer l.f.Close()
+
+	for i, id := range ids {
+		var err error
+		if i%3 == 2 {
+			err = errors.New("unexpected status code: 500")
+		}
+		l.record(change{ImageID: id, RequireSignedURLs: true}, err)
+	}
+}
+
+func readLines(t *testing.T, name string) []string {
+	t.Helper()
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitAfter(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func verifyLines(lines []string) (int, string, error) {
+	return verifyAuditLog(strings.NewReader(strings.Join(lines, "")))
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	name := writeAuditLog(t, "a", "b", "c", "d")
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, lastHash, err := verifyAuditLog(f)
+	if err != nil {
+		t.Fatalf("verifyAuditLog of an intact log: %s", err)
+	}
+
+	if records != 4 {
+		t.Errorf("verified %d records, want 4", records)
+	}
+
+	want, err := lastAuditHash(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lastHash != want {
+		t.Errorf("last hash is %s, want %s", lastHash, want)
+	}
+}
+
+func TestVerifyAuditLogEmpty(t *testing.T) {
+	records, lastHash, err := verifyAuditLog(strings.NewReader(""))
+	if err != nil || records != 0 || lastHash != "" {
+		t.Errorf("verifyAuditLog of an empty log = %d, %q, %v, want nothing", records, lastHash, err)
+	}
+}
+
+func TestVerifyAuditLogTampered(t *testing.T) {
+	lines := readLines(t, writeAuditLog(t, "a", "b", "c", "d"))
+
+	edit := func(line string, fn func(rec *auditRecord)) string {
+		var rec auditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		fn(&rec)
+
+		b, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b) + "\n"
+	}
+
+	tests := []struct {
+		name  string
+		lines func() []string
+		// valid is the number of records verified before the one tampered with.
+		valid int
+	}{
+		{
+			name: "result edited",
+			lines: func() []string {
+				l := slices.Clone(lines)
+				l[2] = strings.Replace(l[2], `"result":"failed"`, `"result":"ok"`, 1)
+				return l
+			},
+			valid: 2,
+		},
+		{
+			name: "image edited and rehashed",
+			lines: func() []string {
+				l := slices.Clone(lines)
+				// The hash of the line is recomputed, the next line still points to the former one.
+				l[1] = edit(l[1], func(rec *auditRecord) {
+					rec.ImageID = "z"
+					rec.Hash, _ = rec.hash()
+				})
+				return l
+			},
+			valid: 2,
+		},
+		{
+			name: "field added",
+			lines: func() []string {
+				l := slices.Clone(lines)
+				l[0] = strings.Replace(l[0], "{", `{"note":"approved",`, 1)
+				return l
+			},
+			valid: 0,
+		},
+		{
+			name: "line reformatted",
+			lines: func() []string {
+				l := slices.Clone(lines)
+				l[3] = strings.ReplaceAll(l[3], `":`, `": `)
+				return l
+			},
+			valid: 3,
+		},
+		{
+			name: "first line deleted",
+			lines: func() []string {
+				return slices.Clone(lines[1:])
+			},
+			valid: 0,
+		},
+		{
+			name: "middle line deleted",
+			lines: func() []string {
+				return slices.Delete(slices.Clone(lines), 1, 2)
+			},
+			valid: 1,
+		},
+		{
+			name: "lines reordered",
+			lines: func() []string {
+				l := slices.Clone(lines)
+				l[1], l[2] = l[2], l[1]
+				return l
+			},
+			valid: 1,
+		},
+		{
+			name: "line duplicated",
+			lines: func() []string {
+				return slices.Insert(slices.Clone(lines), 2, lines[1])
+			},
+			valid: 2,
+		},
+		{
+			name: "garbage line",
+			lines: func() []string {
+				return slices.Insert(slices.Clone(lines), 2, "not json\n")
+			},
+			valid: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, _, err := verifyLines(tt.lines())
+			if !errors.Is(err, errAuditTampered) {
+				t.Fatalf("verifyAuditLog = %v, want it tampered with", err)
+			}
+
+			if records != tt.valid {
+				t.Errorf("verified %d records before the tampering, want %d", records, tt.valid)
+			}
+		})
+	}
+}
+
+func TestAuditLogContinuesAfterRestart(t *testing.T) {
+	name := writeAuditLog(t, "a", "b")
+	before, err := lastAuditHash(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendAuditLog(t, name, "c", "d", "e")
+
+	lines := readLines(t, name)
+	if len(lines) != 5 {
+		t.Fatalf("audit log has %d lines, want 5", len(lines))
+	}
+
+	var rec auditRecord
+	if err := json.Unmarshal([]byte(lines[2]), &rec); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.PrevHash != before {
+		t.Errorf("first record after the restart follows %q, want the last hash before it %q", rec.PrevHash, before)
+	}
+
+	records, _, err := verifyLines(lines)
+	if err != nil || records != 5 {
+		t.Errorf("verifyAuditLog after the restart = %d, %v, want 5 records intact", records, err)
+	}
+}
+
+func TestAuditLogSkipsAlreadySecured(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	l, err := openAuditLog(name, "tester@host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.record(change{ImageID: "a", RequireSignedURLs: true, alreadySecured: true}, nil)
+	l.f.Close()
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b) != 0 {
+		t.Errorf("audit log has %q, want nothing recorded for an image secured already", b)
+	}
+}
+
+func TestOpenAuditLogRejectsForeignFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := os.WriteFile(name, []byte("not an audit log\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openAuditLog(name, "tester@host"); err == nil {
+		t.Error("openAuditLog of a file which isn't an audit log succeeded, want an error")
+	}
+}
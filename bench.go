@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/cloudflareclient/cloudflaretest"
+)
+
+// benchCmd runs the securing pipeline against the fake server with synthetic images,
+// reporting the throughput, to tune the concurrency and rate limit before touching production.
+func benchCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+
+	var opts options
+	opts.registerApplyFlags(fs)
+	imagesPtr := fs.Int("images", 10000, "number of synthetic unprotected images")
+	latencyPtr := fs.Duration("latency", 50*time.Millisecond, "latency of the responses of the fake server")
+	serverLimitPtr := fs.Int("server-rate-limit", 0, "requests the fake server allows per 5 minutes before answering 429, like the 1200 of the api, 0 for no limit")
+	fs.Float64Var(&opts.rateLimit, "rate-limit", 0, "maximum number of requests per second sent to the api, 0 for no limit")
+	fs.IntVar(&opts.maxAttempts, "max-attempts", cloudflareclient.DefaultRetryPolicy.MaxAttempts, "maximum number of times a request failing with a network error, a 429 or a 5xx is sent")
+	fs.StringVar(&opts.listAPI, "list-api", "v2", "images listing to use, v2 or v1")
+	fs.IntVar(&opts.listConcurrency, "list-concurrency", 4, "number of pages of the v1 listing fetched concurrently")
+	fs.BoolVar(&opts.batchToken, "batch-token", false, "update the images through the batch api, which isn't rate limited")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if *imagesPtr < 1 {
+		return errors.New("-images must be at least 1")
+	}
+
+	if err := opts.validateApply(); err != nil {
+		return err
+	}
+
+	if err := opts.openSinks(ctx); err != nil {
+		return err
+	}
+
+	images := make([]cloudflareclient.Image, *imagesPtr)
+	for i := range images {
+		images[i] = cloudflareclient.Image{
+			ID:       fmt.Sprintf("bench-%07d", i),
+			Filename: fmt.Sprintf("bench-%07d.jpg", i),
+			Uploaded: time.Now().Add(-time.Hour),
+		}
+	}
+
+	srv := cloudflaretest.NewServer("bench", "bench", images...)
+	defer srv.Close()
+
+	srv.SetLatency(*latencyPtr)
+	srv.SetRateLimit(*serverLimitPtr, 5*time.Minute)
+
+	opts.accountID, opts.apiKey = "bench", "bench"
+	opts.apiBaseURL, opts.batchURL = srv.BaseURL, srv.BatchURL
+
+	sel, err := opts.loadSelection()
+	if err != nil {
+		return err
+	}
+
+	s := &securer{
+		cli:    opts.newClient(),
+		opts:   &opts,
+		sel:    sel,
+		status: &runStatus{},
+	}
+
+	sum, err := s.run(ctx)
+	if err != nil {
+		return err
+	}
+
+	secs := sum.Duration.Seconds()
+	fmt.Printf("\nsecured %d of %d images in %s: %.1f images/s, %d api requests (%.1f/s), %d rate limited\n",
+		sum.Secured, *imagesPtr, sum.Duration.Round(time.Millisecond),
+		float64(sum.Secured)/secs, sum.APIRequests, float64(sum.APIRequests)/secs, sum.APIRateLimited)
+	return nil
+}
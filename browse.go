@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// browseCmd opens a terminal ui to browse the images of the account and secure
+// or make public a selection of them, for one-off investigations.
+func browseCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("browse", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	opts.registerSelectionFlags(fs)
+	opts.registerApplyFlags(fs)
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	if err := opts.validateApply(); err != nil {
+		return err
+	}
+
+	if !isTerminal(os.Stdout) {
+		return errors.New("browse requires a terminal")
+	}
+
+	if err := opts.openSinks(ctx); err != nil {
+		return err
+	}
+
+	sel, err := opts.loadSelection()
+	if err != nil {
+		return err
+	}
+
+	stopProfiling, err := opts.startProfiling()
+	if err != nil {
+		return err
+	}
+	defer stopProfiling()
+
+	if err := opts.lockRun(); err != nil {
+		return err
+	}
+	defer opts.lock.release()
+
+	cli, err := opts.connect(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	// Logs would garble the ui, the outcome of the actions is shown in its status line.
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(logger)
+
+	b := &browser{
+		ctx:      ctx,
+		cli:      cli,
+		opts:     &opts,
+		sel:      sel,
+		selected: map[string]bool{},
+		status:   "listing images...",
+	}
+
+	_, err = tea.NewProgram(b, tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	if errors.Is(err, tea.ErrProgramKilled) && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// browserView selects the images shown by their protection status.
+type browserView int
+
+const (
+	viewAll browserView = iota
+	viewUnprotected
+	viewProtected
+)
+
+func (v browserView) String() string {
+	return [...]string{"all", "unprotected", "protected"}[v]
+}
+
+// browser is the model of the browse terminal ui.
+type browser struct {
+	ctx  context.Context
+	cli  cloudflareclient.CloudflareImagesAPI
+	opts *options
+	sel  *selection
+
+	images []cloudflareclient.Image
+	// shown are the indexes in images of the images shown, in order.
+	shown     []int
+	view      browserView
+	search    string
+	searching bool
+	cursor    int
+	offset    int
+	selected  map[string]bool
+
+	width, height int
+	status        string
+	busy          bool
+}
+
+type imagesListedMsg struct {
+	images []cloudflareclient.Image
+	err    error
+}
+
+type changesAppliedMsg struct {
+	res *applyResult
+}
+
+var (
+	headerStyle   = lipgloss.NewStyle().Bold(true)
+	cursorStyle   = lipgloss.NewStyle().Reverse(true)
+	publicStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	detailStyle   = lipgloss.NewStyle().Faint(true)
+	detailHeight  = 8
+	browserChrome = 4
+)
+
+func (b *browser) Init() tea.Cmd {
+	return b.list()
+}
+
+func (b *browser) list() tea.Cmd {
+	b.busy = true
+	return func() tea.Msg {
+		images, err := b.cli.ListImages(b.ctx)
+		return imagesListedMsg{images: images, err: err}
+	}
+}
+
+// apply brings the selected images, or the one under the cursor, to the given state.
+func (b *browser) apply(requireSignedURLs bool) tea.Cmd {
+	ids := make([]string, 0, len(b.selected))
+	for _, i := range b.shown {
+		if b.selected[b.images[i].ID] {
+			ids = append(ids, b.images[i].ID)
+		}
+	}
+
+	if len(ids) == 0 && len(b.shown) > 0 {
+		ids = append(ids, b.images[b.shown[b.cursor]].ID)
+	}
+
+	var changes []change
+	var excluded int
+	for _, img := range b.images {
+		if !slices.Contains(ids, img.ID) || img.RequireSignedURLs == requireSignedURLs || img.Draft {
+			continue
+		}
+
+		// Intentionally public images aren't secured, even on demand.
+		if requireSignedURLs && b.sel.filter.excluded[img.ID] {
+			excluded++
+			continue
+		}
+		changes = append(changes, change{ImageID: img.ID, RequireSignedURLs: requireSignedURLs, meta: knownMeta(img)})
+	}
+
+	if len(changes) == 0 {
+		b.status = "nothing to change"
+		if excluded > 0 {
+			b.status = fmt.Sprintf("nothing to change, %d intentionally public images left as they are", excluded)
+		}
+		return nil
+	}
+
+	b.busy = true
+	b.status = fmt.Sprintf("applying %d changes...", len(changes))
+	return func() tea.Msg {
+		return changesAppliedMsg{res: b.opts.newApplier(b.cli).apply(b.ctx, changes)}
+	}
+}
+
+func (b *browser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		b.width, b.height = msg.Width, msg.Height
+		b.scroll()
+
+	case imagesListedMsg:
+		b.busy = false
+		if msg.err != nil {
+			b.status = "failed to list images: " + msg.err.Error()
+			return b, nil
+		}
+
+		b.images = b.images[:0]
+		for _, img := range msg.images {
+			if b.sel.filter.match(img) {
+				b.images = append(b.images, img)
+			}
+		}
+		b.status = fmt.Sprintf("listed %d images", len(b.images))
+		b.refresh()
+
+	case changesAppliedMsg:
+		b.busy = false
+		for _, c := range msg.res.applied {
+			for i := range b.images {
+				if b.images[i].ID == c.ImageID {
+					b.images[i].RequireSignedURLs = c.RequireSignedURLs
+				}
+			}
+			delete(b.selected, c.ImageID)
+		}
+
+		b.status = fmt.Sprintf("%d changes applied, %d failed", len(msg.res.applied), len(msg.res.failed))
+		if len(msg.res.failed) > 0 {
+			b.status += ": " + msg.res.failed[0].ImageID
+		}
+		b.refresh()
+
+	case tea.KeyMsg:
+		if b.searching {
+			return b, b.updateSearch(msg)
+		}
+		return b, b.updateKey(msg)
+	}
+	return b, nil
+}
+
+func (b *browser) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEnter, tea.KeyEsc:
+		b.searching = false
+	case tea.KeyBackspace:
+		if len(b.search) > 0 {
+			b.search = b.search[:len(b.search)-1]
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		b.search += string(msg.Runes)
+	case tea.KeyCtrlC:
+		return tea.Quit
+	}
+	b.refresh()
+	return nil
+}
+
+func (b *browser) updateKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return tea.Quit
+	case "up", "k":
+		b.move(-1)
+	case "down", "j":
+		b.move(1)
+	case "pgup":
+		b.move(-b.listHeight())
+	case "pgdown":
+		b.move(b.listHeight())
+	case "tab", "f":
+		b.view = (b.view + 1) % 3
+		b.refresh()
+	case "/":
+		b.searching = true
+	case " ":
+		if len(b.shown) > 0 {
+			id := b.images[b.shown[b.cursor]].ID
+			b.selected[id] = !b.selected[id]
+			b.move(1)
+		}
+	case "a":
+		for _, i := range b.shown {
+			b.selected[b.images[i].ID] = true
+		}
+	case "c":
+		clear(b.selected)
+	case "s", "u":
+		if !b.busy {
+			return b.apply(msg.String() == "s")
+		}
+	case "r":
+		if !b.busy {
+			b.status = "listing images..."
+			return b.list()
+		}
+	}
+	return nil
+}
+
+// refresh works out the images shown for the view and search.
+func (b *browser) refresh() {
+	b.shown = b.shown[:0]
+	search := strings.ToLower(b.search)
+	for i, img := range b.images {
+		if (b.view == viewUnprotected && img.RequireSignedURLs) || (b.view == viewProtected && !img.RequireSignedURLs) {
+			continue
+		}
+
+		if search != "" && !strings.Contains(strings.ToLower(img.ID), search) && !strings.Contains(strings.ToLower(img.Filename), search) {
+			continue
+		}
+		b.shown = append(b.shown, i)
+	}
+
+	b.cursor = min(b.cursor, max(len(b.shown)-1, 0))
+	b.scroll()
+}
+
+func (b *browser) move(n int) {
+	b.cursor = max(min(b.cursor+n, len(b.shown)-1), 0)
+	b.scroll()
+}
+
+// scroll keeps the cursor within the rows shown.
+func (b *browser) scroll() {
+	h := b.listHeight()
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+h {
+		b.offset = b.cursor - h + 1
+	}
+}
+
+func (b *browser) listHeight() int {
+	return max(b.height-detailHeight-browserChrome, 1)
+}
+
+func (b *browser) View() string {
+	var s strings.Builder
+
+	header := fmt.Sprintf("account %s | view: %s (%d/%d) | %d selected", b.opts.accountID, b.view, len(b.shown), len(b.images), len(b.selected))
+	if b.searching || b.search != "" {
+		header += " | search: " + b.search
+		if b.searching {
+			header += "_"
+		}
+	}
+	s.WriteString(headerStyle.Render(header) + "\n\n")
+
+	h := b.listHeight()
+	for row := 0; row < h; row++ {
+		i := b.offset + row
+		if i >= len(b.shown) {
+			s.WriteString("\n")
+			continue
+		}
+
+		line := b.row(b.images[b.shown[i]])
+		if b.width > 0 && len(line) > b.width {
+			line = line[:b.width]
+		}
+		if i == b.cursor {
+			line = cursorStyle.Render(line)
+		}
+		s.WriteString(line + "\n")
+	}
+
+	s.WriteString(b.details() + "\n")
+	s.WriteString(detailStyle.Render("↑/↓ move  space select  a all  c clear  s secure  u make public  tab view  / search  r reload  q quit") + "\n")
+	s.WriteString(b.status)
+	return s.String()
+}
+
+func (b *browser) row(img cloudflareclient.Image) string {
+	mark := "[ ]"
+	if b.selected[img.ID] {
+		mark = "[x]"
+	}
+
+	state := "signed"
+	if !img.RequireSignedURLs {
+		state = publicStyle.Render("public")
+	}
+
+	flags := ""
+	if img.Draft {
+		flags += " draft"
+	}
+	if b.sel.filter.excluded[img.ID] {
+		flags += " intentionally-public"
+	}
+
+	uploaded := ""
+	if !img.Uploaded.IsZero() {
+		uploaded = img.Uploaded.Format(time.DateOnly)
+	}
+	return fmt.Sprintf("%s %s  %-36s  %-10s  %s%s", mark, state, img.ID, uploaded, img.Filename, flags)
+}
+
+// details describes the image under the cursor, with the action of the policy for it.
+func (b *browser) details() string {
+	lines := make([]string, 0, detailHeight)
+	if len(b.shown) > 0 {
+		img := b.images[b.shown[b.cursor]]
+
+		action, rule := b.sel.policy.Evaluate(img)
+		desc := string(action)
+		if rule != "" {
+			desc += " (" + rule + ")"
+		}
+
+		meta, _ := json.Marshal(img.Meta)
+		lines = append(lines,
+			"id: "+img.ID,
+			"filename: "+img.Filename,
+			"uploaded: "+img.Uploaded.Format(time.RFC3339),
+			fmt.Sprintf("require signed urls: %t", img.RequireSignedURLs),
+			"policy: "+desc,
+			"metadata: "+string(meta),
+		)
+		if len(img.Variants) > 0 {
+			lines = append(lines, "delivery url: "+img.Variants[0])
+		}
+	}
+
+	for len(lines) < detailHeight {
+		lines = append(lines, "")
+	}
+	return detailStyle.Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// canaryConfig holds the flags securing a random sample of the images first, then
+// the others once the sample soaked for a while or the operator confirmed, for the
+// apps broken by the signed URLs to show up before the whole account is secured.
+type canaryConfig struct {
+	percent percentFlag
+	soak    time.Duration
+	confirm bool
+}
+
+// percentFlag is a percentage given as 5% or 5.
+type percentFlag float64
+
+func (p *percentFlag) String() string {
+	if *p == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(*p), 'f', -1, 64) + "%"
+}
+
+func (p *percentFlag) Set(value string) error {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || v <= 0 || v > 100 {
+		return fmt.Errorf("expected a percentage between 0 and 100, got '%s'", value)
+	}
+	*p = percentFlag(v)
+	return nil
+}
+
+func (c *canaryConfig) registerFlags(fs *flag.FlagSet) {
+	fs.Var(&c.percent, "canary", "secure a random sample of this percentage of the images first (e.g. 5%), then the others after -canary-soak or -canary-confirm")
+	fs.DurationVar(&c.soak, "canary-soak", 0, "how long to wait after securing the -canary sample before securing the others")
+	fs.BoolVar(&c.confirm, "canary-confirm", false, "ask for confirmation before securing the others once the -canary sample soaked")
+}
+
+func (c *canaryConfig) enabled() bool {
+	return c.percent > 0
+}
+
+func (c *canaryConfig) validate() error {
+	if !c.enabled() {
+		if c.soak != 0 || c.confirm {
+			return errors.New("-canary-soak and -canary-confirm require -canary")
+		}
+		return nil
+	}
+
+	if c.soak < 0 {
+		return errors.New("-canary-soak cannot be negative")
+	}
+
+	if c.soak == 0 && !c.confirm {
+		return errors.New("-canary requires -canary-soak or -canary-confirm")
+	}
+	return nil
+}
+
+// split returns a random sample of the images to secure, at least one, and the other changes.
+func (c *canaryConfig) split(changes []change) (sample, rest []change) {
+	var securing []change
+	for _, ch := range changes {
+		if ch.RequireSignedURLs {
+			securing = append(securing, ch)
+		} else {
+			rest = append(rest, ch)
+		}
+	}
+
+	rand.Shuffle(len(securing), func(i, j int) { securing[i], securing[j] = securing[j], securing[i] })
+
+	n := int(math.Ceil(float64(len(securing)) * float64(c.percent) / 100))
+	return securing[:n], append(rest, securing[n:]...)
+}
+
+// applyCanary applies the canary sample of the changes, then the others once it soaked
+// and was confirmed. The others are skipped if a change of the sample fails, the operator
+// doesn't confirm or the run is interrupted meanwhile.
+func (s *securer) applyCanary(ctx context.Context, a *applier, changes []change) *applyResult {
+	sample, rest := s.opts.canary.split(changes)
+	slog.Info("securing the canary sample first", "canary", len(sample), "remaining", len(rest))
+
+	res := a.apply(ctx, sample)
+	if !s.canaryPassed(ctx, res, len(rest)) {
+		res.skipped = append(res.skipped, rest...)
+		return res
+	}
+
+	// The signing key of a migration is only rotated once the canary is confirmed.
+	if m := s.opts.migration; m != nil {
+		if err := m.rotate(ctx, s.cli); err != nil {
+			slog.Error("not going on with the changes after the canary", "error", err)
+			res.skipped = append(res.skipped, rest...)
+			return res
+		}
+	}
+
+	more := a.apply(ctx, rest)
+	res.applied = append(res.applied, more.applied...)
+	res.failed = append(res.failed, more.failed...)
+	for class, n := range more.failures {
+		if res.failures == nil {
+			res.failures = make(map[string]int)
+		}
+		res.failures[class] += n
+	}
+	res.alreadySecured = append(res.alreadySecured, more.alreadySecured...)
+	res.skipped = append(res.skipped, more.skipped...)
+	return res
+}
+
+// canaryPassed tells whether to go on with the other changes once the canary sample is applied.
+func (s *securer) canaryPassed(ctx context.Context, res *applyResult, remaining int) bool {
+	if ctx.Err() != nil || remaining == 0 {
+		return false
+	}
+
+	if len(res.failed) > 0 {
+		slog.Error("changes of the canary failed, not going on with the others", "failed", len(res.failed), "remaining", remaining)
+		return false
+	}
+
+	if soak := s.opts.canary.soak; soak > 0 {
+		slog.Info("soaking the canary", "secured", len(res.applied), "until", time.Now().Add(soak).Format(time.RFC3339))
+		if !sleepUntil(ctx, time.Now().Add(soak)) {
+			return false
+		}
+	}
+
+	if !s.opts.canary.confirm {
+		return true
+	}
+
+	c := newConfirmer(os.Stdin, os.Stdout)
+	answer, err := c.ask(fmt.Sprintf("the canary of %d images soaked, go on with the %d remaining changes? [y]es, [n]o: ", len(res.applied), remaining), "y", "n")
+	if err != nil {
+		slog.Error("not going on with the changes after the canary", "error", err)
+		return false
+	}
+
+	if answer == "n" {
+		slog.Info("not going on with the changes after the canary", "remaining", remaining)
+		return false
+	}
+	return true
+}
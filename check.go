@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// checkCmd reports the number of images unprotected against the policy, without changing
+// them, for monitoring scripts. The images are counted as they are listed.
+func checkCmd(ctx context.Context, args []string) error {
+	// As a nagios plugin, the invalid flags are reported as the UNKNOWN state rather than exiting.
+	errorHandling := flag.ExitOnError
+	if nagiosOutput(args) {
+		errorHandling = flag.ContinueOnError
+	}
+	fs := flag.NewFlagSet("check", errorHandling)
+
+	var (
+		opts       options
+		thresholds nagiosThresholds
+	)
+	opts.registerClientFlags(fs)
+	opts.registerSelectionFlags(fs)
+	quietPtr := fs.Bool("quiet", false, "print the count alone, the logs going to stderr")
+	accountsPtr := fs.String("accounts", "", "comma separated ids of the accounts to count the images of with the same credentials, instead of -account-id")
+	outputPtr := fs.String("output", "table", "how to print the count, table, nagios for the status line of a nagios plugin, exiting with its code, or zabbix for the json of a zabbix low-level discovery of the accounts")
+	fs.IntVar(&thresholds.warn, "warn", 0, "with -output nagios, the number of images unprotected from which the check warns, 0 to never warn")
+	fs.IntVar(&thresholds.crit, "crit", 1, "with -output nagios, the number of images unprotected from which the check is critical, 0 to never be")
+	if err := opts.parse(fs, args); err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			return nil
+		case errors.Is(err, errFlagsCollected) || errorHandling != flag.ContinueOnError:
+			return err
+		}
+		return writeNagiosUnknown(os.Stdout, err)
+	}
+
+	usageErr := func(err error) error {
+		fs.Usage()
+		if *outputPtr == "nagios" {
+			return writeNagiosUnknown(os.Stdout, err)
+		}
+		return err
+	}
+
+	switch {
+	case *outputPtr != "table" && *outputPtr != "nagios" && *outputPtr != "zabbix":
+		return usageErr(fmt.Errorf("invalid -output '%s', expected table, nagios or zabbix", *outputPtr))
+	case thresholds.warn < 0 || thresholds.crit < 0:
+		return usageErr(errors.New("-warn and -crit can't be negative"))
+	}
+
+	accounts := splitList(*accountsPtr)
+	if len(accounts) > 0 {
+		if opts.accountID != "" {
+			return usageErr(errors.New("-accounts and -account-id cannot be used together"))
+		}
+		opts.accountID = accounts[0]
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		return usageErr(err)
+	}
+
+	if len(accounts) == 0 {
+		accounts = []string{opts.accountID}
+	}
+
+	counts, err := countAccounts(ctx, opts, accounts)
+
+	if *outputPtr == "nagios" {
+		if err != nil {
+			return writeNagiosUnknown(os.Stdout, err)
+		}
+		return writeNagios(os.Stdout, totalUnprotected(counts), thresholds)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *outputPtr == "zabbix":
+		return writeZabbixDiscovery(os.Stdout, counts)
+	case *quietPtr:
+		fmt.Println(totalUnprotected(counts))
+	case len(counts) == 1:
+		fmt.Printf("%d images unprotected\n", counts[0].unprotected)
+	default:
+		for _, c := range counts {
+			fmt.Printf("%s: %d images unprotected\n", c.accountID, c.unprotected)
+		}
+	}
+	return nil
+}
+
+// accountCount is the number of images unprotected of an account.
+type accountCount struct {
+	accountID   string
+	unprotected int
+}
+
+// countAccounts counts the images unprotected of each account in turn, with the same options
+// but for the account.
+func countAccounts(ctx context.Context, opts options, accounts []string) ([]accountCount, error) {
+	counts := make([]accountCount, 0, len(accounts))
+	for _, id := range accounts {
+		opts.accountID = id
+		n, err := countUnprotected(ctx, &opts)
+		if err != nil {
+			if len(accounts) > 1 {
+				return nil, fmt.Errorf("account %s: %w", id, err)
+			}
+			return nil, err
+		}
+		counts = append(counts, accountCount{accountID: id, unprotected: n})
+	}
+	return counts, nil
+}
+
+func totalUnprotected(counts []accountCount) int {
+	var total int
+	for _, c := range counts {
+		total += c.unprotected
+	}
+	return total
+}
+
+// countUnprotected counts the images the selection and the policy would protect.
+func countUnprotected(ctx context.Context, opts *options) (int, error) {
+	sel, err := opts.loadSelection()
+	if err != nil {
+		return 0, err
+	}
+
+	cli, err := opts.connect(ctx, false)
+	if err != nil {
+		return 0, err
+	}
+
+	var unprotected int
+	for img, err := range cli.Images(ctx) {
+		if err != nil {
+			return 0, fmt.Errorf("failed to list images: %s", err)
+		}
+
+		changes, _, _, _ := sel.filter.changes([]cloudflareclient.Image{img}, sel.policy)
+		for _, c := range changes {
+			if c.RequireSignedURLs {
+				unprotected++
+			}
+		}
+	}
+	return unprotected, nil
+}
+
+// nagiosOutput tells whether the arguments ask for -output nagios, before they are parsed.
+// The last -output given wins, as when parsed.
+func nagiosOutput(args []string) bool {
+	var nagios bool
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		name, value, ok := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != "output" || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if !ok && i+1 < len(args) {
+			value = args[i+1]
+		}
+		nagios = value == "nagios"
+	}
+	return nagios
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const checkpointVersion = 1
+
+// A checkpoint file records the progress of a run so it can be resumed.
+// It is a JSON lines file: the first line holds the changes of the run and
+// each following line the outcome of one of them, appended as it happens.
+type checkpointHeader struct {
+	Version   int       `json:"version"`
+	AccountID string    `json:"account_id"`
+	StartedAt time.Time `json:"started_at"`
+	Changes   []change  `json:"changes"`
+}
+
+type checkpointRecord struct {
+	ImageID string `json:"image_id"`
+	OK      bool   `json:"ok"`
+}
+
+type checkpoint struct {
+	name   string
+	f      *os.File
+	enc    *json.Encoder
+	failed bool
+}
+
+// createCheckpoint starts a new checkpoint file for the changes of a run.
+func createCheckpoint(name, accountID string, changes []change) (*checkpoint, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not create checkpoint: %s", err)
+	}
+
+	cp := checkpoint{name: name, f: f, enc: json.NewEncoder(f)}
+
+	header := checkpointHeader{
+		Version:   checkpointVersion,
+		AccountID: accountID,
+		StartedAt: time.Now().UTC(),
+		Changes:   changes,
+	}
+
+	if err := cp.enc.Encode(header); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not write checkpoint: %s", err)
+	}
+	return &cp, nil
+}
+
+// resumeCheckpoint opens an existing checkpoint file to append to it,
+// returning its header and the changes not successfully applied yet.
+func resumeCheckpoint(name string) (*checkpoint, *checkpointHeader, []change, error) {
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("could not open checkpoint: %s", err)
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<30)
+
+	if !scanner.Scan() {
+		f.Close()
+		return nil, nil, nil, errors.New("empty checkpoint")
+	}
+
+	var header checkpointHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		f.Close()
+		return nil, nil, nil, fmt.Errorf("could not decode checkpoint: %s", err)
+	}
+
+	if header.Version != checkpointVersion {
+		f.Close()
+		return nil, nil, nil, fmt.Errorf("unsupported checkpoint version %d", header.Version)
+	}
+
+	done := map[string]bool{}
+	for scanner.Scan() {
+		var rec checkpointRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// The last line may be cut short by a crash, the image is just attempted again.
+			continue
+		}
+		done[rec.ImageID] = rec.OK
+	}
+
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, nil, nil, fmt.Errorf("could not read checkpoint: %s", err)
+	}
+
+	// Make sure the records we append start on their own line.
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return nil, nil, nil, fmt.Errorf("could not seek checkpoint: %s", err)
+	}
+
+	if _, err := f.WriteString("\n"); err != nil {
+		f.Close()
+		return nil, nil, nil, fmt.Errorf("could not write checkpoint: %s", err)
+	}
+
+	var pending []change
+	for _, c := range header.Changes {
+		if !done[c.ImageID] {
+			pending = append(pending, c)
+		}
+	}
+	return &checkpoint{name: name, f: f, enc: json.NewEncoder(f)}, &header, pending, nil
+}
+
+// record appends the outcome of a change to the checkpoint.
+func (cp *checkpoint) record(c change, err error) {
+	if werr := cp.enc.Encode(checkpointRecord{ImageID: c.ImageID, OK: err == nil}); werr != nil && !cp.failed {
+		// Reported once: losing the checkpoint only means images are attempted again on resume.
+		cp.failed = true
+		slog.Error("failed to write checkpoint", "file", cp.name, "error", werr)
+	}
+}
+
+func (cp *checkpoint) Close() error {
+	return cp.f.Close()
+}
+
+// remove deletes the checkpoint file once the run doesn't need resuming.
+func (cp *checkpoint) remove() error {
+	cp.f.Close()
+	return os.Remove(cp.name)
+}
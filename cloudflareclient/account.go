@@ -0,0 +1,27 @@
+package cloudflareclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Account is the Cloudflare account of the client.
+type Account struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// GetAccount makes a request to Cloudflare to get the account, checking the credentials can access it.
+// https://developers.cloudflare.com/api/resources/accounts/methods/get/
+func (c *Client) GetAccount(ctx context.Context) (*Account, error) {
+	var accountResp struct {
+		Result Account `json:"result"`
+	}
+
+	path := fmt.Sprintf("/accounts/%s", c.accountID)
+	if err := c.do(ctx, "cloudflare.accounts.get", http.MethodGet, path, nil, &accountResp); err != nil {
+		return nil, err
+	}
+	return &accountResp.Result, nil
+}
@@ -0,0 +1,33 @@
+package cloudflareclient
+
+import (
+	"context"
+	"iter"
+	"time"
+)
+
+// CloudflareImagesAPI is the part of the Cloudflare Images API implemented by Client,
+// to swap it for a mock in tests.
+type CloudflareImagesAPI interface {
+	ListImages(ctx context.Context) ([]Image, error)
+	Images(ctx context.Context) iter.Seq2[Image, error]
+	GetUnprotectedImages(ctx context.Context) ([]string, error)
+	GetImage(ctx context.Context, imageID string) (*Image, error)
+	SecureImage(ctx context.Context, imageID string) error
+	SecureImages(ctx context.Context, imageIDs []string, concurrency int) (*RunResult, error)
+	SetRequireSignedURLs(ctx context.Context, imageID string, requireSignedURLs bool) error
+	UpdateImage(ctx context.Context, imageID string, update ImageUpdate) error
+	DeleteImage(ctx context.Context, imageID string) error
+	GetStats(ctx context.Context) (*Stats, error)
+	VerifyToken(ctx context.Context) (*Token, error)
+	Preflight(ctx context.Context, checkWrite bool) error
+	GetAccount(ctx context.Context) (*Account, error)
+	SigningKeys(ctx context.Context) ([]SigningKey, error)
+	CreateSigningKey(ctx context.Context, name string) (*SigningKey, error)
+	ListVariants(ctx context.Context) ([]Variant, error)
+	CreateVariant(ctx context.Context, variant Variant) (*Variant, error)
+	UpdateVariant(ctx context.Context, variant Variant) (*Variant, error)
+	AuditLogs(ctx context.Context, since time.Time) iter.Seq2[AuditLog, error]
+}
+
+var _ CloudflareImagesAPI = (*Client)(nil)
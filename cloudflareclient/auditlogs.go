@@ -0,0 +1,84 @@
+package cloudflareclient
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const maxAuditLogsPageSize int = 1000
+
+// AuditLog is an entry of the audit log of the account, recording an action on one of its resources.
+type AuditLog struct {
+	ID       string        `json:"id"`
+	When     time.Time     `json:"when"`
+	Action   AuditAction   `json:"action"`
+	Actor    AuditActor    `json:"actor"`
+	Resource AuditResource `json:"resource"`
+	// Interface is how the action was made, like API or UI.
+	Interface string `json:"interface"`
+}
+
+// AuditAction is what was done.
+type AuditAction struct {
+	Type   string `json:"type"`
+	Result bool   `json:"result"`
+}
+
+// AuditActor is who made the action: a user, identified by their email, or an API token.
+type AuditActor struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+	IP    string `json:"ip"`
+	Type  string `json:"type"`
+}
+
+// AuditResource is what the action was made on.
+type AuditResource struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type auditLogsResponse struct {
+	Result []AuditLog `json:"result"`
+}
+
+// AuditLogs returns an iterator over the entries of the audit log of the account since the given time,
+// oldest first, fetching the pages as the iteration goes. An error ends the iteration, yielded with a
+// zero AuditLog. Reading the audit log requires the Account Audit Logs Read permission.
+// https://developers.cloudflare.com/api/resources/audit_logs/methods/list/
+func (c *Client) AuditLogs(ctx context.Context, since time.Time) iter.Seq2[AuditLog, error] {
+	return func(yield func(AuditLog, error) bool) {
+		for page := 1; ; page++ {
+			query := url.Values{
+				"since":     {since.UTC().Format(time.RFC3339)},
+				"direction": {"asc"},
+				"page":      {strconv.Itoa(page)},
+				"per_page":  {strconv.Itoa(maxAuditLogsPageSize)},
+			}
+			path := fmt.Sprintf("/accounts/%s/audit_logs?%s", c.accountID, query.Encode())
+
+			var resp auditLogsResponse
+			if err := c.do(ctx, "cloudflare.audit_logs.list", http.MethodGet, path, nil, &resp, attribute.Int("cloudflare.page", page)); err != nil {
+				yield(AuditLog{}, fmt.Errorf("could not list audit logs page %d: %w", page, err))
+				return
+			}
+
+			for _, l := range resp.Result {
+				if !yield(l, nil) {
+					return
+				}
+			}
+
+			if len(resp.Result) < maxAuditLogsPageSize {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package cloudflareclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBatchURL is the base URL of the Cloudflare Images batch API.
+const DefaultBatchURL = "https://batch.imagedelivery.net"
+
+// batchTokenMargin is how long before its expiry a batch token is renewed.
+const batchTokenMargin = time.Minute
+
+// batch holds the batch token the images requests are sent with.
+type batch struct {
+	url string
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// batchPath returns the path of the request on the batch API, and whether it can go through it.
+// The batch API serves the images endpoints without the account prefix, the usage statistics aside.
+func (c *Client) batchPath(path string) (string, bool) {
+	if c.batch == nil {
+		return "", false
+	}
+
+	rest, ok := strings.CutPrefix(path, "/accounts/"+c.accountID+"/images/")
+	if !ok || strings.HasPrefix(rest, "v1/batch_token") || rest == "v1/stats" {
+		return "", false
+	}
+	return "/images/" + rest, true
+}
+
+// batchToken returns a valid batch token, requesting a new one if there is none yet
+// or the current one is about to expire. Concurrent callers share the same token.
+func (c *Client) batchToken(ctx context.Context) (string, error) {
+	c.batch.mu.Lock()
+	defer c.batch.mu.Unlock()
+
+	if c.batch.token != "" && time.Until(c.batch.expiresAt) > batchTokenMargin {
+		return c.batch.token, nil
+	}
+
+	var tokenResp struct {
+		Result struct {
+			Token     string    `json:"token"`
+			ExpiresAt time.Time `json:"expiresAt"`
+		} `json:"result"`
+	}
+	path := fmt.Sprintf("/accounts/%s/images/v1/batch_token", c.accountID)
+	if err := c.do(ctx, "cloudflare.images.batch_token", http.MethodPost, path, nil, &tokenResp); err != nil {
+		return "", fmt.Errorf("could not get batch token: %w", err)
+	}
+
+	c.batch.token = tokenResp.Result.Token
+	c.batch.expiresAt = tokenResp.Result.ExpiresAt
+	return c.batch.token, nil
+}
+
+// dropBatchToken forgets the token after the batch API rejected it, so the next request gets a new one.
+func (c *Client) dropBatchToken(token string) {
+	c.batch.mu.Lock()
+	defer c.batch.mu.Unlock()
+
+	if c.batch.token == token {
+		c.batch.token = ""
+	}
+}
+
+// batchTokenRejectedError is a request rejected by the batch API because of its token,
+// worth retrying with a new one.
+type batchTokenRejectedError struct {
+	err error
+}
+
+func (e *batchTokenRejectedError) Error() string {
+	return "batch token rejected: " + e.err.Error()
+}
+
+func (e *batchTokenRejectedError) Unwrap() error {
+	return e.err
+}
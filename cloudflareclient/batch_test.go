@@ -0,0 +1,65 @@
+package cloudflareclient_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/cloudflareclient/cloudflaretest"
+)
+
+func TestBatchToken(t *testing.T) {
+	srv := cloudflaretest.NewServer(testAccountID, testToken, cloudflareclient.Image{ID: "image"})
+	defer srv.Close()
+
+	cli := srv.Client(cloudflareclient.WithBatchToken(srv.BatchURL), cloudflareclient.WithRetryPolicy(fastRetries))
+	ctx := context.Background()
+
+	// The requests share the token of the first one.
+	for range 3 {
+		if _, err := cli.GetImage(ctx, "image"); err != nil {
+			t.Fatalf("GetImage: %s", err)
+		}
+	}
+
+	if n := countRequests(srv, http.MethodPost, "/batch_token"); n != 1 {
+		t.Errorf("requested %d batch tokens, want 1", n)
+	}
+
+	// An expired token is rejected by the batch api, the request is retried with a new one.
+	srv.ExpireBatchTokens()
+	if err := cli.SetRequireSignedURLs(ctx, "image", true); err != nil {
+		t.Fatalf("SetRequireSignedURLs with an expired batch token: %s", err)
+	}
+
+	if n := countRequests(srv, http.MethodPost, "/batch_token"); n != 2 {
+		t.Errorf("requested %d batch tokens, want a new one after the expiry", n)
+	}
+
+	if img, _ := srv.Image("image"); !img.RequireSignedURLs {
+		t.Error("image not updated after renewing the batch token")
+	}
+
+	for _, r := range srv.Requests() {
+		if r.ImageID != "" && !strings.HasPrefix(r.Path, "/batch/") {
+			t.Errorf("%s %s sent to the api rather than the batch api", r.Method, r.Path)
+		}
+	}
+}
+
+func TestBatchTokenStatsNotBatched(t *testing.T) {
+	srv := cloudflaretest.NewServer(testAccountID, testToken)
+	defer srv.Close()
+
+	cli := srv.Client(cloudflareclient.WithBatchToken(srv.BatchURL))
+
+	if _, err := cli.GetStats(context.Background()); err != nil {
+		t.Fatalf("GetStats: %s", err)
+	}
+
+	if n := countRequests(srv, http.MethodPost, "/batch_token"); n != 0 {
+		t.Errorf("requested %d batch tokens for the usage statistics, want none", n)
+	}
+}
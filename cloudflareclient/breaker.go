@@ -0,0 +1,102 @@
+package cloudflareclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker pauses the requests while the API looks down, rather than sending
+// every request only to have it fail.
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive requests failing with a network error
+	// or a 5xx status that trips the breaker.
+	Threshold int
+	// CoolDown is how long requests are paused once tripped. After it a single
+	// failure trips the breaker again, until a request succeeds.
+	CoolDown time.Duration
+	// OnTrip and OnReset, if set, are called when the breaker trips, with the error
+	// of the last failure, and when requests succeed again.
+	OnTrip  func(err error, coolDown time.Duration)
+	OnReset func()
+}
+
+// breaker is the state of the circuit breaker of a client.
+type breaker struct {
+	cfg CircuitBreaker
+
+	mu       sync.Mutex
+	failures int
+	// tripped is set from the trip until a request succeeds, openUntil is the end of the cool-down.
+	tripped   bool
+	openUntil time.Time
+}
+
+// wait blocks while the breaker is open, or until the context is done.
+func (b *breaker) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+
+	for {
+		b.mu.Lock()
+		d := time.Until(b.openUntil)
+		b.mu.Unlock()
+
+		if d <= 0 {
+			return nil
+		}
+
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// record updates the breaker with the outcome of a request.
+func (b *breaker) record(ctx context.Context, err error) {
+	if b == nil || ctx.Err() != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !outage(err) {
+		if err == nil && b.tripped {
+			b.tripped = false
+			if b.cfg.OnReset != nil {
+				b.cfg.OnReset()
+			}
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+
+	// Requests sent before the trip may fail while it is open, they don't extend it.
+	if time.Now().Before(b.openUntil) {
+		return
+	}
+
+	if b.failures >= b.cfg.Threshold || b.tripped {
+		b.tripped = true
+		b.openUntil = time.Now().Add(b.cfg.CoolDown)
+		if b.cfg.OnTrip != nil {
+			b.cfg.OnTrip(err, b.cfg.CoolDown)
+		}
+	}
+}
+
+// outage reports whether the request failed because of the API rather than of the request itself.
+func outage(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+
+	var sendErr *sendError
+	return errors.As(err, &sendErr)
+}
@@ -0,0 +1,105 @@
+package cloudflareclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/cloudflareclient/cloudflaretest"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	srv := cloudflaretest.NewServer(testAccountID, testToken, cloudflareclient.Image{ID: "image"})
+	defer srv.Close()
+
+	var (
+		mu     sync.Mutex
+		trips  int
+		resets int
+	)
+	coolDown := 200 * time.Millisecond
+	cli := srv.Client(cloudflareclient.WithCircuitBreaker(cloudflareclient.CircuitBreaker{
+		Threshold: 3,
+		CoolDown:  coolDown,
+		OnTrip: func(error, time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			trips++
+		},
+		OnReset: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			resets++
+		},
+	}))
+	ctx := context.Background()
+
+	// Client errors aren't outages, they don't count toward the threshold.
+	srv.Fail(cloudflaretest.Failure{Method: http.MethodGet, StatusCode: http.StatusNotFound, Times: 5})
+	for range 5 {
+		cli.GetImage(ctx, "image")
+	}
+
+	srv.Fail(cloudflaretest.Failure{Method: http.MethodGet, StatusCode: http.StatusInternalServerError, Times: 3})
+	for i := range 3 {
+		_, err := cli.GetImage(ctx, "image")
+
+		var statusErr *cloudflareclient.StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("request %d = %v, want a 500", i+1, err)
+		}
+	}
+
+	mu.Lock()
+	if trips != 1 {
+		t.Errorf("tripped %d times after reaching the threshold, want 1", trips)
+	}
+	mu.Unlock()
+
+	// The requests are paused for the cool-down, then go through.
+	sent := len(srv.Requests())
+	start := time.Now()
+	if _, err := cli.GetImage(ctx, "image"); err != nil {
+		t.Fatalf("GetImage after the cool-down: %s", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < coolDown*3/4 {
+		t.Errorf("request sent %s after the trip, want it paused for the cool-down of %s", elapsed, coolDown)
+	}
+
+	if n := len(srv.Requests()) - sent; n != 1 {
+		t.Errorf("sent %d requests after the cool-down, want 1", n)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if resets != 1 {
+		t.Errorf("reset %d times after a request succeeded, want 1", resets)
+	}
+}
+
+func TestCircuitBreakerWaitStopsWithContext(t *testing.T) {
+	srv := cloudflaretest.NewServer(testAccountID, testToken, cloudflareclient.Image{ID: "image"})
+	defer srv.Close()
+
+	cli := srv.Client(cloudflareclient.WithCircuitBreaker(cloudflareclient.CircuitBreaker{Threshold: 1, CoolDown: time.Hour}))
+
+	srv.Fail(cloudflaretest.Failure{Method: http.MethodGet, StatusCode: http.StatusBadGateway, Times: 1})
+	cli.GetImage(context.Background(), "image")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	sent := len(srv.Requests())
+	if _, err := cli.GetImage(ctx, "image"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetImage while open = %v, want the deadline exceeded", err)
+	}
+
+	if n := len(srv.Requests()) - sent; n != 0 {
+		t.Errorf("sent %d requests while open, want none", n)
+	}
+}
@@ -0,0 +1,101 @@
+package cloudflareclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ImageResult is the outcome of securing an image with SecureImages.
+type ImageResult struct {
+	ImageID string
+	// Err is nil when the image was secured, ErrAlreadySecured when it required signed URLs
+	// already, and the error of the context for the images not attempted before it was done.
+	Err error
+	// Attempts are the requests made to the API for the image, retries included.
+	Attempts int
+	Duration time.Duration
+}
+
+// RunResult is the outcome of SecureImages, with an ImageResult per image in the order given.
+type RunResult struct {
+	Images   []ImageResult
+	Duration time.Duration
+}
+
+// Secured returns the ids of the images secured.
+func (r *RunResult) Secured() []string {
+	var ids []string
+	for _, img := range r.Images {
+		if img.Err == nil {
+			ids = append(ids, img.ImageID)
+		}
+	}
+	return ids
+}
+
+// Failed returns the outcome of the images which failed to be secured, or weren't attempted.
+func (r *RunResult) Failed() []ImageResult {
+	var failed []ImageResult
+	for _, img := range r.Images {
+		if img.Err != nil && !errors.Is(img.Err, ErrAlreadySecured) {
+			failed = append(failed, img)
+		}
+	}
+	return failed
+}
+
+// Err joins the errors of the images which failed to be secured, or weren't attempted,
+// each an *ImageError, nil if none did.
+func (r *RunResult) Err() error {
+	var errs []error
+	for _, img := range r.Failed() {
+		errs = append(errs, &ImageError{ImageID: img.ImageID, Err: img.Err})
+	}
+	return errors.Join(errs...)
+}
+
+// SecureImages secures the images with SecureImage, concurrency of them at a time, returning
+// the outcome of every image rather than stopping at the first failing one, and the errors
+// of the images failing joined, as RunResult.Err.
+func (c *Client) SecureImages(ctx context.Context, imageIDs []string, concurrency int) (*RunResult, error) {
+	start := time.Now()
+	res := RunResult{Images: make([]ImageResult, len(imageIDs))}
+
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, id := range imageIDs {
+		res.Images[i].ImageID = id
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			res.Images[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+
+			var attempts atomic.Int64
+			imgStart := time.Now()
+			err := c.SecureImage(context.WithValue(ctx, attemptsKey{}, &attempts), id)
+			res.Images[i] = ImageResult{ImageID: id, Err: err, Attempts: int(attempts.Load()), Duration: time.Since(imgStart)}
+		})
+	}
+	wg.Wait()
+
+	res.Duration = time.Since(start)
+	return &res, res.Err()
+}
+
+type attemptsKey struct{}
+
+// countAttempt counts an attempt of a request in the counter of the context, if any.
+func countAttempt(ctx context.Context) {
+	if n, ok := ctx.Value(attemptsKey{}).(*atomic.Int64); ok {
+		n.Add(1)
+	}
+}
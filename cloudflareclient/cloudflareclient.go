@@ -1,118 +1,272 @@
+// Package cloudflareclient is a client for the Cloudflare Images API.
 package cloudflareclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const maxPageSize int = 100
+// DefaultBaseURL is the base URL of the Cloudflare API.
+const DefaultBaseURL = "https://api.cloudflare.com/client/v4"
+
+const defaultUserAgent = "securecloudflareimage-go"
 
+// tracer creates the spans of the API calls, using the global tracer provider
+// which doesn't record anything unless the application sets one up.
+var tracer = otel.Tracer("github.com/alesr/securecloudflareimage/cloudflareclient")
+
+// Client talks to the Cloudflare Images API of an account.
 type Client struct {
 	httpCli   *http.Client
 	accountID string
-	apiKey    string
+	apiToken  string
+	authEmail string
+	authKey   string
+	baseURL   string
+	retry     RetryPolicy
+	limiter   *limiter
+	breaker   *breaker
+	userAgent string
+	// headers are added to every request.
+	headers http.Header
+	// logger logs every request at debug level, if set.
+	logger *slog.Logger
+	hooks  Hooks
+	// requestTimeout bounds each attempt of a request, if not zero.
+	requestTimeout time.Duration
+	// batch routes the images requests through the batch api, if enabled.
+	batch *batch
+	// listV2 lists the images with the cursor paginated v2 listing.
+	listV2 bool
+	// listConcurrency is the number of v1 pages fetched at once.
+	listConcurrency int
+	// pageSize is the number of images per page of the listings, 0 for the largest pages.
+	pageSize int
+	// middlewares wrap the transport of httpCli, applied by New.
+	middlewares []Middleware
 }
 
-func New(httpCli *http.Client, accountID, apiKey string) *Client {
-	return &Client{
-		httpCli:   httpCli,
+// New returns a client for the images of the account, configured by the options.
+func New(accountID string, opts ...Option) *Client {
+	c := Client{
+		httpCli:   &http.Client{Transport: NewTransport()},
 		accountID: accountID,
-		apiKey:    apiKey,
+		baseURL:   DefaultBaseURL,
+		retry:     noRetries,
+		userAgent: defaultUserAgent,
 	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if len(c.middlewares) > 0 {
+		transport := c.httpCli.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+
+		for i := len(c.middlewares) - 1; i >= 0; i-- {
+			transport = c.middlewares[i](transport)
+		}
+
+		// Shallow copy to leave the given http client untouched.
+		httpCli := *c.httpCli
+		httpCli.Transport = transport
+		c.httpCli = &httpCli
+	}
+	return &c
 }
 
-type cloudflareResponse struct {
-	Result struct {
-		Images []struct {
-			ID                string `json:"id"`
-			RequireSignedURLs bool   `json:"requireSignedURLs"`
-		} `json:"images"`
-	} `json:"result"`
-	Success bool `json:"success"`
+// responseEnvelope holds the fields common to every API response.
+type responseEnvelope struct {
+	Success bool                  `json:"success"`
+	Errors  []ResponseErrorDetail `json:"errors"`
 }
 
-// getUnprotectedImages makes a request to cloudflare to list all the images
-// and returns the ids of the ones that have required signed url set to false.
-// Does not support pagination, but that is not a problem for now.
-// https://api.cloudflare.com/#cloudflare-images-list-images
-func (c *Client) GetUnprotectedImages() ([]string, error) {
-	u := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/images/v1?page=1&per_page=%d", c.accountID, maxPageSize)
+// do sends a request to the API, retrying it according to the retry policy,
+// and decodes the JSON response into v. The path is relative to the base URL.
+func (c *Client) do(ctx context.Context, operation, method, path string, reqBody []byte, v any, attrs ...attribute.KeyValue) error {
+	for attempt := 1; ; attempt++ {
+		if err := c.breaker.wait(ctx); err != nil {
+			return err
+		}
 
-	req, err := http.NewRequest(http.MethodGet, u, nil)
-	if err != nil {
-		return nil, fmt.Errorf("could not prepare request: %s", err)
-	}
+		err := c.doOnce(ctx, operation, method, path, reqBody, v, attempt, attrs)
+		c.breaker.record(ctx, err)
+		countAttempt(ctx)
+		if err == nil {
+			return nil
+		}
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+		if attempt >= c.retry.MaxAttempts || !retryable(ctx, err) {
+			if attempt > 1 {
+				return &AttemptsError{Attempts: attempt, Err: err}
+			}
+			return err
+		}
 
-	resp, err := c.httpCli.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("could not send request: %s", err)
-	}
-	defer resp.Body.Close()
+		delay := c.retry.delay(attempt, err)
+		if c.hooks.OnRetry != nil {
+			c.hooks.OnRetry(ctx, RequestInfo{Operation: operation, Method: method, Path: path, Attempt: attempt}, err, delay)
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		if err := sleep(ctx, delay); err != nil {
+			return &AttemptsError{Attempts: attempt, Err: err}
+		}
 	}
+}
+
+// doOnce sends a request to the API within a span named after the operation.
+// Errors carry the CF-Ray header of the response, and for unexpected status codes
+// the beginning of the body.
+func (c *Client) doOnce(ctx context.Context, operation, method, path string, reqBody []byte, v any, attempt int, attrs []attribute.KeyValue) (err error) {
+	// Images requests go through the batch api when enabled, with a batch token,
+	// without being subject to the rate limit of the api.
+	batchPath, batched := c.batchPath(path)
 
-	var listImagesResp cloudflareResponse
-	if err := json.NewDecoder(resp.Body).Decode(&listImagesResp); err != nil {
-		return nil, fmt.Errorf("could not decode response: %s", err)
+	if c.limiter != nil && !batched {
+		if err := c.limiter.wait(ctx); err != nil {
+			return err
+		}
 	}
 
-	if !listImagesResp.Success {
-		return nil, fmt.Errorf("list images response not successful")
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
 	}
 
-	if len(listImagesResp.Result.Images) == maxPageSize {
-		log.Println("there's probably more pages to go through")
+	ctx, span := tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("cloudflare.account_id", c.accountID),
+			attribute.Int("cloudflare.attempt", attempt),
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	var body io.Reader
+	if reqBody != nil {
+		body = bytes.NewReader(reqBody)
 	}
 
-	var unprotectedImages []string
-	for _, image := range listImagesResp.Result.Images {
-		if !image.RequireSignedURLs {
-			unprotectedImages = append(unprotectedImages, image.ID)
+	reqURL := c.baseURL + path
+
+	var batchToken string
+	if batched {
+		if batchToken, err = c.batchToken(ctx); err != nil {
+			return err
 		}
+		reqURL = c.batch.url + batchPath
+		span.SetAttributes(attribute.Bool("cloudflare.batch", true))
 	}
-	return unprotectedImages, nil
-}
 
-// SecureImage makes a request to Cloudflare to update the image to require signed URLs.
-func (c *Client) SecureImage(imageID string) error {
-	u := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/images/v1/%s", c.accountID, imageID)
-	req, err := http.NewRequest(http.MethodPatch, u, nil)
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
 	if err != nil {
 		return fmt.Errorf("could not prepare request: %s", err)
 	}
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
-	req.Header.Add("Content-Type", "application/json")
+	for k, values := range c.headers {
+		req.Header[k] = slices.Clone(values)
+	}
 
-	reqBody := []byte(`{"requireSignedURLs": true}`)
-	req.Body = io.NopCloser(bytes.NewReader(reqBody))
-	req.ContentLength = int64(len(reqBody))
+	switch {
+	case batched:
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", batchToken))
+	case c.authKey != "":
+		req.Header.Set("X-Auth-Email", c.authEmail)
+		req.Header.Set("X-Auth-Key", c.authKey)
+	default:
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiToken))
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	resp, err := c.httpCli.Do(req)
+	resp, err := c.send(req, RequestInfo{Operation: operation, Method: method, Path: path, Attempt: attempt})
 	if err != nil {
-		return fmt.Errorf("could not send request: %s", err)
+		return &sendError{err: err}
 	}
 	defer resp.Body.Close()
 
+	rayID := resp.Header.Get("CF-Ray")
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if rayID != "" {
+		span.SetAttributes(attribute.String("cloudflare.ray_id", rayID))
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		statusErr := &StatusError{
+			StatusCode: resp.StatusCode,
+			RayID:      rayID,
+			Body:       string(bytes.TrimSpace(body)),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+
+		if batched && resp.StatusCode == http.StatusUnauthorized {
+			c.dropBatchToken(batchToken)
+			return &batchTokenRejectedError{err: statusErr}
+		}
+		return statusErr
 	}
 
-	var updateImageResp cloudflareResponse
-	if err := json.NewDecoder(resp.Body).Decode(&updateImageResp); err != nil {
-		return fmt.Errorf("could not decode response: %s", err)
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &sendError{err: fmt.Errorf("could not read response (ray id %s): %s", rayID, err)}
+	}
+
+	var envelope responseEnvelope
+	if err := json.Unmarshal(respBody, &envelope); err != nil {
+		return fmt.Errorf("could not decode response (ray id %s): %s", rayID, err)
 	}
 
-	if !updateImageResp.Success {
-		return fmt.Errorf("update image response not successful")
+	if !envelope.Success {
+		return &ResponseError{RayID: rayID, Errors: envelope.Errors}
+	}
+
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return fmt.Errorf("could not decode response (ray id %s): %s", rayID, err)
 	}
 	return nil
 }
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an http date.
+func parseRetryAfter(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(s); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+
+	if t, err := http.ParseTime(s); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,237 @@
+// Package cloudflaremock provides a mock of the Cloudflare Images API client,
+// to test code using cloudflareclient.CloudflareImagesAPI without the network.
+package cloudflaremock
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// Client is a mock of cloudflareclient.CloudflareImagesAPI. Every method calls
+// the function of the same name and records the call, methods whose function
+// isn't set return an error. It is safe for concurrent use.
+type Client struct {
+	ListImagesFunc           func(ctx context.Context) ([]cloudflareclient.Image, error)
+	ImagesFunc               func(ctx context.Context) iter.Seq2[cloudflareclient.Image, error]
+	GetUnprotectedImagesFunc func(ctx context.Context) ([]string, error)
+	GetImageFunc             func(ctx context.Context, imageID string) (*cloudflareclient.Image, error)
+	SecureImageFunc          func(ctx context.Context, imageID string) error
+	SecureImagesFunc         func(ctx context.Context, imageIDs []string, concurrency int) (*cloudflareclient.RunResult, error)
+	SetRequireSignedURLsFunc func(ctx context.Context, imageID string, requireSignedURLs bool) error
+	UpdateImageFunc          func(ctx context.Context, imageID string, update cloudflareclient.ImageUpdate) error
+	DeleteImageFunc          func(ctx context.Context, imageID string) error
+	GetStatsFunc             func(ctx context.Context) (*cloudflareclient.Stats, error)
+	VerifyTokenFunc          func(ctx context.Context) (*cloudflareclient.Token, error)
+	PreflightFunc            func(ctx context.Context, checkWrite bool) error
+	GetAccountFunc           func(ctx context.Context) (*cloudflareclient.Account, error)
+	SigningKeysFunc          func(ctx context.Context) ([]cloudflareclient.SigningKey, error)
+	CreateSigningKeyFunc     func(ctx context.Context, name string) (*cloudflareclient.SigningKey, error)
+	ListVariantsFunc         func(ctx context.Context) ([]cloudflareclient.Variant, error)
+	CreateVariantFunc        func(ctx context.Context, variant cloudflareclient.Variant) (*cloudflareclient.Variant, error)
+	UpdateVariantFunc        func(ctx context.Context, variant cloudflareclient.Variant) (*cloudflareclient.Variant, error)
+	AuditLogsFunc            func(ctx context.Context, since time.Time) iter.Seq2[cloudflareclient.AuditLog, error]
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ cloudflareclient.CloudflareImagesAPI = (*Client)(nil)
+
+// Call is a recorded method call.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// Calls returns the calls made so far, in order.
+func (c *Client) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// CallsTo returns the calls made so far to the method, in order.
+func (c *Client) CallsTo(method string) []Call {
+	var calls []Call
+	for _, call := range c.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (c *Client) record(method string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, Call{Method: method, Args: args})
+}
+
+func notSet(method string) error {
+	return fmt.Errorf("cloudflaremock: %sFunc not set", method)
+}
+
+func (c *Client) ListImages(ctx context.Context) ([]cloudflareclient.Image, error) {
+	c.record("ListImages")
+	if c.ListImagesFunc == nil {
+		return nil, notSet("ListImages")
+	}
+	return c.ListImagesFunc(ctx)
+}
+
+func (c *Client) Images(ctx context.Context) iter.Seq2[cloudflareclient.Image, error] {
+	c.record("Images")
+	if c.ImagesFunc == nil {
+		return func(yield func(cloudflareclient.Image, error) bool) {
+			yield(cloudflareclient.Image{}, notSet("Images"))
+		}
+	}
+	return c.ImagesFunc(ctx)
+}
+
+func (c *Client) GetUnprotectedImages(ctx context.Context) ([]string, error) {
+	c.record("GetUnprotectedImages")
+	if c.GetUnprotectedImagesFunc == nil {
+		return nil, notSet("GetUnprotectedImages")
+	}
+	return c.GetUnprotectedImagesFunc(ctx)
+}
+
+func (c *Client) GetImage(ctx context.Context, imageID string) (*cloudflareclient.Image, error) {
+	c.record("GetImage", imageID)
+	if c.GetImageFunc == nil {
+		return nil, notSet("GetImage")
+	}
+	return c.GetImageFunc(ctx, imageID)
+}
+
+func (c *Client) SecureImage(ctx context.Context, imageID string) error {
+	c.record("SecureImage", imageID)
+	if c.SecureImageFunc == nil {
+		return notSet("SecureImage")
+	}
+	return c.SecureImageFunc(ctx, imageID)
+}
+
+func (c *Client) SecureImages(ctx context.Context, imageIDs []string, concurrency int) (*cloudflareclient.RunResult, error) {
+	c.record("SecureImages", imageIDs, concurrency)
+	if c.SecureImagesFunc == nil {
+		res := cloudflareclient.RunResult{}
+		for _, id := range imageIDs {
+			res.Images = append(res.Images, cloudflareclient.ImageResult{ImageID: id, Err: notSet("SecureImages")})
+		}
+		return &res, res.Err()
+	}
+	return c.SecureImagesFunc(ctx, imageIDs, concurrency)
+}
+
+func (c *Client) SetRequireSignedURLs(ctx context.Context, imageID string, requireSignedURLs bool) error {
+	c.record("SetRequireSignedURLs", imageID, requireSignedURLs)
+	if c.SetRequireSignedURLsFunc == nil {
+		return notSet("SetRequireSignedURLs")
+	}
+	return c.SetRequireSignedURLsFunc(ctx, imageID, requireSignedURLs)
+}
+
+func (c *Client) UpdateImage(ctx context.Context, imageID string, update cloudflareclient.ImageUpdate) error {
+	c.record("UpdateImage", imageID, update)
+	if c.UpdateImageFunc == nil {
+		return notSet("UpdateImage")
+	}
+	return c.UpdateImageFunc(ctx, imageID, update)
+}
+
+func (c *Client) DeleteImage(ctx context.Context, imageID string) error {
+	c.record("DeleteImage", imageID)
+	if c.DeleteImageFunc == nil {
+		return notSet("DeleteImage")
+	}
+	return c.DeleteImageFunc(ctx, imageID)
+}
+
+func (c *Client) GetStats(ctx context.Context) (*cloudflareclient.Stats, error) {
+	c.record("GetStats")
+	if c.GetStatsFunc == nil {
+		return nil, notSet("GetStats")
+	}
+	return c.GetStatsFunc(ctx)
+}
+
+func (c *Client) VerifyToken(ctx context.Context) (*cloudflareclient.Token, error) {
+	c.record("VerifyToken")
+	if c.VerifyTokenFunc == nil {
+		return nil, notSet("VerifyToken")
+	}
+	return c.VerifyTokenFunc(ctx)
+}
+
+func (c *Client) Preflight(ctx context.Context, checkWrite bool) error {
+	c.record("Preflight", checkWrite)
+	if c.PreflightFunc == nil {
+		return notSet("Preflight")
+	}
+	return c.PreflightFunc(ctx, checkWrite)
+}
+
+func (c *Client) GetAccount(ctx context.Context) (*cloudflareclient.Account, error) {
+	c.record("GetAccount")
+	if c.GetAccountFunc == nil {
+		return nil, notSet("GetAccount")
+	}
+	return c.GetAccountFunc(ctx)
+}
+
+func (c *Client) SigningKeys(ctx context.Context) ([]cloudflareclient.SigningKey, error) {
+	c.record("SigningKeys")
+	if c.SigningKeysFunc == nil {
+		return nil, notSet("SigningKeys")
+	}
+	return c.SigningKeysFunc(ctx)
+}
+
+func (c *Client) CreateSigningKey(ctx context.Context, name string) (*cloudflareclient.SigningKey, error) {
+	c.record("CreateSigningKey", name)
+	if c.CreateSigningKeyFunc == nil {
+		return nil, notSet("CreateSigningKey")
+	}
+	return c.CreateSigningKeyFunc(ctx, name)
+}
+
+func (c *Client) ListVariants(ctx context.Context) ([]cloudflareclient.Variant, error) {
+	c.record("ListVariants")
+	if c.ListVariantsFunc == nil {
+		return nil, notSet("ListVariants")
+	}
+	return c.ListVariantsFunc(ctx)
+}
+
+func (c *Client) CreateVariant(ctx context.Context, variant cloudflareclient.Variant) (*cloudflareclient.Variant, error) {
+	c.record("CreateVariant", variant)
+	if c.CreateVariantFunc == nil {
+		return nil, notSet("CreateVariant")
+	}
+	return c.CreateVariantFunc(ctx, variant)
+}
+
+func (c *Client) UpdateVariant(ctx context.Context, variant cloudflareclient.Variant) (*cloudflareclient.Variant, error) {
+	c.record("UpdateVariant", variant)
+	if c.UpdateVariantFunc == nil {
+		return nil, notSet("UpdateVariant")
+	}
+	return c.UpdateVariantFunc(ctx, variant)
+}
+
+func (c *Client) AuditLogs(ctx context.Context, since time.Time) iter.Seq2[cloudflareclient.AuditLog, error] {
+	c.record("AuditLogs", since)
+	if c.AuditLogsFunc == nil {
+		return func(yield func(cloudflareclient.AuditLog, error) bool) {
+			yield(cloudflareclient.AuditLog{}, notSet("AuditLogs"))
+		}
+	}
+	return c.AuditLogsFunc(ctx, since)
+}
@@ -0,0 +1,754 @@
+// Package cloudflaretest provides a fake Cloudflare Images API server keeping the
+// images in memory, to test code using cloudflareclient against real http requests.
+package cloudflaretest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// Server is a fake Cloudflare Images API serving the images of a single account.
+// It implements listing the images with the v1 and v2 listings, getting the details of an image,
+// updating and deleting an image, the usage statistics, verifying the API token, the batch API
+// with its batch tokens, the audit log, getting the account, listing, creating and rotating the signing
+// keys, and listing, creating and updating the variants.
+// Latency and a rate limit can be simulated.
+type Server struct {
+	// BaseURL is the API base URL of the server, to give to cloudflareclient.WithBaseURL.
+	BaseURL string
+	// BatchURL is the batch API base URL of the server, to give to cloudflareclient.WithBatchToken.
+	BatchURL string
+
+	accountID string
+	token     string
+	srv       *httptest.Server
+
+	mu       sync.Mutex
+	images   map[string]*cloudflareclient.Image
+	order    []string
+	failures []*Failure
+	requests []Request
+	// auditLogs are the entries of the audit log, oldest first.
+	auditLogs []cloudflareclient.AuditLog
+	// signingKeys are the keys signing the URLs of the images.
+	signingKeys []cloudflareclient.SigningKey
+	// keyRotations is the number of signing keys created or rotated, for their values to differ.
+	keyRotations int
+	// variants are the variants of the images, by id.
+	variants map[string]cloudflareclient.Variant
+	// batchTokens are the batch tokens handed out, with their expiry.
+	batchTokens map[string]time.Time
+	allowed     int
+	// latency delays every response.
+	latency time.Duration
+	// rateLimit requests are allowed per rateWindow, counted from windowStart.
+	rateLimit   int
+	rateWindow  time.Duration
+	windowStart time.Time
+	windowCount int
+}
+
+// Failure makes the requests matching it fail with an error response.
+type Failure struct {
+	// Method and ImageID select the failing requests, empty to match any.
+	Method  string
+	ImageID string
+	// StatusCode is the status code of the error response.
+	StatusCode int
+	// RetryAfter sets the Retry-After header of the error response if not zero.
+	RetryAfter time.Duration
+	// Times is the number of requests to fail, 0 to fail every matching request.
+	Times int
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Method  string
+	Path    string
+	ImageID string
+}
+
+// NewServer starts a fake server for the account holding the images,
+// authenticating the requests with token if not empty, given either as an API token
+// or as a global API key. Close it when done.
+func NewServer(accountID, token string, images ...cloudflareclient.Image) *Server {
+	s := Server{
+		accountID:   accountID,
+		token:       token,
+		images:      map[string]*cloudflareclient.Image{},
+		batchTokens: map[string]time.Time{},
+		allowed:     DefaultAllowance,
+		signingKeys: []cloudflareclient.SigningKey{DefaultSigningKey},
+		variants:    map[string]cloudflareclient.Variant{DefaultVariant.ID: DefaultVariant},
+	}
+
+	for _, img := range images {
+		s.AddImage(img)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, 7000, "No route for that URI")
+	})
+	mux.HandleFunc("GET /client/v4/user/tokens/verify", s.verifyToken)
+	mux.HandleFunc("GET /client/v4/accounts/{account}/images/v1", s.listImages)
+	mux.HandleFunc("GET /client/v4/accounts/{account}/images/v2", s.listImagesV2)
+	mux.HandleFunc("GET /client/v4/accounts/{account}/images/v1/stats", s.stats)
+	mux.HandleFunc("GET /client/v4/accounts/{account}/images/v1/{id}", s.getImage)
+	mux.HandleFunc("PATCH /client/v4/accounts/{account}/images/v1/{id}", s.updateImage)
+	mux.HandleFunc("DELETE /client/v4/accounts/{account}/images/v1/{id}", s.deleteImage)
+	mux.HandleFunc("POST /client/v4/accounts/{account}/images/v1/batch_token", s.createBatchToken)
+	mux.HandleFunc("GET /client/v4/accounts/{account}/audit_logs", s.listAuditLogs)
+	mux.HandleFunc("GET /client/v4/accounts/{account}", s.getAccount)
+	mux.HandleFunc("GET /client/v4/accounts/{account}/images/v1/keys", s.listSigningKeys)
+	mux.HandleFunc("PUT /client/v4/accounts/{account}/images/v1/keys/{name}", s.putSigningKey)
+	mux.HandleFunc("GET /client/v4/accounts/{account}/images/v1/variants", s.listVariants)
+	mux.HandleFunc("POST /client/v4/accounts/{account}/images/v1/variants", s.createVariant)
+	mux.HandleFunc("PATCH /client/v4/accounts/{account}/images/v1/variants/{id}", s.updateVariant)
+
+	// The batch api serves the images endpoints without the account.
+	mux.HandleFunc("GET /batch/images/v1", s.listImages)
+	mux.HandleFunc("GET /batch/images/v2", s.listImagesV2)
+	mux.HandleFunc("GET /batch/images/v1/{id}", s.getImage)
+	mux.HandleFunc("PATCH /batch/images/v1/{id}", s.updateImage)
+	mux.HandleFunc("DELETE /batch/images/v1/{id}", s.deleteImage)
+
+	s.srv = httptest.NewServer(s.middleware(mux))
+	s.BaseURL = s.srv.URL + "/client/v4"
+	s.BatchURL = s.srv.URL + "/batch"
+	return &s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns a client for the account of the server, configured by the options.
+func (s *Server) Client(opts ...cloudflareclient.Option) *cloudflareclient.Client {
+	opts = append([]cloudflareclient.Option{
+		cloudflareclient.WithAPIToken(s.token),
+		cloudflareclient.WithBaseURL(s.BaseURL),
+		cloudflareclient.WithHTTPClient(s.srv.Client()),
+	}, opts...)
+	return cloudflareclient.New(s.accountID, opts...)
+}
+
+// DefaultAllowance is the number of images the plan of the account allows, unless set with SetAllowance.
+const DefaultAllowance = 100000
+
+// SetAllowance sets the number of images the plan of the account allows, reported by the usage statistics.
+func (s *Server) SetAllowance(allowed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowed = allowed
+}
+
+// DefaultSigningKey is the signing key of the account, unless set with SetSigningKeys.
+var DefaultSigningKey = cloudflareclient.SigningKey{Name: "default", Value: "fake-signing-key"}
+
+// SetSigningKeys sets the keys signing the URLs of the images, none for an account without any.
+func (s *Server) SetSigningKeys(keys ...cloudflareclient.SigningKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signingKeys = keys
+}
+
+// DefaultVariant is the variant of the images, unless set with SetVariants.
+var DefaultVariant = cloudflareclient.Variant{ID: "public", Options: cloudflareclient.VariantOptions{Fit: "scale-down", Metadata: "none", Width: 1366, Height: 768}}
+
+// AccountHash is the account hash of the delivery URLs of the images, listed in their variants.
+const AccountHash = "fake-account-hash"
+
+// SetVariants sets the variants of the images.
+func (s *Server) SetVariants(variants ...cloudflareclient.Variant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.variants = map[string]cloudflareclient.Variant{}
+	for _, v := range variants {
+		s.variants[v.ID] = v
+	}
+}
+
+// SetLatency delays every response by d, to simulate the latency of the API.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetRateLimit rejects the requests but the batch api ones past n per window with a 429 and a Retry-After header
+// telling when the window ends, like the API allowing 1200 requests per 5 minutes.
+// A zero n removes the limit.
+func (s *Server) SetRateLimit(n int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit, s.rateWindow = n, window
+	s.windowStart, s.windowCount = time.Time{}, 0
+}
+
+// rateLimited counts the request against the rate limit, returning how long until the
+// window ends if it is over the limit.
+func (s *Server) rateLimited() (time.Duration, bool) {
+	if s.rateLimit <= 0 {
+		return 0, false
+	}
+
+	now := time.Now()
+	if now.Sub(s.windowStart) >= s.rateWindow {
+		s.windowStart, s.windowCount = now, 0
+	}
+
+	s.windowCount++
+	if s.windowCount <= s.rateLimit {
+		return 0, false
+	}
+	return s.rateWindow - now.Sub(s.windowStart), true
+}
+
+// AddImage adds the image to the account, or replaces the image with the same id.
+func (s *Server) AddImage(img cloudflareclient.Image) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.images[img.ID]; !ok {
+		s.order = append(s.order, img.ID)
+	}
+	s.images[img.ID] = &img
+}
+
+// Image returns the current state of the image.
+func (s *Server) Image(id string) (cloudflareclient.Image, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	img, ok := s.images[id]
+	if !ok {
+		return cloudflareclient.Image{}, false
+	}
+	return *img, true
+}
+
+// Images returns the current state of the images, in the order they were added.
+func (s *Server) Images() []cloudflareclient.Image {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	images := make([]cloudflareclient.Image, 0, len(s.order))
+	for _, id := range s.order {
+		images = append(images, *s.images[id])
+	}
+	return images
+}
+
+// AddAuditLog adds the entry to the audit log of the account, kept sorted by time.
+func (s *Server) AddAuditLog(l cloudflareclient.AuditLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, _ := slices.BinarySearchFunc(s.auditLogs, l.When, func(e cloudflareclient.AuditLog, t time.Time) int {
+		return e.When.Compare(t)
+	})
+	s.auditLogs = slices.Insert(s.auditLogs, i, l)
+}
+
+// Fail makes the requests matching f fail, before the failures added earlier.
+func (s *Server) Fail(f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append([]*Failure{&f}, s.failures...)
+}
+
+// ExpireBatchTokens makes the batch tokens handed out so far expire.
+func (s *Server) ExpireBatchTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.batchTokens)
+}
+
+// Requests returns the requests received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// middleware records the requests, checks the token and injects the failures.
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := Request{Method: r.Method, Path: r.URL.Path, ImageID: imageID(r.URL.EscapedPath())}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		rayID := fmt.Sprintf("%016x-FAKE", len(s.requests))
+		failure := s.failure(req)
+		latency := s.latency
+		// Like the API, the batch api isn't rate limited.
+		var (
+			retryAfter time.Duration
+			limited    bool
+		)
+		if !strings.HasPrefix(r.URL.Path, "/batch/") {
+			retryAfter, limited = s.rateLimited()
+		}
+		s.mu.Unlock()
+
+		w.Header().Set("CF-Ray", rayID)
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if limited {
+			w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+			writeError(w, http.StatusTooManyRequests, 10429, http.StatusText(http.StatusTooManyRequests))
+			return
+		}
+
+		if (s.token != "" || strings.HasPrefix(r.URL.Path, "/batch/")) && !s.authenticated(r) {
+			writeError(w, http.StatusUnauthorized, 10000, "Authentication error")
+			return
+		}
+
+		if failure != nil {
+			if failure.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(failure.RetryAfter.Round(time.Second)/time.Second)))
+			}
+			writeError(w, failure.StatusCode, 10000+failure.StatusCode, http.StatusText(failure.StatusCode))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticated tells whether the request carries the token, as an API token
+// or as the global API key of any user, or a valid batch token for the batch api.
+func (s *Server) authenticated(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/batch/") {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		expiresAt, ok := s.batchTokens[token]
+		return ok && time.Now().Before(expiresAt)
+	}
+
+	if r.Header.Get("X-Auth-Key") != "" {
+		return r.Header.Get("X-Auth-Email") != "" && r.Header.Get("X-Auth-Key") == s.token
+	}
+	return r.Header.Get("Authorization") == "Bearer "+s.token
+}
+
+// failure returns the failure matching the request and counts it down, if any.
+func (s *Server) failure(req Request) *Failure {
+	for i, f := range s.failures {
+		if (f.Method != "" && f.Method != req.Method) || (f.ImageID != "" && f.ImageID != req.ImageID) {
+			continue
+		}
+
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.failures = append(s.failures[:i:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *Server) verifyToken(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Auth-Key") != "" {
+		writeError(w, http.StatusBadRequest, 1000, "Invalid API Token")
+		return
+	}
+	writeResult(w, map[string]any{"id": "fake-token", "status": "active"})
+}
+
+func (s *Server) listImages(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	page, perPage := intParam(r, "page", 1), intParam(r, "per_page", 100)
+	if page < 1 || perPage < 1 {
+		writeError(w, http.StatusBadRequest, 5400, "Bad request: invalid pagination")
+		return
+	}
+
+	images := s.Images()
+	start := min((page-1)*perPage, len(images))
+	end := min(start+perPage, len(images))
+
+	writeResult(w, map[string]any{"images": images[start:end]})
+}
+
+// listImagesV2 pages through the images in the order they were added,
+// the continuation token being the offset of the next page.
+func (s *Server) listImagesV2(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	perPage := intParam(r, "per_page", 1000)
+	if perPage < 10 || perPage > 10000 {
+		writeError(w, http.StatusBadRequest, 5400, "Bad request: per_page must be between 10 and 10000")
+		return
+	}
+
+	start := 0
+	if token := r.URL.Query().Get("continuation_token"); token != "" {
+		n, err := strconv.Atoi(token)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, 5400, "Bad request: invalid continuation token")
+			return
+		}
+		start = n
+	}
+
+	images := s.Images()
+	start = min(start, len(images))
+	end := min(start+perPage, len(images))
+
+	var next *string
+	if end < len(images) {
+		token := strconv.Itoa(end)
+		next = &token
+	}
+
+	writeResult(w, map[string]any{"images": images[start:end], "continuation_token": next})
+}
+
+func (s *Server) getImage(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	img, ok := s.Image(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, 5404, "Image not found")
+		return
+	}
+
+	// The details of the images list the delivery URLs of their variants.
+	if img.Variants == nil {
+		s.mu.Lock()
+		for _, id := range slices.Sorted(maps.Keys(s.variants)) {
+			img.Variants = append(img.Variants, fmt.Sprintf("https://imagedelivery.net/%s/%s/%s", AccountHash, img.ID, id))
+		}
+		s.mu.Unlock()
+	}
+	writeResult(w, img)
+}
+
+func (s *Server) updateImage(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	var update struct {
+		RequireSignedURLs *bool          `json:"requireSignedURLs"`
+		Metadata          map[string]any `json:"metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, 5400, "Bad request: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	img, ok := s.images[r.PathValue("id")]
+	if ok && img.Draft {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, 5409, "Image upload is not completed")
+		return
+	}
+
+	if ok {
+		if update.RequireSignedURLs != nil {
+			img.RequireSignedURLs = *update.RequireSignedURLs
+		}
+		if update.Metadata != nil {
+			img.Meta = update.Metadata
+		}
+	}
+
+	var result cloudflareclient.Image
+	if ok {
+		result = *img
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, 5404, "Image not found")
+		return
+	}
+	writeResult(w, result)
+}
+
+func (s *Server) deleteImage(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	_, ok := s.images[id]
+	if ok {
+		delete(s.images, id)
+		s.order = slices.DeleteFunc(s.order, func(other string) bool { return other == id })
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, 5404, "Image not found")
+		return
+	}
+	writeResult(w, map[string]any{})
+}
+
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	count := cloudflareclient.Stats{Current: len(s.images), Allowed: s.allowed}
+	s.mu.Unlock()
+
+	writeResult(w, map[string]any{"count": count})
+}
+
+// listAuditLogs pages through the entries of the audit log since the given time, oldest first.
+func (s *Server) listAuditLogs(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	page, perPage := intParam(r, "page", 1), intParam(r, "per_page", 100)
+	if page < 1 || perPage < 1 || perPage > 1000 {
+		writeError(w, http.StatusBadRequest, 5400, "Bad request: invalid pagination")
+		return
+	}
+
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, 5400, "Bad request: invalid since")
+			return
+		}
+		since = t
+	}
+
+	s.mu.Lock()
+	var logs []cloudflareclient.AuditLog
+	for _, l := range s.auditLogs {
+		if !l.When.Before(since) {
+			logs = append(logs, l)
+		}
+	}
+	s.mu.Unlock()
+
+	if r.URL.Query().Get("direction") != "asc" {
+		slices.Reverse(logs)
+	}
+
+	start := min((page-1)*perPage, len(logs))
+	end := min(start+perPage, len(logs))
+	writeResult(w, append([]cloudflareclient.AuditLog{}, logs[start:end]...))
+}
+
+func (s *Server) getAccount(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+	writeResult(w, cloudflareclient.Account{ID: s.accountID, Name: "Fake account"})
+}
+
+func (s *Server) listSigningKeys(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	keys := append([]cloudflareclient.SigningKey{}, s.signingKeys...)
+	s.mu.Unlock()
+
+	writeResult(w, map[string]any{"keys": keys})
+}
+
+func (s *Server) listVariants(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	variants := maps.Clone(s.variants)
+	s.mu.Unlock()
+
+	writeResult(w, map[string]any{"variants": variants})
+}
+
+// putSigningKey creates the signing key, or rotates it, its value changing every time.
+func (s *Server) putSigningKey(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keyRotations++
+	key := cloudflareclient.SigningKey{Name: r.PathValue("name"), Value: fmt.Sprintf("fake-signing-key-%d", s.keyRotations)}
+	if i := slices.IndexFunc(s.signingKeys, func(k cloudflareclient.SigningKey) bool { return k.Name == key.Name }); i >= 0 {
+		s.signingKeys[i] = key
+	} else {
+		s.signingKeys = append(s.signingKeys, key)
+	}
+	writeResult(w, map[string]any{"keys": s.signingKeys})
+}
+
+func (s *Server) createVariant(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	var v cloudflareclient.Variant
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil || v.ID == "" {
+		writeError(w, http.StatusBadRequest, 5400, "Bad request: a variant id is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.variants[v.ID]; ok {
+		writeError(w, http.StatusConflict, 5409, "Variant already exists")
+		return
+	}
+	s.variants[v.ID] = v
+	writeResult(w, map[string]any{"variant": v})
+}
+
+func (s *Server) updateVariant(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	var update struct {
+		Options                cloudflareclient.VariantOptions `json:"options"`
+		NeverRequireSignedURLs bool                            `json:"neverRequireSignedURLs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, http.StatusBadRequest, 5400, "Bad request: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.variants[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, 5404, "Variant not found")
+		return
+	}
+	v.Options, v.NeverRequireSignedURLs = update.Options, update.NeverRequireSignedURLs
+	s.variants[v.ID] = v
+	writeResult(w, map[string]any{"variant": v})
+}
+
+func (s *Server) createBatchToken(w http.ResponseWriter, r *http.Request) {
+	if !s.checkAccount(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	token := fmt.Sprintf("batch-%d", len(s.requests))
+	expiresAt := time.Now().Add(30 * time.Minute)
+	s.batchTokens[token] = expiresAt
+	s.mu.Unlock()
+
+	writeResult(w, map[string]any{"token": token, "expiresAt": expiresAt})
+}
+
+// checkAccount checks the request is for the account of the server, batch api requests being for it.
+func (s *Server) checkAccount(w http.ResponseWriter, r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/batch/") {
+		return true
+	}
+
+	if r.PathValue("account") != s.accountID {
+		writeError(w, http.StatusForbidden, 7003, "Could not route to /accounts/"+r.PathValue("account"))
+		return false
+	}
+	return true
+}
+
+// imageID returns the image id of an image path, empty for other paths.
+func imageID(escapedPath string) string {
+	// /client/v4/accounts/{account}/images/v1/{id} or /batch/images/v1/{id}
+	parts := strings.Split(escapedPath, "/")
+	if len(parts) == 5 && parts[1] == "batch" {
+		parts = append([]string{"", "client", "v4", "accounts", ""}, parts[2:]...)
+	}
+
+	if len(parts) != 8 || parts[5] != "images" || parts[6] != "v1" || parts[7] == "batch_token" || parts[7] == "stats" || parts[7] == "keys" || parts[7] == "variants" {
+		return ""
+	}
+
+	id, err := url.PathUnescape(parts[7])
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+func intParam(r *http.Request, name string, def int) int {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func writeResult(w http.ResponseWriter, result any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  true,
+		"errors":   []any{},
+		"messages": []any{},
+		"result":   result,
+	})
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  false,
+		"errors":   []any{map[string]any{"code": code, "message": message}},
+		"messages": []any{},
+		"result":   nil,
+	})
+}
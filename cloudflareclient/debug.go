@@ -0,0 +1,47 @@
+package cloudflareclient
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// redacted replaces the values of the headers carrying credentials in the debug logs.
+const redacted = "[redacted]"
+
+func (c *Client) logRequest(req *http.Request, info RequestInfo, result RequestResult) {
+	attrs := []slog.Attr{
+		slog.String("operation", info.Operation),
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.String()),
+		slog.Int("attempt", info.Attempt),
+		slog.Duration("latency", result.Duration),
+		slog.Any("headers", sanitizeHeaders(req.Header)),
+	}
+
+	if result.Err != nil {
+		attrs = append(attrs, slog.String("error", result.Err.Error()))
+		c.logger.LogAttrs(req.Context(), slog.LevelDebug, "cloudflare api request failed", attrs...)
+		return
+	}
+
+	attrs = append(attrs, slog.Int("status", result.StatusCode), slog.String("ray_id", result.RayID))
+	c.logger.LogAttrs(req.Context(), slog.LevelDebug, "cloudflare api request", attrs...)
+}
+
+// sanitizeHeaders returns the headers with the values of the ones that may carry
+// credentials redacted: the authentication headers and the ones named like secrets.
+func sanitizeHeaders(h http.Header) map[string]string {
+	sanitized := make(map[string]string, len(h))
+	for k, values := range h {
+		v := strings.Join(values, ", ")
+
+		switch name := strings.ToLower(k); {
+		case name == "authorization", name == "cookie", strings.HasPrefix(name, "x-auth-"),
+			strings.Contains(name, "secret"), strings.Contains(name, "token"), strings.Contains(name, "key"):
+			v = redacted
+		}
+		sanitized[k] = v
+	}
+	return sanitized
+}
@@ -0,0 +1,98 @@
+package cloudflareclient
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxErrorBodySize is how much of the response body is kept in errors.
+const maxErrorBodySize = 512
+
+// StatusError is returned when Cloudflare responds with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+	// RayID is the CF-Ray header of the response, to reference the request in support tickets.
+	RayID string
+	// Body is the beginning of the response body.
+	Body string
+	// RetryAfter is the delay asked for by the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+	if e.RayID != "" {
+		msg += fmt.Sprintf(" (ray id %s)", e.RayID)
+	}
+
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// ResponseError is returned when Cloudflare responds with success set to false.
+type ResponseError struct {
+	RayID  string
+	Errors []ResponseErrorDetail
+}
+
+// ResponseErrorDetail is one of the errors listed in an unsuccessful response.
+type ResponseErrorDetail struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	msg := "response not successful"
+	if e.RayID != "" {
+		msg += fmt.Sprintf(" (ray id %s)", e.RayID)
+	}
+
+	for i, d := range e.Errors {
+		sep := ", "
+		if i == 0 {
+			sep = ": "
+		}
+		msg += fmt.Sprintf("%s%d %s", sep, d.Code, d.Message)
+	}
+	return msg
+}
+
+// AttemptsError is returned when a request still failed after being retried.
+type AttemptsError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *AttemptsError) Error() string {
+	return fmt.Sprintf("%s (after %d attempts)", e.Err, e.Attempts)
+}
+
+func (e *AttemptsError) Unwrap() error {
+	return e.Err
+}
+
+// Attempts returns how many times the request that failed with the error was attempted.
+func Attempts(err error) int {
+	var attemptsErr *AttemptsError
+	if errors.As(err, &attemptsErr) {
+		return attemptsErr.Attempts
+	}
+	return 1
+}
+
+// ImageError is the error of an image failing in a bulk operation.
+type ImageError struct {
+	ImageID string
+	Err     error
+}
+
+func (e *ImageError) Error() string {
+	return fmt.Sprintf("image '%s': %s", e.ImageID, e.Err)
+}
+
+func (e *ImageError) Unwrap() error {
+	return e.Err
+}
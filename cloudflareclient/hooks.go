@@ -0,0 +1,72 @@
+package cloudflareclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Hooks are called along the requests of the client, for the applications embedding it
+// to record their own metrics or telemetry. Any of them can be nil. They are called
+// from the goroutines making the requests, concurrently, and should return quickly.
+type Hooks struct {
+	// OnRequestStart is called before every attempt of a request is sent.
+	OnRequestStart func(ctx context.Context, info RequestInfo)
+	// OnRequestEnd is called once the response to the attempt is received, or it failed to be sent.
+	OnRequestEnd func(ctx context.Context, info RequestInfo, result RequestResult)
+	// OnRetry is called before waiting for delay to retry the request whose attempt failed with err.
+	OnRetry func(ctx context.Context, info RequestInfo, err error, delay time.Duration)
+}
+
+// RequestInfo describes an attempt of a request to the API.
+type RequestInfo struct {
+	// Operation names the call, e.g. cloudflare.images.update, like the tracing spans.
+	Operation string
+	Method    string
+	// Path is the path of the request relative to the base URL of the API, query included.
+	Path    string
+	Attempt int
+}
+
+// RequestResult is the outcome of an attempt of a request.
+type RequestResult struct {
+	// StatusCode and RayID are the status and CF-Ray header of the response, zero when Err is set.
+	StatusCode int
+	RayID      string
+	Duration   time.Duration
+	// Err is the error sending the request or receiving its response, e.g. a network error.
+	Err error
+}
+
+// WithHooks calls the hooks along the requests of the client.
+func WithHooks(h Hooks) Option {
+	return func(c *Client) {
+		c.hooks = h
+	}
+}
+
+// send sends the request, calling the hooks and logging it along with its response
+// at debug level on the logger of the client, if any.
+func (c *Client) send(req *http.Request, info RequestInfo) (*http.Response, error) {
+	ctx := req.Context()
+	if c.hooks.OnRequestStart != nil {
+		c.hooks.OnRequestStart(ctx, info)
+	}
+
+	start := time.Now()
+	resp, err := c.httpCli.Do(req)
+
+	result := RequestResult{Duration: time.Since(start), Err: err}
+	if err == nil {
+		result.StatusCode, result.RayID = resp.StatusCode, resp.Header.Get("CF-Ray")
+	}
+
+	if c.hooks.OnRequestEnd != nil {
+		c.hooks.OnRequestEnd(ctx, info, result)
+	}
+
+	if c.logger != nil {
+		c.logRequest(req, info, result)
+	}
+	return resp, err
+}
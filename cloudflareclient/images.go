@@ -0,0 +1,272 @@
+package cloudflareclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// The limits of the page sizes of the listings, MaxPageSize being the largest page of the v1
+// listing and MaxV2PageSize the largest of the v2 listing.
+const (
+	MinPageSize   int = 10
+	MaxPageSize   int = 100
+	MaxV2PageSize int = 10000
+)
+
+// Image is an image as returned by the Cloudflare Images API.
+type Image struct {
+	ID                string         `json:"id"`
+	Filename          string         `json:"filename"`
+	Meta              map[string]any `json:"meta"`
+	RequireSignedURLs bool           `json:"requireSignedURLs"`
+	Uploaded          time.Time      `json:"uploaded"`
+	Variants          []string       `json:"variants"`
+	// Draft is set while the image is being uploaded with a direct upload URL.
+	Draft bool `json:"draft"`
+}
+
+type cloudflareResponse struct {
+	Result struct {
+		Images []Image `json:"images"`
+	} `json:"result"`
+}
+
+// ListImages makes requests to cloudflare to list all the images in the account,
+// going through the pages until a page comes back with less images than the page size,
+// or with the v2 listing until no continuation token comes back.
+// https://api.cloudflare.com/#cloudflare-images-list-images
+func (c *Client) ListImages(ctx context.Context) ([]Image, error) {
+	var images []Image
+	for pageImages, err := range c.pages(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, pageImages...)
+	}
+	return images, nil
+}
+
+// Images returns an iterator over the images of the account, fetching the pages as
+// the iteration goes, so that only a page of images is held in memory at a time.
+// An error ends the iteration, yielded with a zero Image.
+func (c *Client) Images(ctx context.Context) iter.Seq2[Image, error] {
+	return func(yield func(Image, error) bool) {
+		for pageImages, err := range c.pages(ctx) {
+			if err != nil {
+				yield(Image{}, err)
+				return
+			}
+
+			for _, img := range pageImages {
+				if !yield(img, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// pages returns an iterator over the pages of images, with the v1 or v2 listing.
+// The v1 pages are fetched by batches of concurrent requests, yielded in order.
+func (c *Client) pages(ctx context.Context) iter.Seq2[[]Image, error] {
+	if c.listV2 {
+		return c.pagesV2(ctx)
+	}
+
+	type pageResult struct {
+		images []Image
+		err    error
+	}
+
+	return func(yield func([]Image, error) bool) {
+		n := max(c.listConcurrency, 1)
+		for first := 1; ; first += n {
+			results := make([]pageResult, n)
+
+			var wg sync.WaitGroup
+			for i := range n {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					images, err := c.listImagesPage(ctx, first+i)
+					results[i] = pageResult{images: images, err: err}
+				}()
+			}
+			wg.Wait()
+
+			for i, r := range results {
+				if r.err != nil {
+					yield(nil, fmt.Errorf("could not list page %d: %w", first+i, r.err))
+					return
+				}
+
+				if !yield(r.images, nil) || len(r.images) < c.pageSizeOf(MaxPageSize) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// pageSizeOf returns the page size of the listing whose largest page is maxSize,
+// the one set with WithPageSize within the limits of the listing, or maxSize.
+func (c *Client) pageSizeOf(maxSize int) int {
+	if c.pageSize == 0 {
+		return maxSize
+	}
+	return min(max(c.pageSize, MinPageSize), maxSize)
+}
+
+func (c *Client) listImagesPage(ctx context.Context, page int) ([]Image, error) {
+	path := fmt.Sprintf("/accounts/%s/images/v1?page=%d&per_page=%d", c.accountID, page, c.pageSizeOf(MaxPageSize))
+
+	var listImagesResp cloudflareResponse
+	if err := c.do(ctx, "cloudflare.images.list", http.MethodGet, path, nil, &listImagesResp, attribute.Int("cloudflare.page", page)); err != nil {
+		return nil, err
+	}
+	return listImagesResp.Result.Images, nil
+}
+
+type listImagesV2Response struct {
+	Result struct {
+		Images            []Image `json:"images"`
+		ContinuationToken *string `json:"continuation_token"`
+	} `json:"result"`
+}
+
+// pagesV2 lists the images with the v2 listing, following the continuation tokens.
+// https://developers.cloudflare.com/api/resources/images/subresources/v2/methods/list/
+func (c *Client) pagesV2(ctx context.Context) iter.Seq2[[]Image, error] {
+	return func(yield func([]Image, error) bool) {
+		var token string
+		for page := 1; ; page++ {
+			query := url.Values{"per_page": {strconv.Itoa(c.pageSizeOf(MaxV2PageSize))}, "sort_order": {"asc"}}
+			if token != "" {
+				query.Set("continuation_token", token)
+			}
+
+			path := fmt.Sprintf("/accounts/%s/images/v2?%s", c.accountID, query.Encode())
+
+			var resp listImagesV2Response
+			if err := c.do(ctx, "cloudflare.images.list", http.MethodGet, path, nil, &resp, attribute.Int("cloudflare.page", page)); err != nil {
+				yield(nil, fmt.Errorf("could not list page %d: %w", page, err))
+				return
+			}
+
+			if !yield(resp.Result.Images, nil) {
+				return
+			}
+
+			if resp.Result.ContinuationToken == nil || *resp.Result.ContinuationToken == "" {
+				return
+			}
+			token = *resp.Result.ContinuationToken
+		}
+	}
+}
+
+// GetUnprotectedImages lists all the images and returns the ids
+// of the ones that have required signed url set to false.
+func (c *Client) GetUnprotectedImages(ctx context.Context) ([]string, error) {
+	images, err := c.ListImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var unprotectedImages []string
+	for _, image := range images {
+		if !image.RequireSignedURLs {
+			unprotectedImages = append(unprotectedImages, image.ID)
+		}
+	}
+	return unprotectedImages, nil
+}
+
+// ErrAlreadySecured is returned by SecureImage when the image requires signed URLs already.
+var ErrAlreadySecured = errors.New("image already requires signed URLs")
+
+// SecureImage makes a request to Cloudflare to update the image to require signed URLs,
+// unless it does already, in which case it returns ErrAlreadySecured without updating it.
+func (c *Client) SecureImage(ctx context.Context, imageID string) error {
+	img, err := c.GetImage(ctx, imageID)
+	if err != nil {
+		return err
+	}
+
+	if img.RequireSignedURLs {
+		return ErrAlreadySecured
+	}
+	return c.SetRequireSignedURLs(ctx, imageID, true)
+}
+
+// GetImage makes a request to Cloudflare to get the details of the image.
+// https://api.cloudflare.com/#cloudflare-images-image-details
+func (c *Client) GetImage(ctx context.Context, imageID string) (*Image, error) {
+	path := fmt.Sprintf("/accounts/%s/images/v1/%s", c.accountID, url.PathEscape(imageID))
+
+	var imageResp struct {
+		Result Image `json:"result"`
+	}
+	if err := c.do(ctx, "cloudflare.images.get", http.MethodGet, path, nil, &imageResp,
+		attribute.String("cloudflare.image_id", imageID),
+	); err != nil {
+		return nil, err
+	}
+	return &imageResp.Result, nil
+}
+
+// SetRequireSignedURLs makes a request to Cloudflare to update whether the image requires signed URLs.
+// https://api.cloudflare.com/#cloudflare-images-update-image
+func (c *Client) SetRequireSignedURLs(ctx context.Context, imageID string, requireSignedURLs bool) error {
+	return c.UpdateImage(ctx, imageID, ImageUpdate{RequireSignedURLs: requireSignedURLs})
+}
+
+// DeleteImage makes a request to Cloudflare to delete the image, for good.
+// https://api.cloudflare.com/#cloudflare-images-delete-image
+func (c *Client) DeleteImage(ctx context.Context, imageID string) error {
+	path := fmt.Sprintf("/accounts/%s/images/v1/%s", c.accountID, url.PathEscape(imageID))
+
+	var deleteImageResp cloudflareResponse
+	return c.do(ctx, "cloudflare.images.delete", http.MethodDelete, path, nil, &deleteImageResp,
+		attribute.String("cloudflare.image_id", imageID),
+	)
+}
+
+// ImageUpdate is an update of an image.
+type ImageUpdate struct {
+	RequireSignedURLs bool `json:"requireSignedURLs"`
+	// Metadata replaces the whole metadata of the image, it is left as it is when nil.
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// UpdateImage makes a request to Cloudflare to update whether the image requires signed URLs,
+// along with its metadata.
+// https://api.cloudflare.com/#cloudflare-images-update-image
+func (c *Client) UpdateImage(ctx context.Context, imageID string, update ImageUpdate) error {
+	path := fmt.Sprintf("/accounts/%s/images/v1/%s", c.accountID, url.PathEscape(imageID))
+
+	reqBody, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("could not encode request body: %s", err)
+	}
+
+	var updateImageResp cloudflareResponse
+	if err := c.do(ctx, "cloudflare.images.update", http.MethodPatch, path, reqBody, &updateImageResp,
+		attribute.String("cloudflare.image_id", imageID),
+		attribute.Bool("cloudflare.require_signed_urls", update.RequireSignedURLs),
+		attribute.Bool("cloudflare.metadata_updated", update.Metadata != nil),
+	); err != nil {
+		return err
+	}
+	return nil
+}
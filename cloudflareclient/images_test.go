@@ -0,0 +1,188 @@
+package cloudflareclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/cloudflareclient/cloudflaretest"
+)
+
+const (
+	testAccountID = "test-account"
+	testToken     = "test-token"
+)
+
+// newImages returns n images, the even ones requiring signed URLs.
+func newImages(n int) []cloudflareclient.Image {
+	images := make([]cloudflareclient.Image, n)
+	for i := range images {
+		images[i] = cloudflareclient.Image{ID: fmt.Sprintf("image-%04d", i), RequireSignedURLs: i%2 == 0}
+	}
+	return images
+}
+
+// countRequests returns the number of requests received by the server whose path ends with suffix.
+func countRequests(srv *cloudflaretest.Server, method, suffix string) int {
+	var n int
+	for _, r := range srv.Requests() {
+		if r.Method == method && strings.HasSuffix(r.Path, suffix) {
+			n++
+		}
+	}
+	return n
+}
+
+func checkListed(t *testing.T, got, want []cloudflareclient.Image) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("listed %d images, want %d", len(got), len(want))
+	}
+
+	for i := range want {
+		if got[i].ID != want[i].ID {
+			t.Fatalf("image %d is %s, want %s", i, got[i].ID, want[i].ID)
+		}
+	}
+}
+
+func TestListImagesV1(t *testing.T) {
+	tests := []struct {
+		name        string
+		images      int
+		concurrency int
+		pageSize    int
+		// pages is the number of pages requested, the ones past the last page of a batch included.
+		pages int
+	}{
+		{name: "single page", images: 42, concurrency: 1, pages: 1},
+		{name: "sequential pages", images: 250, concurrency: 1, pages: 3},
+		{name: "last page full", images: 200, concurrency: 1, pages: 3},
+		{name: "concurrent pages", images: 250, concurrency: 4, pages: 4},
+		{name: "several batches of concurrent pages", images: 95, concurrency: 4, pageSize: 10, pages: 12},
+		{name: "empty account", images: 0, concurrency: 4, pages: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images := newImages(tt.images)
+			srv := cloudflaretest.NewServer(testAccountID, testToken, images...)
+			defer srv.Close()
+
+			cli := srv.Client(cloudflareclient.WithListConcurrency(tt.concurrency), cloudflareclient.WithPageSize(tt.pageSize))
+
+			got, err := cli.ListImages(context.Background())
+			if err != nil {
+				t.Fatalf("ListImages: %s", err)
+			}
+			checkListed(t, got, images)
+
+			if n := countRequests(srv, "GET", "/images/v1"); n != tt.pages {
+				t.Errorf("requested %d pages, want %d", n, tt.pages)
+			}
+		})
+	}
+}
+
+func TestListImagesV2(t *testing.T) {
+	tests := []struct {
+		name     string
+		images   int
+		pageSize int
+		pages    int
+	}{
+		{name: "single page", images: 42, pages: 1},
+		{name: "continuation tokens", images: 25, pageSize: 10, pages: 3},
+		{name: "last page full", images: 20, pageSize: 10, pages: 2},
+		{name: "empty account", images: 0, pages: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images := newImages(tt.images)
+			srv := cloudflaretest.NewServer(testAccountID, testToken, images...)
+			defer srv.Close()
+
+			cli := srv.Client(cloudflareclient.WithImagesV2Listing(), cloudflareclient.WithPageSize(tt.pageSize))
+
+			got, err := cli.ListImages(context.Background())
+			if err != nil {
+				t.Fatalf("ListImages: %s", err)
+			}
+			checkListed(t, got, images)
+
+			if n := countRequests(srv, "GET", "/images/v2"); n != tt.pages {
+				t.Errorf("requested %d pages, want %d", n, tt.pages)
+			}
+		})
+	}
+}
+
+func TestImagesEndsWithError(t *testing.T) {
+	srv := cloudflaretest.NewServer(testAccountID, testToken, newImages(25)...)
+	defer srv.Close()
+
+	cli := srv.Client(cloudflareclient.WithImagesV2Listing(), cloudflareclient.WithPageSize(10))
+
+	var (
+		listed int
+		errs   []error
+	)
+	for img, err := range cli.Images(context.Background()) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		listed++
+		if img.ID == "image-0009" {
+			// The pages are fetched as the iteration goes, the second one fails.
+			srv.Fail(cloudflaretest.Failure{Method: "GET", StatusCode: 500})
+		}
+	}
+
+	if listed != 10 {
+		t.Errorf("listed %d images, want the 10 of the first page", listed)
+	}
+
+	var statusErr *cloudflareclient.StatusError
+	if len(errs) != 1 || !errors.As(errs[0], &statusErr) || statusErr.StatusCode != 500 {
+		t.Errorf("iteration ended with %v, want a single 500", errs)
+	}
+}
+
+func TestSecureImage(t *testing.T) {
+	srv := cloudflaretest.NewServer(testAccountID, testToken,
+		cloudflareclient.Image{ID: "public"},
+		cloudflareclient.Image{ID: "secured", RequireSignedURLs: true},
+	)
+	defer srv.Close()
+
+	cli := srv.Client()
+	ctx := context.Background()
+
+	if err := cli.SecureImage(ctx, "public"); err != nil {
+		t.Fatalf("SecureImage of an unprotected image: %s", err)
+	}
+
+	if img, _ := srv.Image("public"); !img.RequireSignedURLs {
+		t.Error("image doesn't require signed URLs after SecureImage")
+	}
+
+	if err := cli.SecureImage(ctx, "secured"); !errors.Is(err, cloudflareclient.ErrAlreadySecured) {
+		t.Errorf("SecureImage of a secured image = %v, want ErrAlreadySecured", err)
+	}
+
+	if n := countRequests(srv, "PATCH", "/secured"); n != 0 {
+		t.Errorf("secured image updated %d times, want none", n)
+	}
+
+	var statusErr *cloudflareclient.StatusError
+	if err := cli.SecureImage(ctx, "unknown"); !errors.As(err, &statusErr) || statusErr.StatusCode != 404 {
+		t.Errorf("SecureImage of an unknown image = %v, want a 404", err)
+	}
+}
@@ -0,0 +1,54 @@
+package cloudflareclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// SigningKey is a key of the account signing the URLs of the images requiring signed URLs.
+type SigningKey struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SigningKeys makes a request to Cloudflare to list the keys signing the URLs of the images.
+// https://developers.cloudflare.com/api/resources/images/subresources/v1/subresources/keys/methods/list/
+func (c *Client) SigningKeys(ctx context.Context) ([]SigningKey, error) {
+	var keysResp struct {
+		Result struct {
+			Keys []SigningKey `json:"keys"`
+		} `json:"result"`
+	}
+
+	path := fmt.Sprintf("/accounts/%s/images/v1/keys", c.accountID)
+	if err := c.do(ctx, "cloudflare.images.keys.list", http.MethodGet, path, nil, &keysResp); err != nil {
+		return nil, err
+	}
+	return keysResp.Result.Keys, nil
+}
+
+// CreateSigningKey makes a request to Cloudflare to create the signing key with the given name,
+// or to rotate it if it exists already, the URLs signed with its previous value no longer being
+// valid. It returns the key with its new value.
+// https://developers.cloudflare.com/api/resources/images/subresources/v1/subresources/keys/methods/update/
+func (c *Client) CreateSigningKey(ctx context.Context, name string) (*SigningKey, error) {
+	var keysResp struct {
+		Result struct {
+			Keys []SigningKey `json:"keys"`
+		} `json:"result"`
+	}
+
+	path := fmt.Sprintf("/accounts/%s/images/v1/keys/%s", c.accountID, url.PathEscape(name))
+	if err := c.do(ctx, "cloudflare.images.keys.update", http.MethodPut, path, nil, &keysResp); err != nil {
+		return nil, err
+	}
+
+	for _, k := range keysResp.Result.Keys {
+		if k.Name == name {
+			return &k, nil
+		}
+	}
+	return nil, fmt.Errorf("signing key '%s' missing from the response", name)
+}
@@ -0,0 +1,161 @@
+package cloudflareclient
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIToken authenticates the requests with an API token.
+func WithAPIToken(token string) Option {
+	return func(c *Client) {
+		c.apiToken = token
+	}
+}
+
+// WithAPIKey authenticates the requests with the legacy global API key of the user
+// and their email, instead of an API token. API tokens should be preferred, the
+// global key gives access to everything the user can do.
+func WithAPIKey(email, key string) Option {
+	return func(c *Client) {
+		c.authEmail = email
+		c.authKey = key
+	}
+}
+
+// WithHTTPClient sends the requests with the given http client instead of a client
+// of its own with NewTransport.
+func WithHTTPClient(httpCli *http.Client) Option {
+	return func(c *Client) {
+		c.httpCli = httpCli
+	}
+}
+
+// Middleware wraps the transport of the http client to add behavior to every
+// request sent, e.g. logging, metrics, auth injection or recording.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// WithTransportMiddleware wraps the transport of the http client with the middlewares.
+// The first one is the outermost, it sees the requests first and the responses last.
+// Retried requests go through the middlewares on every attempt.
+func WithTransportMiddleware(middlewares ...Middleware) Option {
+	return func(c *Client) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// WithBaseURL sends the requests to the given API base URL instead of
+// DefaultBaseURL, e.g. a mock server, an API gateway or the China network endpoint.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithRequestTimeout cancels each attempt of a request not done within the timeout,
+// reading the response included. A timed out attempt is retried like a network error.
+// Requests aren't timed out by default, other than by their context.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.requestTimeout = timeout
+	}
+}
+
+// WithRetryPolicy retries failed requests according to the policy. Requests aren't retried by default.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		if p.MaxAttempts < 1 {
+			p.MaxAttempts = 1
+		}
+		c.retry = p
+	}
+}
+
+// WithRateLimit spaces the requests to send no more than requestsPerSecond on average,
+// allowing bursts of up to burst requests. Cloudflare allows 1200 requests per 5 minutes, 4 per second.
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return func(c *Client) {
+		if requestsPerSecond > 0 {
+			c.limiter = newLimiter(requestsPerSecond, burst)
+		}
+	}
+}
+
+// WithCircuitBreaker pauses the requests for the cool-down of the breaker once its threshold
+// of consecutive requests fail with a network error or a 5xx status, as during an outage
+// of the API. Requests aren't paused by default.
+func WithCircuitBreaker(cb CircuitBreaker) Option {
+	return func(c *Client) {
+		if cb.Threshold > 0 && cb.CoolDown > 0 {
+			c.breaker = &breaker{cfg: cb}
+		}
+	}
+}
+
+// WithImagesV2Listing lists the images with the v2 listing, paginated with continuation
+// tokens and returning up to 10000 images per page instead of 100, which drastically
+// reduces the number of requests for big accounts.
+func WithImagesV2Listing() Option {
+	return func(c *Client) {
+		c.listV2 = true
+	}
+}
+
+// WithBatchToken sends the images requests through the batch API with a batch token,
+// for bulk runs: they aren't subject to the rate limit of the API, nor to WithRateLimit.
+// batchURL is the base URL of the batch API, DefaultBatchURL if empty. The token is
+// requested with the credentials of the client and renewed before it expires.
+func WithBatchToken(batchURL string) Option {
+	return func(c *Client) {
+		if batchURL == "" {
+			batchURL = DefaultBatchURL
+		}
+		c.batch = &batch{url: strings.TrimRight(batchURL, "/")}
+	}
+}
+
+// WithListConcurrency fetches up to n pages of the v1 listing concurrently, cutting the
+// time to list big accounts. The v2 listing follows continuation tokens one page after
+// the other. Up to n-1 pages past the last one may be requested.
+func WithListConcurrency(n int) Option {
+	return func(c *Client) {
+		c.listConcurrency = n
+	}
+}
+
+// WithPageSize lists the images by pages of n images rather than of the most the listing
+// allows, MaxPageSize with the v1 listing and MaxV2PageSize with v2, trading more requests
+// for smaller responses. n is brought within MinPageSize and the largest page of the listing.
+func WithPageSize(n int) Option {
+	return func(c *Client) {
+		c.pageSize = n
+	}
+}
+
+// WithHeaders adds the headers to every request, e.g. the credentials of a gateway in front
+// of the API such as the service token of Cloudflare Access. The headers set by the client,
+// for authentication and the User-Agent, take precedence.
+func WithHeaders(h http.Header) Option {
+	return func(c *Client) {
+		c.headers = h.Clone()
+	}
+}
+
+// WithLogger logs every attempt of the requests at debug level: the method, url, status,
+// latency, ray id and attempt number, with the values of the credentials headers redacted.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the requests.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
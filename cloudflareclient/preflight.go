@@ -0,0 +1,75 @@
+package cloudflareclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// PermissionError reports a permission the credentials are missing on the account.
+type PermissionError struct {
+	AccountID string
+	// Permission is the name of the missing permission, e.g. "Cloudflare Images Read".
+	Permission string
+	Err        error
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("credentials are missing the %s permission on account %s: %s", e.Permission, e.AccountID, e.Err)
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+// Preflight checks the credentials before the client is used: the API token is
+// verified, and a page of images is listed to check the Cloudflare Images Read
+// permission on the account. When checkWrite is set, the first listed image which
+// isn't a draft is updated to its current state, a no-op checking the Cloudflare
+// Images Write permission.
+// Missing permissions are reported with a PermissionError.
+func (c *Client) Preflight(ctx context.Context, checkWrite bool) error {
+	if c.authKey == "" {
+		if _, err := c.VerifyToken(ctx); err != nil {
+			return fmt.Errorf("failed to verify token: %w", err)
+		}
+	}
+
+	images, err := c.listImagesPage(ctx, 1)
+	if err != nil {
+		if denied(err) {
+			return &PermissionError{AccountID: c.accountID, Permission: "Cloudflare Images Read", Err: err}
+		}
+		return fmt.Errorf("failed to list images: %w", err)
+	}
+
+	if !checkWrite {
+		return nil
+	}
+
+	// Drafts can't be updated. Without other images, the write permission can't be checked.
+	i := slices.IndexFunc(images, func(img Image) bool { return !img.Draft })
+	if i < 0 {
+		return nil
+	}
+
+	img := images[i]
+	if err := c.SetRequireSignedURLs(ctx, img.ID, img.RequireSignedURLs); err != nil {
+		if denied(err) {
+			return &PermissionError{AccountID: c.accountID, Permission: "Cloudflare Images Write", Err: err}
+		}
+		return fmt.Errorf("failed to update image '%s': %w", img.ID, err)
+	}
+	return nil
+}
+
+// denied tells whether the request failed for lack of authentication or permissions.
+func denied(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden
+}
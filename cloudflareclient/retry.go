@@ -0,0 +1,145 @@
+package cloudflareclient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RetryPolicy tells how requests failing with a network error, a 429 or a 5xx status are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent, 1 disables retries.
+	MaxAttempts int
+	// MinDelay is the delay before the first retry, doubled on each following one.
+	MinDelay time.Duration
+	// MaxDelay caps the delay between attempts, including the ones asked for by Retry-After.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy retries requests twice, waiting 1s then 2s, give or take some jitter.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinDelay:    time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// noRetries is the policy of clients not given one.
+var noRetries = RetryPolicy{MaxAttempts: 1}
+
+// delay returns how long to wait before the next attempt, after the given one failed with err.
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	d := p.MinDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+
+	// Up to 20% of jitter so concurrent requests don't retry in lockstep.
+	if d > 0 {
+		d += time.Duration(rand.Int63n(int64(d)/5 + 1))
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > d {
+		d = statusErr.RetryAfter
+	}
+
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// retryable reports whether a request that failed with err may succeed if sent again.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var batchErr *batchTokenRejectedError
+	if errors.As(err, &batchErr) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+
+	var sendErr *sendError
+	return errors.As(err, &sendErr)
+}
+
+// sendError is a failure to send a request or get its response, such as a network error.
+type sendError struct {
+	err error
+}
+
+func (e *sendError) Error() string {
+	return "could not send request: " + e.err.Error()
+}
+
+func (e *sendError) Unwrap() error {
+	return e.err
+}
+
+// limiter is a token bucket spacing the requests to stay within a rate limit.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	tokens   float64
+	last     time.Time
+}
+
+func newLimiter(requestsPerSecond float64, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &limiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+		burst:    burst,
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until a request can be sent or the context is done.
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+
+	// Taking the token right away, possibly going negative, reserves the slot for this request.
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens * float64(l.interval))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	return sleep(ctx, wait)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
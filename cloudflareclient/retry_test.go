@@ -0,0 +1,137 @@
+package cloudflareclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/cloudflareclient/cloudflaretest"
+)
+
+// fastRetries retries the requests without waiting long, but for Retry-After.
+var fastRetries = cloudflareclient.RetryPolicy{MaxAttempts: 3, MinDelay: time.Millisecond, MaxDelay: 5 * time.Second}
+
+// retryRecorder records the delays before the retries of the requests.
+type retryRecorder struct {
+	mu     sync.Mutex
+	delays []time.Duration
+}
+
+func (r *retryRecorder) hooks() cloudflareclient.Hooks {
+	return cloudflareclient.Hooks{
+		OnRetry: func(_ context.Context, _ cloudflareclient.RequestInfo, _ error, delay time.Duration) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.delays = append(r.delays, delay)
+		},
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure cloudflaretest.Failure
+		// attempts is the number of requests sent, and wantStatus the status of the error returned, if any.
+		attempts   int
+		wantStatus int
+	}{
+		{name: "5xx then success", failure: cloudflaretest.Failure{StatusCode: http.StatusServiceUnavailable, Times: 2}, attempts: 3},
+		{name: "429 then success", failure: cloudflaretest.Failure{StatusCode: http.StatusTooManyRequests, Times: 1}, attempts: 2},
+		{name: "5xx past the attempts", failure: cloudflaretest.Failure{StatusCode: http.StatusBadGateway}, attempts: 3, wantStatus: http.StatusBadGateway},
+		{name: "4xx not retried", failure: cloudflaretest.Failure{StatusCode: http.StatusForbidden}, attempts: 1, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := cloudflaretest.NewServer(testAccountID, testToken, cloudflareclient.Image{ID: "image"})
+			defer srv.Close()
+
+			tt.failure.Method = http.MethodGet
+			srv.Fail(tt.failure)
+
+			_, err := srv.Client(cloudflareclient.WithRetryPolicy(fastRetries)).GetImage(context.Background(), "image")
+
+			if n := countRequests(srv, http.MethodGet, "/image"); n != tt.attempts {
+				t.Errorf("sent %d requests, want %d", n, tt.attempts)
+			}
+
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("GetImage: %s", err)
+				}
+				return
+			}
+
+			var statusErr *cloudflareclient.StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+				t.Fatalf("GetImage = %v, want a %d", err, tt.wantStatus)
+			}
+
+			if got := cloudflareclient.Attempts(err); got != tt.attempts {
+				t.Errorf("Attempts = %d, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	srv := cloudflaretest.NewServer(testAccountID, testToken, cloudflareclient.Image{ID: "image"})
+	defer srv.Close()
+
+	srv.Fail(cloudflaretest.Failure{Method: http.MethodPatch, StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second, Times: 1})
+
+	var rec retryRecorder
+	cli := srv.Client(cloudflareclient.WithRetryPolicy(fastRetries), cloudflareclient.WithHooks(rec.hooks()))
+
+	start := time.Now()
+	if err := cli.SetRequireSignedURLs(context.Background(), "image", true); err != nil {
+		t.Fatalf("SetRequireSignedURLs: %s", err)
+	}
+
+	if len(rec.delays) != 1 || rec.delays[0] < time.Second {
+		t.Errorf("retried after %v, want once after the second of Retry-After", rec.delays)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, before the second of Retry-After", elapsed)
+	}
+}
+
+func TestRetryAfterCappedByMaxDelay(t *testing.T) {
+	srv := cloudflaretest.NewServer(testAccountID, testToken, cloudflareclient.Image{ID: "image"})
+	defer srv.Close()
+
+	srv.Fail(cloudflaretest.Failure{Method: http.MethodGet, StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour, Times: 1})
+
+	var rec retryRecorder
+	policy := cloudflareclient.RetryPolicy{MaxAttempts: 2, MinDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	cli := srv.Client(cloudflareclient.WithRetryPolicy(policy), cloudflareclient.WithHooks(rec.hooks()))
+
+	if _, err := cli.GetImage(context.Background(), "image"); err != nil {
+		t.Fatalf("GetImage: %s", err)
+	}
+
+	if len(rec.delays) != 1 || rec.delays[0] > policy.MaxDelay {
+		t.Errorf("retried after %v, want once within MaxDelay %s", rec.delays, policy.MaxDelay)
+	}
+}
+
+func TestRetryStopsWithContext(t *testing.T) {
+	srv := cloudflaretest.NewServer(testAccountID, testToken, cloudflareclient.Image{ID: "image"})
+	defer srv.Close()
+
+	srv.Fail(cloudflaretest.Failure{Method: http.MethodGet, StatusCode: http.StatusServiceUnavailable, RetryAfter: time.Hour})
+
+	policy := cloudflareclient.RetryPolicy{MaxAttempts: 5, MinDelay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := srv.Client(cloudflareclient.WithRetryPolicy(policy)).GetImage(ctx, "image")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetImage = %v, want the deadline exceeded while waiting to retry", err)
+	}
+}
@@ -0,0 +1,180 @@
+package cloudflareclient
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	signingKey  = "XXXX"
+	deliveryURL = "https://imagedelivery.net/ZWd9g1K7eljCn_KDTu_MWA/083eb7b2-5392-4565-b69e-aff66acddd00/public"
+)
+
+// signedAt is the exp of the URLs signed by the tests, 2026-01-01 00:00:00 UTC.
+var signedAt = time.Unix(1767225600, 0).UTC()
+
+func TestSignURL(t *testing.T) {
+	// The signatures are the HMAC-SHA256 of the path and the query with exp appended, as computed
+	// by the worker of Cloudflare's example, here with openssl dgst -sha256 -hmac XXXX.
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{
+			name: "no query",
+			url:  deliveryURL,
+			want: deliveryURL + "?exp=1767225600&sig=44426046781280820dba4ff19880771e3db3054061581ccb8ef6bff7517a34e9",
+		},
+		{
+			name: "query preserved",
+			url:  strings.TrimSuffix(deliveryURL, "public") + "w=400?width=400",
+			want: strings.TrimSuffix(deliveryURL, "public") + "w=400?width=400&exp=1767225600&sig=dc74b264f6d898a97407c3141a80d1b964fb1b1b53f093697716b018fa884017",
+		},
+		{
+			name: "previous signature replaced",
+			url:  deliveryURL + "?exp=1&sig=0123",
+			want: deliveryURL + "?exp=1767225600&sig=44426046781280820dba4ff19880771e3db3054061581ccb8ef6bff7517a34e9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SignURL(tt.url, signingKey, signedAt)
+			if err != nil {
+				t.Fatalf("SignURL: %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("SignURL(%q) =\n%s\nwant\n%s", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignURLInvalid(t *testing.T) {
+	if _, err := SignURL("http://[::1", signingKey, signedAt); err == nil {
+		t.Error("SignURL of an invalid url succeeded, want an error")
+	}
+}
+
+func TestVerifyURL(t *testing.T) {
+	for _, rawURL := range []string{
+		deliveryURL,
+		deliveryURL + "?width=400&fit=cover",
+		deliveryURL + "?name=a%20b&empty=&flag",
+	} {
+		signed, err := SignURL(rawURL, signingKey, signedAt)
+		if err != nil {
+			t.Fatalf("SignURL(%q): %s", rawURL, err)
+		}
+
+		expiresAt, err := VerifyURL(signed, signingKey, signedAt.Add(-time.Minute))
+		if err != nil {
+			t.Errorf("VerifyURL(%q): %s", signed, err)
+		}
+		if !expiresAt.Equal(signedAt) {
+			t.Errorf("VerifyURL(%q) expires at %s, want %s", signed, expiresAt, signedAt)
+		}
+	}
+}
+
+func TestVerifyURLExpired(t *testing.T) {
+	signed, err := SignURL(deliveryURL, signingKey, signedAt)
+	if err != nil {
+		t.Fatalf("SignURL: %s", err)
+	}
+
+	for _, now := range []time.Time{signedAt, signedAt.Add(time.Hour)} {
+		expiresAt, err := VerifyURL(signed, signingKey, now)
+		if !errors.Is(err, ErrSignatureExpired) {
+			t.Errorf("VerifyURL at %s = %v, want ErrSignatureExpired", now, err)
+		}
+		if !expiresAt.Equal(signedAt) {
+			t.Errorf("VerifyURL at %s expires at %s, want %s", now, expiresAt, signedAt)
+		}
+	}
+}
+
+func TestVerifyURLInvalid(t *testing.T) {
+	signed, err := SignURL(deliveryURL+"?width=400", signingKey, signedAt)
+	if err != nil {
+		t.Fatalf("SignURL: %s", err)
+	}
+	now := signedAt.Add(-time.Minute)
+
+	tests := []struct {
+		name string
+		url  string
+		key  string
+		want error
+	}{
+		{"other key", signed, "YYYY", ErrInvalidSignature},
+		{"path tampered", strings.Replace(signed, "/public?", "/private?", 1), signingKey, ErrInvalidSignature},
+		{"query tampered", strings.Replace(signed, "width=400", "width=4000", 1), signingKey, ErrInvalidSignature},
+		{"query added", signed + "&height=400", signingKey, ErrInvalidSignature},
+		{"exp extended", strings.Replace(signed, "exp=1767225600", "exp=1767229200", 1), signingKey, ErrInvalidSignature},
+		{"exp removed", strings.Replace(signed, "&exp=1767225600", "", 1), signingKey, ErrInvalidSignature},
+		{"unsigned", deliveryURL + "?width=400", signingKey, ErrUnsigned},
+		{"empty signature", deliveryURL + "?sig=", signingKey, ErrUnsigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyURL(tt.url, tt.key, now); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyURL(%q) = %v, want %v", tt.url, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyURLSignatureCase(t *testing.T) {
+	signed, err := SignURL(deliveryURL, signingKey, signedAt)
+	if err != nil {
+		t.Fatalf("SignURL: %s", err)
+	}
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	query.Set("sig", strings.ToUpper(query.Get("sig")))
+	u.RawQuery = "exp=" + query.Get("exp") + "&sig=" + query.Get("sig")
+
+	if _, err := VerifyURL(u.String(), signingKey, signedAt.Add(-time.Minute)); err != nil {
+		t.Errorf("VerifyURL with an upper case signature: %s", err)
+	}
+}
+
+func TestVerifyURLInvalidExp(t *testing.T) {
+	if _, err := VerifyURL(deliveryURL+"?exp=tomorrow&sig=0123", signingKey, signedAt); err == nil {
+		t.Error("VerifyURL with an invalid exp succeeded, want an error")
+	}
+}
+
+func TestQueryWithout(t *testing.T) {
+	tests := []struct {
+		query string
+		names []string
+		want  []string
+	}{
+		{"", []string{"sig"}, nil},
+		{"a=1&b=2", nil, []string{"a=1", "b=2"}},
+		{"a=1&sig=x&b=2", []string{"sig"}, []string{"a=1", "b=2"}},
+		{"sig=x&exp=1&a=1", []string{"sig", "exp"}, []string{"a=1"}},
+		{"b=2&a=1&a=3", []string{"sig"}, []string{"b=2", "a=1", "a=3"}},
+		{"a=1&&b=2&", nil, []string{"a=1", "b=2"}},
+		{"flag&sig&a=%20", []string{"sig"}, []string{"flag", "a=%20"}},
+		{"signature=x", []string{"sig"}, []string{"signature=x"}},
+	}
+
+	for _, tt := range tests {
+		if got := queryWithout(tt.query, tt.names...); !slices.Equal(got, tt.want) {
+			t.Errorf("queryWithout(%q, %q) = %q, want %q", tt.query, tt.names, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package cloudflareclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Stats is the usage of the Images storage of the account.
+type Stats struct {
+	// Current is the number of images stored, and Allowed the number the plan allows.
+	Current int `json:"current"`
+	Allowed int `json:"allowed"`
+}
+
+// GetStats makes a request to Cloudflare to get the number of images stored against the plan allowance.
+// https://api.cloudflare.com/#cloudflare-images-images-usage-statistics
+func (c *Client) GetStats(ctx context.Context) (*Stats, error) {
+	var statsResp struct {
+		Result struct {
+			Count Stats `json:"count"`
+		} `json:"result"`
+	}
+
+	path := fmt.Sprintf("/accounts/%s/images/v1/stats", c.accountID)
+	if err := c.do(ctx, "cloudflare.images.stats", http.MethodGet, path, nil, &statsResp); err != nil {
+		return nil, err
+	}
+	return &statsResp.Result.Count, nil
+}
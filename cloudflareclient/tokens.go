@@ -0,0 +1,32 @@
+package cloudflareclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Token is the API token as described by the token verification endpoint.
+type Token struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	ExpiresOn time.Time `json:"expires_on"`
+}
+
+// VerifyToken makes a request to Cloudflare to check the API token is valid and active.
+// It fails for clients authenticated with a global API key, which isn't a token.
+// https://api.cloudflare.com/#user-api-tokens-verify-token
+func (c *Client) VerifyToken(ctx context.Context) (*Token, error) {
+	var verifyResp struct {
+		Result Token `json:"result"`
+	}
+	if err := c.do(ctx, "cloudflare.tokens.verify", http.MethodGet, "/user/tokens/verify", nil, &verifyResp); err != nil {
+		return nil, err
+	}
+
+	if verifyResp.Result.Status != "active" {
+		return nil, fmt.Errorf("token is %s", verifyResp.Result.Status)
+	}
+	return &verifyResp.Result, nil
+}
@@ -0,0 +1,32 @@
+package cloudflareclient
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// NewTransport returns the transport the clients use by default, a transport of its own
+// rather than http.DefaultTransport, tuned for many concurrent requests to the API:
+// enough idle connections are kept to the host for the requests to reuse them, and
+// connecting is bounded in time. HTTP/2 is negotiated when the API supports it.
+// It is meant to be customized, e.g. with a proxy, and given back with WithHTTPClient.
+func NewTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   64,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+}
@@ -0,0 +1,106 @@
+package cloudflareclient
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Variant is a variant of the images of the account, the way they are resized when delivered.
+type Variant struct {
+	ID      string         `json:"id"`
+	Options VariantOptions `json:"options"`
+	// NeverRequireSignedURLs serves the variant publicly even for the images requiring signed URLs.
+	NeverRequireSignedURLs bool `json:"neverRequireSignedURLs"`
+}
+
+// VariantOptions are how the images are resized for a variant.
+type VariantOptions struct {
+	// Fit is scale-down, contain, cover, crop or pad.
+	Fit string `json:"fit"`
+	// Metadata is keep, copyright or none.
+	Metadata string `json:"metadata"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// ListVariants makes a request to Cloudflare to list the variants of the images of the account, by id.
+// https://developers.cloudflare.com/api/resources/images/subresources/v1/subresources/variants/methods/list/
+func (c *Client) ListVariants(ctx context.Context) ([]Variant, error) {
+	var variantsResp struct {
+		Result struct {
+			Variants map[string]Variant `json:"variants"`
+		} `json:"result"`
+	}
+
+	path := fmt.Sprintf("/accounts/%s/images/v1/variants", c.accountID)
+	if err := c.do(ctx, "cloudflare.images.variants.list", http.MethodGet, path, nil, &variantsResp); err != nil {
+		return nil, err
+	}
+
+	variants := make([]Variant, 0, len(variantsResp.Result.Variants))
+	for id, v := range variantsResp.Result.Variants {
+		if v.ID == "" {
+			v.ID = id
+		}
+		variants = append(variants, v)
+	}
+	slices.SortFunc(variants, func(a, b Variant) int { return cmp.Compare(a.ID, b.ID) })
+	return variants, nil
+}
+
+// CreateVariant makes a request to Cloudflare to create a variant of the images of the account.
+// https://developers.cloudflare.com/api/resources/images/subresources/v1/subresources/variants/methods/create/
+func (c *Client) CreateVariant(ctx context.Context, variant Variant) (*Variant, error) {
+	reqBody, err := json.Marshal(variant)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode request body: %s", err)
+	}
+
+	var variantResp struct {
+		Result struct {
+			Variant Variant `json:"variant"`
+		} `json:"result"`
+	}
+
+	path := fmt.Sprintf("/accounts/%s/images/v1/variants", c.accountID)
+	if err := c.do(ctx, "cloudflare.images.variants.create", http.MethodPost, path, reqBody, &variantResp,
+		attribute.String("cloudflare.variant_id", variant.ID),
+	); err != nil {
+		return nil, err
+	}
+	return &variantResp.Result.Variant, nil
+}
+
+// UpdateVariant makes a request to Cloudflare to update the options of a variant and whether
+// it is always served publicly, replacing them as a whole.
+// https://developers.cloudflare.com/api/resources/images/subresources/v1/subresources/variants/methods/edit/
+func (c *Client) UpdateVariant(ctx context.Context, variant Variant) (*Variant, error) {
+	reqBody, err := json.Marshal(struct {
+		Options                VariantOptions `json:"options"`
+		NeverRequireSignedURLs bool           `json:"neverRequireSignedURLs"`
+	}{variant.Options, variant.NeverRequireSignedURLs})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode request body: %s", err)
+	}
+
+	var variantResp struct {
+		Result struct {
+			Variant Variant `json:"variant"`
+		} `json:"result"`
+	}
+
+	path := fmt.Sprintf("/accounts/%s/images/v1/variants/%s", c.accountID, url.PathEscape(variant.ID))
+	if err := c.do(ctx, "cloudflare.images.variants.update", http.MethodPatch, path, reqBody, &variantResp,
+		attribute.String("cloudflare.variant_id", variant.ID),
+	); err != nil {
+		return nil, err
+	}
+	return &variantResp.Result.Variant, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// toolName is the name the completions are registered for, the one of the installed binary.
+const toolName = "securecloudflareimg"
+
+// flagsCollector is set by the completion command to collect the flags of the commands rather
+// than running them, the commands returning errFlagsCollected once their flags are registered.
+var flagsCollector func(fs *flag.FlagSet)
+
+var errFlagsCollected = errors.New("flags collected")
+
+// parseFlags parses the flags of a command, unless they are collected for the completions.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if flagsCollector != nil {
+		flagsCollector(fs)
+		return errFlagsCollected
+	}
+	return fs.Parse(args)
+}
+
+// commandFlags are the flags of a command, for its completions.
+type commandFlags struct {
+	command
+	flags []*flag.Flag
+}
+
+// collectFlags returns the commands with their flags, running each of them far enough
+// for their flags to be registered.
+func collectFlags() ([]commandFlags, error) {
+	var (
+		cmds []commandFlags
+		fs   *flag.FlagSet
+	)
+	flagsCollector = func(f *flag.FlagSet) { fs = f }
+	defer func() { flagsCollector = nil }()
+
+	for _, c := range commands {
+		cf := commandFlags{command: c}
+
+		// Neither has flags, version would print the version.
+		if c.name != "version" && c.name != "completion" {
+			// The flags of the commands with a subcommand are the ones of their first.
+			var args []string
+			switch c.name {
+			case "config":
+				args = []string{"validate"}
+			case "variants":
+				args = []string{"list"}
+			case "audit":
+				args = []string{"verify"}
+			}
+
+			fs = nil
+			if err := c.run(context.Background(), args); !errors.Is(err, errFlagsCollected) {
+				return nil, fmt.Errorf("could not collect the flags of %s: %v", c.name, err)
+			}
+			fs.VisitAll(func(f *flag.Flag) { cf.flags = append(cf.flags, f) })
+		}
+		cmds = append(cmds, cf)
+	}
+	return cmds, nil
+}
+
+// completionCmd prints the completion script of a shell, for the commands and their flags.
+func completionCmd(_ context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: completion bash|zsh|fish")
+	}
+
+	cmds, err := collectFlags()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "bash":
+		return writeBashCompletion(os.Stdout, cmds)
+	case "zsh":
+		return writeZshCompletion(os.Stdout, cmds)
+	case "fish":
+		return writeFishCompletion(os.Stdout, cmds)
+	default:
+		return fmt.Errorf("invalid shell '%s', expected bash, zsh or fish", args[0])
+	}
+}
+
+// isBoolFlag tells whether the flag takes no value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func commandNames(cmds []commandFlags) string {
+	names := make([]string, 0, len(cmds))
+	for _, c := range cmds {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, " ")
+}
+
+// writeBashCompletion completes the commands, then the flags of the command, secure without one,
+// leaving the values of the flags and the arguments to the completion of the file names.
+func writeBashCompletion(w io.Writer, cmds []commandFlags) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s, source <(%s completion bash)\n\n", toolName, toolName)
+	fmt.Fprintf(&b, "_%s() {\n", toolName)
+	b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" cmd=secure\n")
+	b.WriteString("\tif [[ ${COMP_CWORD} -eq 1 && $cur != -* ]]; then\n")
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", commandNames(cmds))
+	b.WriteString("\t\treturn\n\tfi\n")
+	b.WriteString("\t[[ ${COMP_WORDS[1]} != -* ]] && cmd=${COMP_WORDS[1]}\n")
+	b.WriteString("\t[[ $cur != -* ]] && return\n\n")
+	b.WriteString("\tlocal flags\n\tcase $cmd in\n")
+	for _, c := range cmds {
+		names := make([]string, 0, len(c.flags))
+		for _, f := range c.flags {
+			names = append(names, "-"+f.Name)
+		}
+		fmt.Fprintf(&b, "\t%s) flags=%q ;;\n", c.name, strings.Join(names, " "))
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("\tCOMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n}\n\n")
+	fmt.Fprintf(&b, "complete -o default -F _%s %s\n", toolName, toolName)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeZshCompletion completes the commands and the flags with their descriptions.
+func writeZshCompletion(w io.Writer, cmds []commandFlags) error {
+	// The descriptions are in single quotes, after a colon for the commands and in brackets for the flags.
+	quoteCommand := strings.NewReplacer("'", `'\''`, ":", `\:`).Replace
+	quoteFlag := strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`).Replace
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n# zsh completion for %s, source <(%s completion zsh)\n\n", toolName, toolName, toolName)
+	fmt.Fprintf(&b, "_%s() {\n", toolName)
+	b.WriteString("\tif (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then\n\t\tlocal -a commands=(\n")
+	for _, c := range cmds {
+		fmt.Fprintf(&b, "\t\t\t'%s:%s'\n", c.name, quoteCommand(c.help))
+	}
+	b.WriteString("\t\t)\n\t\t_describe command commands\n\t\treturn\n\tfi\n\n")
+	b.WriteString("\tlocal cmd=secure\n")
+	b.WriteString("\tif [[ $words[2] != -* ]]; then\n\t\tcmd=$words[2]\n\t\tshift words\n\t\t(( CURRENT-- ))\n\tfi\n\n")
+	b.WriteString("\tlocal -a flags\n\tcase $cmd in\n")
+	for _, c := range cmds {
+		fmt.Fprintf(&b, "\t%s)\n\t\tflags=(\n", c.name)
+		for _, f := range c.flags {
+			value := ":value:_files"
+			if isBoolFlag(f) {
+				value = ""
+			}
+			fmt.Fprintf(&b, "\t\t\t'-%s[%s]%s'\n", f.Name, quoteFlag(f.Usage), value)
+		}
+		b.WriteString("\t\t)\n\t\t;;\n")
+	}
+	b.WriteString("\tesac\n\t_arguments $flags '*:file:_files'\n}\n\n")
+	fmt.Fprintf(&b, "if [[ $zsh_eval_context[-1] == loadautofunc ]]; then\n\t_%s \"$@\"\nelse\n\tcompdef _%s %s\nfi\n", toolName, toolName, toolName)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeFishCompletion completes the commands and the flags with their descriptions,
+// the flags of secure when no command is given.
+func writeFishCompletion(w io.Writer, cmds []commandFlags) error {
+	quote := func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s, %s completion fish | source\n\n", toolName, toolName)
+	fmt.Fprintf(&b, "complete -c %s -f\n", toolName)
+	for _, c := range cmds {
+		fmt.Fprintf(&b, "complete -c %s -n __fish_use_subcommand -a %s -d %s\n", toolName, c.name, quote(c.help))
+	}
+
+	for _, c := range cmds {
+		cond := "__fish_seen_subcommand_from " + c.name
+		if c.name == "secure" {
+			cond += "; or not __fish_seen_subcommand_from " + commandNames(cmds)
+		}
+
+		for _, f := range c.flags {
+			value := " -r -F"
+			if isBoolFlag(f) {
+				value = ""
+			}
+			fmt.Fprintf(&b, "complete -c %s -n %s -o %s%s -d %s\n", toolName, quote(cond), f.Name, value, quote(f.Usage))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestCollectFlags(t *testing.T) {
+	cmds, err := collectFlags()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := map[string]map[string]bool{}
+	for _, c := range cmds {
+		flags[c.name] = map[string]bool{}
+		for _, f := range c.flags {
+			flags[c.name][f.Name] = true
+		}
+	}
+
+	tests := []struct {
+		command    string
+		has, hasnt []string
+	}{
+		{"secure", []string{"watch", "schedule", "serve", "grpc-addr", "upload-queue", "resume"}, []string{"migration-dir", "access-logs"}},
+		{"migrate", []string{"migration-dir", "canary"}, []string{"watch", "schedule", "serve", "grpc-addr", "metrics-addr", "upload-queue", "resume"}},
+		{"ingest-logs", []string{"access-logs", "resume"}, []string{"watch", "schedule", "serve", "grpc-addr", "metrics-addr", "upload-queue"}},
+	}
+
+	for _, tt := range tests {
+		for _, name := range tt.has {
+			if !flags[tt.command][name] {
+				t.Errorf("%s has no -%s", tt.command, name)
+			}
+		}
+		for _, name := range tt.hasnt {
+			if flags[tt.command][name] {
+				t.Errorf("%s has -%s", tt.command, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type validatingConfigKey struct{}
+
+// validatingConfig tells whether the command only validates its configuration, for config validate.
+func validatingConfig(ctx context.Context) bool {
+	v, _ := ctx.Value(validatingConfigKey{}).(bool)
+	return v
+}
+
+// configCmd validates the flags of secure as a deployment gives them: the flags are parsed,
+// the secrets resolved and the policy, exclude and ids files loaded, the combinations of flags
+// checked, stopping before contacting cloudflare, for bad configurations to be caught in CI.
+// The sinks are only checked, not opened: no file is created and no broker dialled.
+func configCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return errors.New("usage: config validate [flags of secure]")
+	}
+
+	if err := secureCmd(context.WithValue(ctx, validatingConfigKey{}, true), args[1:]); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	fmt.Println("configuration is valid")
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigValidateOpensNothing(t *testing.T) {
+	dir := t.TempDir()
+
+	err := configCmd(context.Background(), []string{
+		"validate",
+		"-account-id", "account", "-api-key", "key",
+		"-audit-log", filepath.Join(dir, "audit.jsonl"),
+		"-log-file", filepath.Join(dir, "secure.log"),
+		"-publish-events", "nats://127.0.0.1:1/images",
+		"-statsd-addr", "127.0.0.1:8125",
+	})
+	if err != nil {
+		t.Fatalf("config validate: %s", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("config validate created %s", e.Name())
+	}
+}
+
+func TestConfigValidateChecksSinks(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"audit log directory missing", []string{"-audit-log", filepath.Join(t.TempDir(), "missing", "audit.jsonl")}},
+		{"unknown event bus", []string{"-publish-events", "amqp://broker/images"}},
+		{"statsd address without port", []string{"-statsd-addr", "127.0.0.1"}},
+		{"invalid sentry dsn", []string{"-sentry-dsn", "not a dsn"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"validate", "-account-id", "account", "-api-key", "key"}, tt.args...)
+			if err := configCmd(context.Background(), args); err == nil {
+				t.Errorf("config validate %q succeeded, want an error", tt.args)
+			}
+		})
+	}
+}
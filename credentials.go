@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keychainService is the service the api key is stored under in the os keychain.
+const keychainService = "securecloudflareimg"
+
+// newCredentialSource returns the backend reading the api key for local usage,
+// selected with -credential-source.
+func newCredentialSource(source string) (secretBackend, error) {
+	switch source {
+	case "keychain":
+		return osKeychain{}, nil
+	case "1password":
+		if _, err := exec.LookPath("op"); err != nil {
+			return nil, errors.New("the 1password cli 'op' is required to read the api key from 1password")
+		}
+		return onePassword{}, nil
+	default:
+		return nil, fmt.Errorf("invalid -credential-source '%s', expected keychain or 1password", source)
+	}
+}
+
+// osKeychain reads the api key from the macOS Keychain, the Windows Credential Manager
+// or the Secret Service on linux, stored as a generic password for the service under the name.
+type osKeychain struct{}
+
+func (osKeychain) fetch(ctx context.Context, name, key string) (string, error) {
+	secret, err := keyring.Get(keychainService, name)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", fmt.Errorf("no password for service '%s' and account '%s' in the keychain", keychainService, name)
+	}
+	return secret, err
+}
+
+// onePassword reads the api key with the 1password cli, from a secret reference
+// such as op://Private/Cloudflare/credential. The cli prompts to unlock if needed.
+type onePassword struct{}
+
+func (onePassword) fetch(ctx context.Context, name, key string) (string, error) {
+	if !strings.HasPrefix(name, "op://") {
+		return "", fmt.Errorf("invalid 1password secret reference '%s', expected op://<vault>/<item>/<field>", name)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "op", "read", "--no-newline", name)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("op read failed: %s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
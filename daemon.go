@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+// daemonConfig holds the flags of secure keeping it running, which migrate and ingest-logs,
+// making a single pass, don't take.
+type daemonConfig struct {
+	watch        time.Duration
+	schedule     string
+	serveAddr    string
+	grpcAddr     string
+	metricsAddr  string
+	pprof        bool
+	uploadEvents bool
+	uploadQueue  string
+}
+
+func (c *daemonConfig) registerFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.watch, "watch", 0, "keep running, securing new images at the given poll interval (e.g. 5m)")
+	fs.StringVar(&c.schedule, "schedule", "", "keep running, securing images on the given cron schedule (e.g. \"0 */6 * * *\")")
+	fs.StringVar(&c.serveAddr, "serve", "", "keep running, serving an http api to trigger runs and follow them on the given address (e.g. :8080)")
+	fs.StringVar(&c.grpcAddr, "grpc-addr", "", "keep running, serving the grpc api on the given address (e.g. :9000)")
+	fs.StringVar(&c.metricsAddr, "metrics-addr", "", "address to expose prometheus metrics on at /metrics when watching or running on a schedule (e.g. :9090)")
+	fs.BoolVar(&c.pprof, "pprof", false, "serve the pprof endpoints at /debug/pprof/ along with -serve or -metrics-addr, to profile a daemon")
+	fs.BoolVar(&c.uploadEvents, "upload-events", false, "receive upload notifications on POST /v1/uploads of -serve, securing the uploaded images within seconds, signed with the "+uploadsSecretEnv+" environment variable if set")
+	fs.StringVar(&c.uploadQueue, "upload-queue", "", "keep running, securing the images of the upload notifications received from sqs://<queue url without https>, pubsub://<project>/<subscription> or nats://<host>:<port>/<subject>[?queue=<group>]")
+}
+
+// enabled tells whether the flags keep secure running, passing through the images when
+// watching, on schedule or on request.
+func (c *daemonConfig) enabled() bool {
+	return c.watch > 0 || c.schedule != "" || c.serveAddr != "" || c.grpcAddr != "" || c.uploadQueue != ""
+}
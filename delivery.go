@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// deliveryHost is the host the images are delivered from, unless served from a zone of the
+// account under /cdn-cgi/imagedelivery.
+const deliveryHost = "imagedelivery.net"
+
+// isURL tells whether the line of an ids file is a URL rather than an id.
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// parseDeliveryURL returns the account hash and the image id of a delivery URL,
+// https://imagedelivery.net/<account hash>/<image id>/<variant> or
+// https://<zone>/cdn-cgi/imagedelivery/<account hash>/<image id>/<variant>. The custom ids
+// may contain slashes, the variant, or the flexible variant options, being the last segment.
+func parseDeliveryURL(s string) (accountHash, imageID string, err error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid url: %s", err)
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+	if u.Hostname() != deliveryHost {
+		var ok bool
+		if path, ok = strings.CutPrefix(path, "cdn-cgi/imagedelivery/"); !ok {
+			return "", "", fmt.Errorf("'%s' is not an image delivery url", s)
+		}
+	}
+
+	segments := strings.Split(path, "/")
+	if len(segments) < 3 || segments[0] == "" || slices.Contains(segments[1:len(segments)-1], "") {
+		return "", "", fmt.Errorf("'%s' is not an image delivery url, expected /<account hash>/<image id>/<variant>", s)
+	}
+	return segments[0], strings.Join(segments[1:len(segments)-1], "/"), nil
+}
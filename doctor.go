@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+const (
+	// doctorLatencySamples is the number of requests the latency of the API is measured over.
+	doctorLatencySamples = 5
+	// doctorSlowLatency is the median latency of the API above which it is reported as slow.
+	doctorSlowLatency = time.Second
+	// doctorTokenExpiry is how long before its expiry the API token is reported as expiring.
+	doctorTokenExpiry = 30 * 24 * time.Hour
+)
+
+// doctorCheck is the outcome of a check of the doctor command.
+type doctorCheck struct {
+	name   string
+	status string
+	detail string
+}
+
+// doctorCmd checks the credentials, the account and the Cloudflare Images subscription the tool
+// needs to run, going through every check rather than stopping at the first failing one.
+func doctorCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	checks := runDoctor(ctx, opts.newClient(), opts.authKey != "")
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var failed int
+	for _, c := range checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.status, c.name, c.detail)
+		if c.status == "FAIL" {
+			failed++
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// runDoctor runs the checks against the API. The global API keys aren't tokens, they can't be verified.
+func runDoctor(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI, globalKey bool) []doctorCheck {
+	token := doctorCheck{name: "credentials", status: "ok", detail: "global api key, not a token to verify"}
+	if !globalKey {
+		token = checkToken(ctx, cli)
+	}
+
+	return []doctorCheck{
+		token,
+		checkAccount(ctx, cli),
+		checkSubscription(ctx, cli),
+		checkPermissions(ctx, cli),
+		checkLatency(ctx, cli),
+		checkSigningKeys(ctx, cli),
+	}
+}
+
+func checkToken(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI) doctorCheck {
+	c := doctorCheck{name: "credentials"}
+	switch token, err := cli.VerifyToken(ctx); {
+	case err != nil:
+		c.status, c.detail = "FAIL", fmt.Sprintf("the api token is invalid: %s", err)
+	case !token.ExpiresOn.IsZero() && time.Until(token.ExpiresOn) < doctorTokenExpiry:
+		c.status, c.detail = "warn", "the api token expires on "+token.ExpiresOn.Format(time.DateOnly)
+	default:
+		c.status, c.detail = "ok", "the api token is active"
+	}
+	return c
+}
+
+func checkAccount(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI) doctorCheck {
+	account, err := cli.GetAccount(ctx)
+	if err != nil {
+		return doctorCheck{name: "account", status: "FAIL", detail: fmt.Sprintf("the account can't be accessed, check -account-id and the account resources of the token: %s", err)}
+	}
+	return doctorCheck{name: "account", status: "ok", detail: fmt.Sprintf("%s (%s)", account.Name, account.ID)}
+}
+
+// checkSubscription checks the plan of the account allows storing images, the usage statistics
+// failing for accounts without a Cloudflare Images subscription.
+func checkSubscription(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI) doctorCheck {
+	c := doctorCheck{name: "images subscription"}
+	switch stats, err := cli.GetStats(ctx); {
+	case err != nil:
+		c.status, c.detail = "FAIL", fmt.Sprintf("the usage of cloudflare images can't be read, check the subscription of the account: %s", err)
+	case stats.Allowed == 0:
+		c.status, c.detail = "FAIL", "the plan of the account allows no images, check the subscription of the account"
+	case stats.Current >= stats.Allowed:
+		c.status, c.detail = "warn", fmt.Sprintf("%d images stored, the %d allowed by the plan are used up", stats.Current, stats.Allowed)
+	default:
+		c.status, c.detail = "ok", fmt.Sprintf("%d of %d images allowed stored", stats.Current, stats.Allowed)
+	}
+	return c
+}
+
+// checkPermissions runs the preflight of the runs securing the images, checking the permissions to read and update them.
+func checkPermissions(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI) doctorCheck {
+	c := doctorCheck{name: "permissions"}
+	var permErr *cloudflareclient.PermissionError
+	switch err := cli.Preflight(ctx, true); {
+	case errors.As(err, &permErr):
+		c.status, c.detail = "FAIL", fmt.Sprintf("the credentials need the %s permission: %s", permErr.Permission, permErr.Err)
+	case err != nil:
+		c.status, c.detail = "FAIL", err.Error()
+	default:
+		c.status, c.detail = "ok", "cloudflare images read and write"
+	}
+	return c
+}
+
+// checkLatency measures the latency of the API over a few requests for the usage statistics,
+// which are cheap to serve.
+func checkLatency(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI) doctorCheck {
+	latencies := make([]time.Duration, 0, doctorLatencySamples)
+	for range doctorLatencySamples {
+		start := time.Now()
+		if _, err := cli.GetStats(ctx); err != nil {
+			return doctorCheck{name: "api latency", status: "FAIL", detail: fmt.Sprintf("request failed: %s", err)}
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	slices.Sort(latencies)
+
+	median := latencies[len(latencies)/2]
+	detail := fmt.Sprintf("median %s, max %s over %d requests", median.Round(time.Millisecond), latencies[len(latencies)-1].Round(time.Millisecond), len(latencies))
+	if median > doctorSlowLatency {
+		return doctorCheck{name: "api latency", status: "warn", detail: detail + ", consider a higher -request-timeout"}
+	}
+	return doctorCheck{name: "api latency", status: "ok", detail: detail}
+}
+
+// checkSigningKeys checks the account has a key to sign the URLs of the images once they require signed URLs.
+func checkSigningKeys(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI) doctorCheck {
+	keys, err := cli.SigningKeys(ctx)
+	if err != nil {
+		return doctorCheck{name: "signing keys", status: "FAIL", detail: fmt.Sprintf("the signing keys can't be listed: %s", err)}
+	}
+
+	if len(keys) == 0 {
+		return doctorCheck{name: "signing keys", status: "FAIL", detail: "no signing key, the images requiring signed urls can't be served"}
+	}
+
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.Name)
+	}
+	return doctorCheck{name: "signing keys", status: "ok", detail: strings.Join(names, ", ")}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+)
+
+// driftCmd compares the current state of the account with a stored inventory
+// and reports the images that drifted from the desired state since, without modifying them.
+func driftCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	opts.registerSelectionFlags(fs)
+	inventoryPtr := fs.String("inventory", "", "inventory file written by a previous run")
+	updatePtr := fs.Bool("update", false, "replace the inventory file with the current state after reporting")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	if *inventoryPtr == "" {
+		fs.Usage()
+		return errors.New("-inventory is required")
+	}
+
+	sel, err := opts.loadSelection()
+	if err != nil {
+		return err
+	}
+
+	prev, err := readInventory(*inventoryPtr)
+	if err != nil {
+		return err
+	}
+
+	if prev.AccountID != opts.accountID {
+		return fmt.Errorf("inventory was taken for account '%s', not '%s'", prev.AccountID, opts.accountID)
+	}
+
+	cli, err := opts.connect(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	images, err := cli.ListImages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %s", err)
+	}
+
+	known := prev.byID()
+	changes, _, _, _ := sel.filter.changes(images, sel.policy)
+
+	var added, flipped int
+	for _, c := range changes {
+		before, ok := known[c.ImageID]
+		switch {
+		case !ok:
+			added++
+			fmt.Printf("new image '%s' should be %s\n", c.ImageID, c.verb())
+		case before.RequireSignedURLs == c.RequireSignedURLs:
+			flipped++
+			fmt.Printf("image '%s' changed since the inventory and should be %s again\n", c.ImageID, c.verb())
+		}
+	}
+
+	fmt.Printf("drift since %s: %d new images and %d changed images not in the desired state\n",
+		prev.TakenAt.Format("2006-01-02 15:04:05 MST"), added, flipped)
+
+	if *updatePtr {
+		if err := writeInventory(*inventoryPtr, newInventory(opts.accountID, images)); err != nil {
+			return err
+		}
+		slog.Info("inventory updated", "file", *inventoryPtr)
+	}
+	return nil
+}
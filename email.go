@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const smtpPasswordEnv = "SECURECLOUDFLAREIMG_SMTP_PASSWORD"
+
+// smtpConfig holds the flags configuring the report emails.
+type smtpConfig struct {
+	addr     string
+	from     string
+	to       string
+	username string
+	password string
+	// digest is "run" to send an email per run, or "daily" for a digest of the runs of the day.
+	digest string
+}
+
+func (c *smtpConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.addr, "smtp-addr", "", "smtp server host:port to send report emails through")
+	fs.StringVar(&c.from, "smtp-from", "", "sender address of the report emails")
+	fs.StringVar(&c.to, "smtp-to", "", "comma separated recipient addresses of the report emails")
+	fs.StringVar(&c.username, "smtp-username", "", "smtp username, the password is read from the "+smtpPasswordEnv+" environment variable")
+	fs.StringVar(&c.digest, "email-digest", "run", "send a report email per run, or a daily digest of the runs when watching or running on a schedule")
+}
+
+// mailer sends report emails, either one per run or a daily digest.
+type mailer struct {
+	cfg smtpConfig
+	to  []string
+
+	mu sync.Mutex
+	// digestStart is when the runs of the current digest started being collected.
+	digestStart time.Time
+	runs        int
+	secured     []string
+	failed      []string
+	last        *runSummary
+}
+
+func newMailer(cfg smtpConfig) (*mailer, error) {
+	if _, _, err := net.SplitHostPort(cfg.addr); err != nil {
+		return nil, fmt.Errorf("invalid -smtp-addr '%s': %s", cfg.addr, err)
+	}
+
+	if cfg.from == "" {
+		return nil, errors.New("-smtp-from is required to send report emails")
+	}
+
+	to := splitList(cfg.to)
+	if len(to) == 0 {
+		return nil, errors.New("-smtp-to is required to send report emails")
+	}
+
+	if cfg.digest != "run" && cfg.digest != "daily" {
+		return nil, fmt.Errorf("invalid -email-digest '%s', expected run or daily", cfg.digest)
+	}
+
+	cfg.password = os.Getenv(smtpPasswordEnv)
+
+	return &mailer{cfg: cfg, to: to, digestStart: time.Now()}, nil
+}
+
+// report sends the email of the run, or adds the run to the digest and sends it once a day has passed.
+func (m *mailer) report(sum *runSummary) error {
+	if m.cfg.digest == "run" {
+		return m.send("securecloudflareimg run report", summaryEmail(sum))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.runs++
+	m.secured = append(m.secured, sum.SecuredIDs...)
+	m.failed = append(m.failed, sum.FailedIDs...)
+	m.last = sum
+
+	if time.Since(m.digestStart) < 24*time.Hour {
+		return nil
+	}
+
+	body := m.digestEmail()
+
+	m.digestStart, m.runs, m.secured, m.failed, m.last = time.Now(), 0, nil, nil, nil
+	return m.send("securecloudflareimg daily digest", body)
+}
+
+func (m *mailer) digestEmail() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d runs on account %s since %s.\n\n", m.runs, m.last.AccountID, m.digestStart.Format(time.RFC1123))
+	writeIDList(&b, "Newly secured images", m.secured)
+	writeIDList(&b, "Failed images", m.failed)
+	writeIDList(&b, "Images remaining unprotected after the last run", m.last.RemainingUnprotectedIDs)
+	return b.String()
+}
+
+func summaryEmail(sum *runSummary) string {
+	var b strings.Builder
+
+	b.WriteString(summaryText(sum))
+	b.WriteString("\n\n")
+	writeIDList(&b, "Newly secured images", sum.SecuredIDs)
+	writeIDList(&b, "Failed images", sum.FailedIDs)
+	writeIDList(&b, "Images remaining unprotected", sum.RemainingUnprotectedIDs)
+	return b.String()
+}
+
+func writeIDList(b *strings.Builder, title string, ids []string) {
+	fmt.Fprintf(b, "%s (%d):\n", title, len(ids))
+	for _, id := range ids {
+		fmt.Fprintf(b, "  - %s\n", id)
+	}
+	b.WriteString("\n")
+}
+
+func (m *mailer) send(subject, body string) error {
+	return m.sendTo(m.to, subject, body)
+}
+
+// sendTo sends an email to other recipients than the ones of the reports.
+func (m *mailer) sendTo(to []string, subject, body string) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.cfg.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if m.cfg.username != "" {
+		host, _, _ := net.SplitHostPort(m.cfg.addr)
+		auth = smtp.PlainAuth("", m.cfg.username, m.cfg.password, host)
+	}
+
+	// SendMail upgrades the connection with STARTTLS when the server supports it.
+	if err := smtp.SendMail(m.cfg.addr, auth, m.cfg.from, to, []byte(msg.String())); err != nil {
+		return fmt.Errorf("could not send email: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// imageEvent is the event published to the message bus for an image.
+type imageEvent struct {
+	// Event is image.secured, image.made_public, image.deleted at the end of the quarantine
+	// of the image, or image.unprotected for the images remaining unprotected at the end of a run.
+	Event     string    `json:"event"`
+	AccountID string    `json:"account_id"`
+	ImageID   string    `json:"image_id"`
+	Time      time.Time `json:"time"`
+}
+
+// eventSink sends the events to a message bus.
+type eventSink interface {
+	send(ctx context.Context, events []imageEvent) error
+}
+
+// eventSinkRef is the sink of the events given with -publish-events, parsed without connecting to it:
+//
+//	sns://arn:aws:sns:eu-west-1:123456789012:images            aws sns topic, by its arn
+//	sqs://sqs.eu-west-1.amazonaws.com/123456789012/images      aws sqs queue, by its url without https
+//	kafka://broker-1:9092,broker-2:9092/images                 kafka topic
+//	nats://localhost:4222/images.events                        nats subject
+type eventSinkRef struct {
+	scheme string
+	// target is the arn of the sns topic, the url of the sqs queue, the kafka topic or the nats subject.
+	target string
+	// servers are the kafka brokers, or the url of the nats server with its credentials.
+	servers []string
+	user    *url.Userinfo
+}
+
+func parseEventSink(ref string) (eventSinkRef, error) {
+	scheme, rest, ok := strings.Cut(ref, "://")
+	if !ok || rest == "" {
+		return eventSinkRef{}, fmt.Errorf("invalid -publish-events '%s', expected sns://, sqs://, kafka:// or nats://", ref)
+	}
+
+	switch scheme {
+	case "sns":
+		return eventSinkRef{scheme: scheme, target: rest}, nil
+	case "sqs":
+		return eventSinkRef{scheme: scheme, target: "https://" + rest}, nil
+	case "kafka":
+		brokers, topic, ok := strings.Cut(rest, "/")
+		if !ok || brokers == "" || topic == "" {
+			return eventSinkRef{}, errors.New("expected kafka://<broker>[,<broker>...]/<topic>")
+		}
+		return eventSinkRef{scheme: scheme, target: topic, servers: splitList(brokers)}, nil
+	case "nats":
+		u, err := url.Parse(ref)
+		if err != nil {
+			return eventSinkRef{}, fmt.Errorf("invalid -publish-events '%s': %s", ref, err)
+		}
+
+		subject := strings.TrimPrefix(u.Path, "/")
+		if subject == "" {
+			return eventSinkRef{}, errors.New("expected nats://<host>:<port>/<subject>")
+		}
+		return eventSinkRef{scheme: scheme, target: subject, servers: []string{u.Scheme + "://" + u.Host}, user: u.User}, nil
+	default:
+		return eventSinkRef{}, fmt.Errorf("invalid -publish-events '%s', expected sns://, sqs://, kafka:// or nats://", ref)
+	}
+}
+
+// connect returns the sink, loading the aws configuration or connecting to nats.
+func (r eventSinkRef) connect(ctx context.Context) (eventSink, error) {
+	switch r.scheme {
+	case "sns", "sqs":
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not load aws configuration: %s", err)
+		}
+
+		if r.scheme == "sns" {
+			return snsSink{cli: sns.NewFromConfig(cfg), topicARN: r.target}, nil
+		}
+		return sqsSink{cli: sqs.NewFromConfig(cfg), queueURL: r.target}, nil
+	case "kafka":
+		return kafkaSink{w: &kafka.Writer{
+			Addr:         kafka.TCP(r.servers...),
+			Topic:        r.target,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 10 * time.Millisecond,
+		}}, nil
+	default:
+		opts := []nats.Option{nats.Name("securecloudflareimg")}
+		if r.user != nil {
+			password, _ := r.user.Password()
+			opts = append(opts, nats.UserInfo(r.user.Username(), password))
+		}
+
+		// The connection reconnects by itself when it is lost.
+		nc, err := nats.Connect(r.servers[0], opts...)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to nats: %s", err)
+		}
+		return natsSink{nc: nc, subject: r.target}, nil
+	}
+}
+
+// eventPublisher publishes an event for every image changed, as the changes are made,
+// and for the images remaining unprotected at the end of a run, for the downstream systems
+// (cache invalidation, CMS) to react. The events are sent in the background, by batches.
+// Its methods are no-ops on a nil eventPublisher.
+type eventPublisher struct {
+	sink      eventSink
+	accountID string
+	queue     chan queuedEvent
+}
+
+// queuedEvent is an event waiting to be sent, or a marker closing flushed
+// once the events queued before it are sent.
+type queuedEvent struct {
+	event   imageEvent
+	flushed chan struct{}
+}
+
+// eventQueueSize is the number of events waiting to be sent at most,
+// the changes wait for the queue to make room beyond.
+const eventQueueSize = 1000
+
+func newEventPublisher(sink eventSink, accountID string) *eventPublisher {
+	p := &eventPublisher{sink: sink, accountID: accountID, queue: make(chan queuedEvent, eventQueueSize)}
+	go p.run()
+	return p
+}
+
+// run sends the events by batches of the ones queued meanwhile, up to 100.
+func (p *eventPublisher) run() {
+	var batch []imageEvent
+	for q := range p.queue {
+		if q.flushed == nil {
+			batch = append(batch, q.event)
+			if len(p.queue) > 0 && len(batch) < 100 {
+				continue
+			}
+		}
+
+		if len(batch) > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := p.sink.send(ctx, batch); err != nil {
+				slog.Error("failed to publish image events", "events", len(batch), "error", err)
+			}
+			cancel()
+			batch = nil
+		}
+
+		if q.flushed != nil {
+			close(q.flushed)
+		}
+	}
+}
+
+func (p *eventPublisher) publish(event, imageID string) {
+	p.queue <- queuedEvent{event: imageEvent{Event: event, AccountID: p.accountID, ImageID: imageID, Time: time.Now().UTC()}}
+}
+
+// observe is an applier observer publishing the changes made.
+func (p *eventPublisher) observe(c change, err error) {
+	if p == nil || err != nil || c.alreadySecured {
+		return
+	}
+
+	event := "image.secured"
+	switch {
+	case c.delete:
+		event = "image.deleted"
+	case !c.RequireSignedURLs:
+		event = "image.made_public"
+	}
+	p.publish(event, c.ImageID)
+}
+
+// report publishes the images remaining unprotected at the end of the run,
+// and waits for the events of the run to be sent.
+func (p *eventPublisher) report(sum *runSummary) {
+	if p == nil {
+		return
+	}
+
+	for _, id := range sum.RemainingUnprotectedIDs {
+		p.publish("image.unprotected", id)
+	}
+
+	flushed := make(chan struct{})
+	p.queue <- queuedEvent{flushed: flushed}
+	<-flushed
+}
+
+// snsSink publishes the events to an AWS SNS topic, with the credentials and region
+// of the default AWS configuration chain.
+type snsSink struct {
+	cli      *sns.Client
+	topicARN string
+}
+
+func (s snsSink) send(ctx context.Context, events []imageEvent) error {
+	for batch := range slices.Chunk(events, 10) {
+		entries := make([]snstypes.PublishBatchRequestEntry, 0, len(batch))
+		for i, ev := range batch {
+			body, err := json.Marshal(ev)
+			if err != nil {
+				return fmt.Errorf("could not encode event: %s", err)
+			}
+
+			entries = append(entries, snstypes.PublishBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				Message:           aws.String(string(body)),
+				MessageAttributes: map[string]snstypes.MessageAttributeValue{"event": {DataType: aws.String("String"), StringValue: aws.String(ev.Event)}},
+			})
+		}
+
+		out, err := s.cli.PublishBatch(ctx, &sns.PublishBatchInput{TopicArn: aws.String(s.topicARN), PublishBatchRequestEntries: entries})
+		if err != nil {
+			return err
+		}
+		if len(out.Failed) > 0 {
+			return fmt.Errorf("%d events rejected: %s", len(out.Failed), aws.ToString(out.Failed[0].Message))
+		}
+	}
+	return nil
+}
+
+// sqsSink sends the events to an AWS SQS queue, with the credentials and region
+// of the default AWS configuration chain.
+type sqsSink struct {
+	cli      *sqs.Client
+	queueURL string
+}
+
+func (s sqsSink) send(ctx context.Context, events []imageEvent) error {
+	for batch := range slices.Chunk(events, 10) {
+		entries := make([]sqstypes.SendMessageBatchRequestEntry, 0, len(batch))
+		for i, ev := range batch {
+			body, err := json.Marshal(ev)
+			if err != nil {
+				return fmt.Errorf("could not encode event: %s", err)
+			}
+
+			entries = append(entries, sqstypes.SendMessageBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				MessageBody:       aws.String(string(body)),
+				MessageAttributes: map[string]sqstypes.MessageAttributeValue{"event": {DataType: aws.String("String"), StringValue: aws.String(ev.Event)}},
+			})
+		}
+
+		out, err := s.cli.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{QueueUrl: aws.String(s.queueURL), Entries: entries})
+		if err != nil {
+			return err
+		}
+		if len(out.Failed) > 0 {
+			return fmt.Errorf("%d events rejected: %s", len(out.Failed), aws.ToString(out.Failed[0].Message))
+		}
+	}
+	return nil
+}
+
+// kafkaSink writes the events to a Kafka topic, keyed by image id so that the events
+// of an image stay in order on the same partition.
+type kafkaSink struct {
+	w *kafka.Writer
+}
+
+func (s kafkaSink) send(ctx context.Context, events []imageEvent) error {
+	msgs := make([]kafka.Message, 0, len(events))
+	for _, ev := range events {
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return fmt.Errorf("could not encode event: %s", err)
+		}
+		msgs = append(msgs, kafka.Message{Key: []byte(ev.ImageID), Value: body})
+	}
+	return s.w.WriteMessages(ctx, msgs...)
+}
+
+// natsSink publishes the events to a NATS subject.
+type natsSink struct {
+	nc      *nats.Conn
+	subject string
+}
+
+func (s natsSink) send(ctx context.Context, events []imageEvent) error {
+	for _, ev := range events {
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return fmt.Errorf("could not encode event: %s", err)
+		}
+
+		if err := s.nc.Publish(s.subject, body); err != nil {
+			return err
+		}
+	}
+	return s.nc.FlushWithContext(ctx)
+}
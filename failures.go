@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// The classes of the failures of the changes, for the operators to tell an expired token apart
+// from an incident of Cloudflare without reading the errors.
+const (
+	failureAuth        = "auth"
+	failureNotFound    = "not-found"
+	failureRateLimited = "rate-limited"
+	failureServerError = "server-error"
+	failureNetwork     = "network"
+	failureOther       = "other"
+)
+
+// failureClasses are the classes in the order they are reported in.
+var failureClasses = []string{failureAuth, failureNotFound, failureRateLimited, failureServerError, failureNetwork, failureOther}
+
+// classifyFailure returns the class of the error of a failed change. The timeouts, of the
+// requests or of -image-timeout, are classified as network failures.
+func classifyFailure(err error) string {
+	var permErr *cloudflareclient.PermissionError
+	if errors.As(err, &permErr) {
+		return failureAuth
+	}
+
+	if code, ok := statusCode(err); ok {
+		switch {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return failureAuth
+		case code == http.StatusNotFound:
+			return failureNotFound
+		case code == http.StatusTooManyRequests:
+			return failureRateLimited
+		case code >= 500:
+			return failureServerError
+		default:
+			return failureOther
+		}
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errImageTimeout) {
+		return failureNetwork
+	}
+	return failureOther
+}
+
+// formatFailureClasses describes the number of failures of each class, e.g. "2 auth, 1 network".
+func formatFailureClasses(classes map[string]int) string {
+	var parts []string
+	for _, class := range failureClasses {
+		if n := classes[class]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, class))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/policy"
+)
+
+// metadataFlag collects repeated --metadata key=value flags.
+type metadataFlag map[string]string
+
+func (m metadataFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m metadataFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected key=value, got '%s'", value)
+	}
+	m[k] = v
+	return nil
+}
+
+// imageFilter selects the images the tool operates on.
+type imageFilter struct {
+	filenameGlob string
+	metadata     map[string]string
+	// excluded holds the ids of the images that are intentionally public.
+	excluded map[string]bool
+	// ids restricts the filter to an explicit set of images when not nil.
+	ids   map[string]bool
+	shard shard
+	// minAge leaves the images uploaded less than minAge ago as they are, giving the
+	// application uploading them time to set them up itself.
+	minAge time.Duration
+}
+
+// shard is the part of the images an instance operates on when a run is distributed
+// over several: the images whose id hashes to index modulo count.
+type shard struct {
+	index, count int
+}
+
+// String formats the shard as index/count, empty when the run isn't distributed.
+func (s shard) String() string {
+	if s.count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", s.index, s.count)
+}
+
+// parseShard parses a shard given as index/count, index going from 0 to count-1.
+func parseShard(value string) (shard, error) {
+	if value == "" {
+		return shard{}, nil
+	}
+
+	var s shard
+	i, n, ok := strings.Cut(value, "/")
+	index, err1 := strconv.Atoi(i)
+	count, err2 := strconv.Atoi(n)
+	if !ok || err1 != nil || err2 != nil || count < 1 || index < 0 || index >= count {
+		return s, fmt.Errorf("invalid shard '%s', expected index/count with index from 0 to count-1", value)
+	}
+
+	s.index, s.count = index, count
+	return s, nil
+}
+
+// owns reports whether the image belongs to the shard, always when the run isn't distributed.
+// The shard of an image depends on its id only, so it stays the same from run to run.
+func (s shard) owns(imageID string) bool {
+	if s.count <= 1 {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(imageID))
+	return int(h.Sum32()%uint32(s.count)) == s.index
+}
+
+func (f imageFilter) validate() error {
+	if f.filenameGlob != "" {
+		if _, err := path.Match(f.filenameGlob, ""); err != nil {
+			return fmt.Errorf("invalid filename glob '%s': %s", f.filenameGlob, err)
+		}
+	}
+
+	if f.minAge < 0 {
+		return fmt.Errorf("invalid min age %s, it can't be negative", f.minAge)
+	}
+	return nil
+}
+
+// match reports whether the image is one of the explicit ids, if any, belongs to the shard
+// and matches the filename glob and every metadata key=value pair of the filter.
+func (f imageFilter) match(image cloudflareclient.Image) bool {
+	if f.ids != nil && !f.ids[image.ID] {
+		return false
+	}
+
+	if !f.shard.owns(image.ID) {
+		return false
+	}
+
+	if f.filenameGlob != "" {
+		if ok, _ := path.Match(f.filenameGlob, image.Filename); !ok {
+			return false
+		}
+	}
+
+	for k, want := range f.metadata {
+		got, ok := image.Meta[k]
+		if !ok || fmt.Sprint(got) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// change is an update of the requireSignedURLs flag of an image, or its deletion.
+type change struct {
+	ImageID           string `json:"image_id"`
+	RequireSignedURLs bool   `json:"require_signed_urls"`
+
+	// meta is the metadata of the image when the change was worked out from the listing,
+	// nil when it isn't known. It isn't kept in plans, it would be stale by the time they are applied.
+	meta map[string]any
+	// alreadySecured is set on the changes found not needed when applied, the image
+	// requiring signed URLs already.
+	alreadySecured bool
+	// delete deletes the image, at the end of its quarantine, rather than updating it.
+	delete bool
+}
+
+// verb describes what applying the change does to the image.
+func (c change) verb() string {
+	if c.delete {
+		return "deleted"
+	}
+
+	if c.RequireSignedURLs {
+		return "secured"
+	}
+	return "made public"
+}
+
+// changes returns the changes needed for the images matching the filter
+// to reach the state desired by the policy, leaving out the ones of the
+// excluded images, of the draft images still being uploaded and of the images
+// uploaded less than minAge ago, which are returned separately.
+func (f imageFilter) changes(images []cloudflareclient.Image, p *policy.Policy) (changes []change, excluded, drafts, tooRecent []string) {
+	for _, image := range images {
+		if !f.match(image) {
+			continue
+		}
+
+		requireSignedURLs, ok := p.Desired(image)
+		if !ok || requireSignedURLs == image.RequireSignedURLs {
+			continue
+		}
+
+		if f.excluded[image.ID] {
+			excluded = append(excluded, image.ID)
+			continue
+		}
+
+		// Images in the middle of a direct upload can't be updated until it completes.
+		if image.Draft {
+			drafts = append(drafts, image.ID)
+			continue
+		}
+		if f.recent(image) {
+			tooRecent = append(tooRecent, image.ID)
+			continue
+		}
+		changes = append(changes, change{ImageID: image.ID, RequireSignedURLs: requireSignedURLs, meta: knownMeta(image)})
+	}
+	return changes, excluded, drafts, tooRecent
+}
+
+// recent reports whether the image was uploaded less than minAge ago.
+func (f imageFilter) recent(image cloudflareclient.Image) bool {
+	return f.minAge > 0 && !image.Uploaded.IsZero() && time.Since(image.Uploaded) < f.minAge
+}
+
+// knownMeta returns the metadata of the listed image, empty rather than nil when it has none.
+func knownMeta(image cloudflareclient.Image) map[string]any {
+	if image.Meta == nil {
+		return map[string]any{}
+	}
+	return image.Meta
+}
+
+// explicitChanges secures the explicit ids of the filter, without looking
+// at the account listing, leaving out the excluded ones which are returned separately.
+func (f imageFilter) explicitChanges(ids []string) (changes []change, excluded []string) {
+	for _, id := range ids {
+		if !f.shard.owns(id) {
+			continue
+		}
+
+		if f.excluded[id] {
+			excluded = append(excluded, id)
+			continue
+		}
+		changes = append(changes, change{ImageID: id, RequireSignedURLs: true})
+	}
+	return changes, excluded
+}
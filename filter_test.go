@@ -0,0 +1,44 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/policy"
+)
+
+func TestFilterChanges(t *testing.T) {
+	now := time.Now()
+	images := []cloudflareclient.Image{
+		{ID: "old", Uploaded: now.Add(-time.Hour)},
+		{ID: "recent", Uploaded: now.Add(-time.Minute)},
+		{ID: "draft", Draft: true, Uploaded: now.Add(-time.Hour)},
+		{ID: "recent-draft", Draft: true, Uploaded: now.Add(-time.Minute)},
+		{ID: "excluded", Uploaded: now.Add(-time.Minute)},
+		{ID: "protected", Uploaded: now.Add(-time.Minute), RequireSignedURLs: true},
+	}
+
+	f := imageFilter{excluded: map[string]bool{"excluded": true}, minAge: 30 * time.Minute}
+	changes, excluded, drafts, tooRecent := f.changes(images, policy.Default())
+
+	var changed []string
+	for _, c := range changes {
+		changed = append(changed, c.ImageID)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		got, want []string
+	}{
+		{"changes", changed, []string{"old"}},
+		{"excluded", excluded, []string{"excluded"}},
+		{"drafts", drafts, []string{"draft", "recent-draft"}},
+		{"too recent", tooRecent, []string{"recent"}},
+	} {
+		if !slices.Equal(tt.got, tt.want) {
+			t.Errorf("%s %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// githubAnnotationsLimit is the number of annotations of each kind GitHub Actions shows
+// for a step, the ones beyond are dropped.
+const githubAnnotationsLimit = 10
+
+// writeGitHubAnnotations writes the workflow commands annotating a GitHub Actions run with the
+// images failing to be secured, as errors, and the images remaining unprotected, as warnings.
+func writeGitHubAnnotations(w io.Writer, sum *runSummary) error {
+	failed := make(map[string]bool, len(sum.FailedIDs))
+	for _, id := range sum.FailedIDs {
+		failed[id] = true
+	}
+
+	var unprotected []string
+	for _, id := range sum.RemainingUnprotectedIDs {
+		if !failed[id] {
+			unprotected = append(unprotected, id)
+		}
+	}
+
+	var b strings.Builder
+	annotate := func(level, title string, ids []string, message string) {
+		for i, id := range ids {
+			if i == githubAnnotationsLimit-1 && len(ids) > githubAnnotationsLimit {
+				fmt.Fprintf(&b, "::%s title=%s::%d more images, see the run summary\n", level, githubEscapeProperty(title), len(ids)-i)
+				return
+			}
+			fmt.Fprintf(&b, "::%s title=%s::%s\n", level, githubEscapeProperty(title), githubEscape(fmt.Sprintf(message, id)))
+		}
+	}
+
+	annotate("error", "Image failed to be secured", sum.FailedIDs, "image %s failed to be secured and remains unprotected")
+	annotate("warning", "Image unprotected", unprotected, "image %s remains unprotected")
+
+	if sum.RemainingProtected > 0 {
+		fmt.Fprintf(&b, "::warning title=Images protected against the policy::%d images left protected against the policy\n", sum.RemainingProtected)
+	}
+	if sum.Interrupted {
+		b.WriteString("::warning title=Run interrupted::the run was interrupted before securing every image\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeGitHubStepSummary appends the summary as a markdown table to the job summary of
+// the GitHub Actions step, when running in one.
+func writeGitHubStepSummary(sum *runSummary) error {
+	name := os.Getenv("GITHUB_STEP_SUMMARY")
+	if name == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open step summary: %s", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Cloudflare Images of %s\n\n| | |\n|---|---|\n", sum.AccountID)
+	row := func(name string, value any) {
+		fmt.Fprintf(&b, "| %s | %v |\n", name, value)
+	}
+
+	if sum.Listed > 0 {
+		row("total images", sum.Listed)
+		row("already protected", sum.AlreadyProtected)
+	}
+	row("secured", sum.Secured)
+	if sum.MadePublic > 0 {
+		row("made public", sum.MadePublic)
+	}
+	row("failed", sum.Failed)
+	row("skipped", sum.Excluded+sum.Drafts+sum.TooRecent+sum.Skipped)
+	row("remaining unprotected", sum.RemainingUnprotected)
+	b.WriteString("\n")
+
+	if _, err := io.WriteString(f, b.String()); err != nil {
+		f.Close()
+		return fmt.Errorf("could not write step summary: %s", err)
+	}
+	return f.Close()
+}
+
+// githubEscape escapes the message of a workflow command.
+func githubEscape(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubEscapeProperty escapes a property of a workflow command.
+func githubEscapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/securerpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcService implements the gRPC API of the securer.
+type grpcService struct {
+	securerpc.UnimplementedSecureServiceServer
+
+	// ctx is the context of the runs started over gRPC, which outlive the requests.
+	ctx context.Context
+	s   *securer
+}
+
+func (g *grpcService) SecureAll(_ context.Context, _ *securerpc.SecureAllRequest) (*securerpc.SecureAllResponse, error) {
+	startedAt := time.Now()
+	if !g.s.tryPass(g.ctx) {
+		return nil, status.Error(codes.FailedPrecondition, "a run is in progress")
+	}
+	return &securerpc.SecureAllResponse{StartedAt: timestamppb.New(startedAt)}, nil
+}
+
+func (g *grpcService) SecureImages(ctx context.Context, req *securerpc.SecureImagesRequest) (*securerpc.SecureImagesResponse, error) {
+	if len(req.GetImageIds()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "image_ids is required")
+	}
+
+	// The images are secured like a pass, not alongside one changing them.
+	if !g.s.mu.TryLock() {
+		return nil, status.Error(codes.FailedPrecondition, "a run is in progress")
+	}
+	defer g.s.mu.Unlock()
+
+	start := time.Now()
+	usageStart := g.s.opts.usage.snapshot()
+	g.s.status.begin()
+
+	changes, excluded := g.s.sel.filter.explicitChanges(req.GetImageIds())
+	changes, drafts, tooRecent, unknown := checkExplicit(ctx, g.s.cli, g.s.sel.filter, changes)
+	g.s.status.planned(len(changes))
+
+	errs := map[string]error{}
+
+	a := g.s.opts.newApplier(g.s.cli)
+	if g.s.metrics != nil {
+		a.observe(g.s.metrics.observeChange)
+	}
+	a.observe(g.s.status.observe)
+	a.observe(func(c change, err error) {
+		errs[c.ImageID] = err
+	})
+	res := a.apply(ctx, changes)
+
+	// The run is reported by GetRunStatus like the passes, and the owners notified of it.
+	p := &planned{changes: changes, excluded: excluded, drafts: drafts, tooRecent: tooRecent, unknown: unknown}
+	sum := newRunSummary(g.s.opts.accountID, start, usageStart, p, res)
+	var runErr error
+	if ctx.Err() != nil {
+		sum.Interrupted = true
+		runErr = interruption(ctx)
+	}
+	g.s.status.end(sum, runErr)
+	// The owners are told of the images secured even when the caller went away meanwhile.
+	g.s.opts.ownerNotifier.notify(context.WithoutCancel(ctx), g.s.opts.accountID)
+
+	var resp securerpc.SecureImagesResponse
+	result := func(id string, outcome securerpc.ImageResult_Outcome, reason string) {
+		resp.Results = append(resp.Results, &securerpc.ImageResult{ImageId: id, Outcome: outcome, Error: reason})
+	}
+
+	for _, id := range excluded {
+		result(id, securerpc.ImageResult_OUTCOME_EXCLUDED, "")
+	}
+	for _, id := range drafts {
+		result(id, securerpc.ImageResult_OUTCOME_SKIPPED, "draft, upload not completed")
+	}
+	for _, id := range tooRecent {
+		result(id, securerpc.ImageResult_OUTCOME_SKIPPED, "uploaded less than -min-age ago")
+	}
+	for _, id := range unknown {
+		result(id, securerpc.ImageResult_OUTCOME_FAILED, "image not found")
+	}
+	for _, c := range res.applied {
+		result(c.ImageID, securerpc.ImageResult_OUTCOME_SECURED, "")
+	}
+	for _, c := range res.alreadySecured {
+		result(c.ImageID, securerpc.ImageResult_OUTCOME_ALREADY_SECURED, "")
+	}
+	for _, c := range res.failed {
+		result(c.ImageID, securerpc.ImageResult_OUTCOME_FAILED, errs[c.ImageID].Error())
+	}
+	for _, c := range res.skipped {
+		result(c.ImageID, securerpc.ImageResult_OUTCOME_SKIPPED, "not attempted: request cancelled")
+	}
+	return &resp, nil
+}
+
+func (g *grpcService) ListUnprotected(ctx context.Context, _ *securerpc.ListUnprotectedRequest) (*securerpc.ListUnprotectedResponse, error) {
+	images, err := g.s.cli.ListImages(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to list images: %s", err)
+	}
+
+	changes, _, _, _ := g.s.sel.filter.changes(images, g.s.sel.policy)
+
+	desired := make(map[string]bool, len(changes))
+	for _, c := range changes {
+		desired[c.ImageID] = c.RequireSignedURLs
+	}
+
+	var resp securerpc.ListUnprotectedResponse
+	for _, image := range images {
+		requireSignedURLs, ok := desired[image.ID]
+		if !ok {
+			continue
+		}
+
+		meta := make(map[string]string, len(image.Meta))
+		for k, v := range image.Meta {
+			meta[k] = fmt.Sprint(v)
+		}
+
+		resp.Images = append(resp.Images, &securerpc.Image{
+			Id:                       image.ID,
+			Filename:                 image.Filename,
+			Uploaded:                 timestamppb.New(image.Uploaded),
+			Meta:                     meta,
+			RequireSignedUrls:        image.RequireSignedURLs,
+			DesiredRequireSignedUrls: requireSignedURLs,
+		})
+	}
+	return &resp, nil
+}
+
+func (g *grpcService) GetRunStatus(_ context.Context, _ *securerpc.GetRunStatusRequest) (*securerpc.RunStatus, error) {
+	st := g.s.status.response()
+
+	resp := securerpc.RunStatus{
+		Running:   st.Running,
+		Total:     int32(st.Total),
+		Processed: int32(st.Processed),
+		Failed:    int32(st.Failed),
+		LastError: st.LastError,
+	}
+
+	if st.StartedAt != nil {
+		resp.StartedAt = timestamppb.New(*st.StartedAt)
+	}
+
+	if sum := g.s.status.lastSummary(); sum != nil {
+		resp.LastRun = &securerpc.RunSummary{
+			AccountId:            sum.AccountID,
+			StartedAt:            timestamppb.New(sum.StartedAt),
+			DurationSeconds:      sum.Duration.Seconds(),
+			Interrupted:          sum.Interrupted,
+			Secured:              int32(sum.Secured),
+			MadePublic:           int32(sum.MadePublic),
+			Failed:               int32(sum.Failed),
+			Skipped:              int32(sum.Skipped),
+			Excluded:             int32(sum.Excluded),
+			Drafts:               int32(sum.Drafts),
+			TooRecent:            int32(sum.TooRecent),
+			RemainingUnprotected: int32(sum.RemainingUnprotected),
+			RemainingProtected:   int32(sum.RemainingProtected),
+			FailedIds:            sum.FailedIDs,
+		}
+	}
+	return &resp, nil
+}
+
+// serveGRPC serves the gRPC API of the securer on the address until the context is done.
+func serveGRPC(ctx context.Context, addr string, s *securer) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		slog.Error("grpc server failed", "addr", addr, "error", err)
+		return
+	}
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(s.opts.errReporter.unaryInterceptor(s.opts.accountID)))
+	securerpc.RegisterSecureServiceServer(srv, &grpcService{ctx: ctx, s: s})
+
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+
+	slog.Info("grpc listening", "addr", addr)
+	if err := srv.Serve(lis); err != nil {
+		slog.Error("grpc server failed", "addr", addr, "error", err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/cloudflareclient/cloudflaretest"
+	"github.com/alesr/securecloudflareimage/securerpc"
+)
+
+func TestGRPCSecureImagesReported(t *testing.T) {
+	srv := cloudflaretest.NewServer("account", "token",
+		cloudflareclient.Image{ID: "a", Meta: map[string]any{"owner": "alice@example.com"}},
+		cloudflareclient.Image{ID: "b", Meta: map[string]any{"owner": "bob@example.com"}, RequireSignedURLs: true},
+	)
+	defer srv.Close()
+
+	var (
+		mu       sync.Mutex
+		notified []ownerEvent
+	)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev ownerEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("invalid owner event: %s", err)
+		}
+		mu.Lock()
+		notified = append(notified, ev)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	s := &securer{
+		cli: srv.Client(),
+		opts: &options{
+			accountID:     "account",
+			concurrency:   2,
+			maxChanges:    -1,
+			ownerNotifier: &ownerNotifier{keys: []string{"owner"}, webhook: webhook.URL, secured: map[string][]string{}},
+		},
+		sel:     &selection{},
+		status:  &runStatus{},
+		metrics: &metrics{},
+	}
+	g := &grpcService{ctx: context.Background(), s: s}
+
+	if _, err := g.SecureImages(context.Background(), &securerpc.SecureImagesRequest{ImageIds: []string{"a", "b", "missing"}}); err != nil {
+		t.Fatalf("SecureImages: %s", err)
+	}
+
+	st, err := g.GetRunStatus(context.Background(), &securerpc.GetRunStatusRequest{})
+	if err != nil {
+		t.Fatalf("GetRunStatus: %s", err)
+	}
+	if st.Running || st.StartedAt == nil {
+		t.Errorf("status running %t, started at %v, want a finished run", st.Running, st.StartedAt)
+	}
+	if st.LastRun == nil {
+		t.Fatal("no last run after SecureImages")
+	}
+	if st.LastRun.Secured != 1 {
+		t.Errorf("last run secured %d images, want 1", st.LastRun.Secured)
+	}
+
+	if n := s.metrics.imagesSecured.Load() + s.metrics.imagesFailed.Load(); n != 2 {
+		t.Errorf("metrics count %d changes, want 2", n)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(notified) != 1 || notified[0].Owner != "alice@example.com" || len(notified[0].ImageIDs) != 1 || notified[0].ImageIDs[0] != "a" {
+		t.Errorf("owners notified %+v, want alice@example.com of a", notified)
+	}
+}
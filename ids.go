@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// readIDs reads one image id per line, ignoring blank lines and lines starting with '#'.
+// The lines can also be delivery URLs, from access logs or CSP reports, the ids being parsed
+// out of them and the URLs of another account hash than accountHash, if given, skipped.
+// The ids are returned once, in the order they first appear.
+func readIDs(r io.Reader, accountHash string) ([]string, error) {
+	var (
+		ids  []string
+		seen = map[string]bool{}
+	)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id := line
+		if isURL(line) {
+			hash, imageID, err := parseDeliveryURL(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+
+			if accountHash != "" && hash != accountHash {
+				slog.Warn("skipping delivery url of another account hash", "url", line, "account_hash", hash)
+				continue
+			}
+			id = imageID
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read ids: %s", err)
+	}
+	return ids, nil
+}
+
+// readIDsFile reads image ids from the file at the given path, or from stdin if the path is "-".
+func readIDsFile(name, accountHash string) ([]string, error) {
+	if name == "-" {
+		return readIDs(os.Stdin, accountHash)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not open ids file: %s", err)
+	}
+	defer f.Close()
+
+	return readIDs(f, accountHash)
+}
+
+// splitList splits a comma separated list, dropping the empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// confirmer asks the operator to approve the changes of a run before they are applied,
+// all at once or one image at a time.
+type confirmer struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newConfirmer(in io.Reader, out io.Writer) *confirmer {
+	return &confirmer{in: bufio.NewReader(in), out: out}
+}
+
+// confirm shows the changes with the details of their image, when listed,
+// and returns the ones approved.
+func (c *confirmer) confirm(p *planned) ([]change, error) {
+	if len(p.changes) == 0 {
+		return nil, nil
+	}
+
+	images := map[string]cloudflareclient.Image{}
+	for _, img := range p.images {
+		images[img.ID] = img
+	}
+
+	fmt.Fprintf(c.out, "%d images will be changed:\n", len(p.changes))
+	for _, ch := range p.changes {
+		fmt.Fprintf(c.out, "  %s\n", describeChange(ch, images))
+	}
+
+	answer, err := c.ask("apply these changes? [y]es, [n]o, [s]elect one by one: ", "y", "n", "s")
+	if err != nil {
+		return nil, err
+	}
+
+	switch answer {
+	case "y":
+		return p.changes, nil
+	case "n":
+		slog.Info("changes not approved, nothing to do")
+		return nil, nil
+	}
+
+	var approved []change
+	for i, ch := range p.changes {
+		answer, err := c.ask(fmt.Sprintf("%s? [y]es, [n]o, [a]ll remaining, [q]uit: ", describeChange(ch, images)), "y", "n", "a", "q")
+		if err != nil {
+			return nil, err
+		}
+
+		switch answer {
+		case "y":
+			approved = append(approved, ch)
+		case "n":
+			slog.Info("skipping image: not approved", "image_id", ch.ImageID)
+		case "a":
+			return append(approved, p.changes[i:]...), nil
+		case "q":
+			return approved, nil
+		}
+	}
+	return approved, nil
+}
+
+// ask prompts until one of the choices is answered.
+func (c *confirmer) ask(prompt string, choices ...string) (string, error) {
+	for {
+		fmt.Fprint(c.out, prompt)
+
+		line, err := c.in.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		for _, choice := range choices {
+			if answer == choice || (answer != "" && strings.HasPrefix(choiceWord(choice), answer)) {
+				return choice, nil
+			}
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", errors.New("no answer, the confirmation was aborted")
+			}
+			return "", fmt.Errorf("could not read answer: %s", err)
+		}
+	}
+}
+
+// choiceWord returns the word a choice letter stands for, so the whole word can be answered.
+func choiceWord(choice string) string {
+	return map[string]string{"y": "yes", "n": "no", "s": "select", "a": "all", "q": "quit"}[choice]
+}
+
+// describeChange describes the change with the filename, upload date and delivery URL of the image, if known.
+func describeChange(ch change, images map[string]cloudflareclient.Image) string {
+	desc := fmt.Sprintf("%s will be %s", ch.ImageID, ch.verb())
+
+	img, ok := images[ch.ImageID]
+	if !ok {
+		return desc
+	}
+
+	details := []string{}
+	if img.Filename != "" {
+		details = append(details, img.Filename)
+	}
+	if !img.Uploaded.IsZero() {
+		details = append(details, "uploaded "+img.Uploaded.Format(time.DateOnly))
+	}
+	if len(img.Variants) > 0 {
+		details = append(details, img.Variants[0])
+	}
+
+	if len(details) == 0 {
+		return desc
+	}
+	return fmt.Sprintf("%s (%s)", desc, strings.Join(details, ", "))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+const inventoryVersion = 1
+
+// inventory is a snapshot of the images of an account at a point in time.
+type inventory struct {
+	Version   int                      `json:"version"`
+	AccountID string                   `json:"account_id"`
+	TakenAt   time.Time                `json:"taken_at"`
+	Images    []cloudflareclient.Image `json:"images"`
+}
+
+func newInventory(accountID string, images []cloudflareclient.Image) *inventory {
+	return &inventory{
+		Version:   inventoryVersion,
+		AccountID: accountID,
+		TakenAt:   time.Now().UTC(),
+		Images:    images,
+	}
+}
+
+// byID indexes the images of the inventory by id.
+func (inv *inventory) byID() map[string]cloudflareclient.Image {
+	m := make(map[string]cloudflareclient.Image, len(inv.Images))
+	for _, image := range inv.Images {
+		m[image.ID] = image
+	}
+	return m
+}
+
+func writeInventory(name string, inv *inventory) error {
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return fmt.Errorf("could not encode inventory: %s", err)
+	}
+
+	// Write to a temporary file first so an interrupted write doesn't lose the previous inventory.
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not write inventory: %s", err)
+	}
+
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("could not write inventory: %s", err)
+	}
+	return nil
+}
+
+func readInventory(name string) (*inventory, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read inventory: %s", err)
+	}
+
+	var inv inventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("could not decode inventory: %s", err)
+	}
+
+	if inv.Version != inventoryVersion {
+		return nil, fmt.Errorf("unsupported inventory version %d", inv.Version)
+	}
+	return &inv, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// errLocked is returned when another run holds the lock.
+var errLocked = errors.New("lock held by another run")
+
+// runLock is a lock file held for the duration of a run, so that overlapping
+// invocations don't process the same account twice. The lock is taken on the
+// open file rather than by its existence, a crashed run doesn't leave it held.
+type runLock struct {
+	f *os.File
+}
+
+// acquireLock takes the lock of the file, creating it if needed, failing right away
+// with errLocked if another run holds it.
+func acquireLock(name string) (*runLock, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %s", err)
+	}
+
+	if err := lockFile(f); err != nil {
+		holder, _ := os.ReadFile(name)
+		f.Close()
+
+		if errors.Is(err, errLocked) {
+			if pid := strings.TrimSpace(string(holder)); pid != "" {
+				return nil, fmt.Errorf("%w %s, pid %s", errLocked, name, pid)
+			}
+			return nil, fmt.Errorf("%w %s", errLocked, name)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %s", name, err)
+	}
+
+	// The pid tells who holds the lock, for the operator wondering.
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &runLock{f: f}, nil
+}
+
+// release releases the lock, leaving the file for the next run.
+func (l *runLock) release() {
+	if l == nil {
+		return
+	}
+
+	l.f.Truncate(0)
+	unlockFile(l.f)
+	l.f.Close()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// logFileConfig holds the flags writing the logs to a file rotated by size and time,
+// for daemons running on plain VMs not to fill the disk nor lose the history.
+type logFileConfig struct {
+	name        string
+	maxSizeMB   int
+	maxBackups  int
+	maxAge      time.Duration
+	rotateEvery time.Duration
+	compress    bool
+}
+
+func (c *logFileConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.name, "log-file", "", "file to write the logs to instead of stderr, rotated by size and time")
+	fs.IntVar(&c.maxSizeMB, "log-max-size", 100, "size in megabytes the -log-file is rotated at")
+	fs.DurationVar(&c.rotateEvery, "log-rotate", 0, "rotate the -log-file at this interval regardless of its size (e.g. 24h), 0 to only rotate by size")
+	fs.IntVar(&c.maxBackups, "log-max-backups", 7, "number of rotated log files kept, 0 to keep them all")
+	fs.DurationVar(&c.maxAge, "log-max-age", 0, "remove the rotated log files older than this (e.g. 720h), rounded up to days, 0 to keep them")
+	fs.BoolVar(&c.compress, "log-compress", false, "gzip the rotated log files")
+}
+
+func (c *logFileConfig) validate() error {
+	if c.name == "" {
+		return nil
+	}
+
+	if c.maxSizeMB < 1 {
+		return errors.New("-log-max-size must be at least 1")
+	}
+
+	if c.maxBackups < 0 || c.maxAge < 0 || c.rotateEvery < 0 {
+		return errors.New("-log-max-backups, -log-max-age and -log-rotate cannot be negative")
+	}
+	return nil
+}
+
+// open returns the writer of the log file, rotating it every -log-rotate in the background.
+func (c *logFileConfig) open() io.Writer {
+	l := &lumberjack.Logger{
+		Filename:   c.name,
+		MaxSize:    c.maxSizeMB,
+		MaxBackups: c.maxBackups,
+		MaxAge:     int((c.maxAge + 24*time.Hour - 1) / (24 * time.Hour)),
+		Compress:   c.compress,
+		LocalTime:  true,
+	}
+
+	if c.rotateEvery > 0 {
+		go func() {
+			for range time.Tick(c.rotateEvery) {
+				if err := l.Rotate(); err != nil {
+					slog.Error("failed to rotate log file", "file", c.name, "error", err)
+				}
+			}
+		}()
+	}
+	return l
+}
+
+// setupLogging makes the default slog logger write to out in the given format, from the given level.
+func setupLogging(out io.Writer, format, level string) error {
+	h, err := newLogHandler(out, format, level, false)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// newLogHandler returns a handler writing to out in the given format, from the given level,
+// without the time of the records if omitTime is set.
+func newLogHandler(out io.Writer, format, level string, omitTime bool) (slog.Handler, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level '%s'", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	if omitTime {
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		}
+	}
+
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.NewTextHandler(out, opts), nil
+	case "json":
+		return slog.NewJSONHandler(out, opts), nil
+	default:
+		return nil, fmt.Errorf("invalid log format '%s', expected text or json", format)
+	}
+}
+
+// statusCode returns the status code of the API response the error came from, if any.
+func statusCode(err error) (int, bool) {
+	var statusErr *cloudflareclient.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, true
+	}
+	return 0, false
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// logSink receives the formatted log lines with their level, for the targets with
+// severities of their own.
+type logSink func(level slog.Level, line string) error
+
+// setupSinkLogging makes the default slog logger send the records to syslog or the
+// systemd journal, in the given format without their time, the target timestamping them.
+func setupSinkLogging(target, format, level string) error {
+	var (
+		sink logSink
+		err  error
+	)
+
+	switch target {
+	case "syslog":
+		sink, err = openSyslog()
+	case "journald":
+		sink, err = openJournald()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %s", target, err)
+	}
+
+	buf := &bytes.Buffer{}
+	h, err := newLogHandler(buf, format, level, true)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(&sinkHandler{inner: h, buf: buf, mu: &sync.Mutex{}, sink: sink}))
+	return nil
+}
+
+// sinkHandler formats the records with the inner handler, writing to buf, and hands
+// the line to the sink with the level of the record. The handlers derived with WithAttrs
+// and WithGroup share the buffer and the mutex guarding it.
+type sinkHandler struct {
+	inner slog.Handler
+	buf   *bytes.Buffer
+	mu    *sync.Mutex
+	sink  logSink
+}
+
+func (h *sinkHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *sinkHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buf.Reset()
+	if err := h.inner.Handle(ctx, r); err != nil {
+		return err
+	}
+	return h.sink(r.Level, strings.TrimSuffix(h.buf.String(), "\n"))
+}
+
+func (h *sinkHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sinkHandler{inner: h.inner.WithAttrs(attrs), buf: h.buf, mu: h.mu, sink: h.sink}
+}
+
+func (h *sinkHandler) WithGroup(name string) slog.Handler {
+	return &sinkHandler{inner: h.inner.WithGroup(name), buf: h.buf, mu: h.mu, sink: h.sink}
+}
+
+// journaldSocket is where the systemd journal receives the entries of the native protocol.
+const journaldSocket = "/run/systemd/journal/socket"
+
+// openJournald returns a sink sending the lines to the systemd journal with the native
+// protocol, with their priority and the name of the tool as identifier.
+// https://systemd.io/JOURNAL_NATIVE_PROTOCOL/
+func openJournald() (logSink, error) {
+	conn, err := net.Dial("unixgram", journaldSocket)
+	if err != nil {
+		return nil, err
+	}
+
+	identifier := filepath.Base(os.Args[0])
+	return func(level slog.Level, line string) error {
+		var entry bytes.Buffer
+		writeJournalField(&entry, "MESSAGE", line)
+		writeJournalField(&entry, "PRIORITY", fmt.Sprint(syslogPriority(level)))
+		writeJournalField(&entry, "SYSLOG_IDENTIFIER", identifier)
+
+		_, err := conn.Write(entry.Bytes())
+		return err
+	}, nil
+}
+
+// writeJournalField writes a field of a journal entry, the values spanning several lines
+// being prefixed with their length.
+func writeJournalField(w *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		w.WriteString(key + "=" + value + "\n")
+		return
+	}
+
+	w.WriteString(key + "\n")
+	binary.Write(w, binary.LittleEndian, uint64(len(value)))
+	w.WriteString(value + "\n")
+}
+
+// syslogPriority maps the level of a record to a syslog severity.
+func syslogPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
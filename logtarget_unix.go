@@ -0,0 +1,32 @@
+//go:build unix
+
+package main
+
+import (
+	"log/slog"
+	"log/syslog"
+	"os"
+	"path/filepath"
+)
+
+// openSyslog returns a sink sending the lines to the local syslog daemon, with the
+// severity of their level and the name of the tool as tag.
+func openSyslog() (logSink, error) {
+	w, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, filepath.Base(os.Args[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(level slog.Level, line string) error {
+		switch syslogPriority(level) {
+		case 3:
+			return w.Err(line)
+		case 4:
+			return w.Warning(line)
+		case 6:
+			return w.Info(line)
+		default:
+			return w.Debug(line)
+		}
+	}, nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "errors"
+
+// openSyslog fails, there is no syslog on windows.
+func openSyslog() (logSink, error) {
+	return nil, errors.New("syslog is not available on windows")
+}
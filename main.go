@@ -1,61 +1,161 @@
 package main
 
 import (
-	"flag"
-	"log"
-	"net/http"
-	"sync"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"go.opentelemetry.io/otel/codes"
 )
 
-func main() {
-	cloudflareAccountIDPtr := flag.String("account-id", "", "cloudflare account id")
-	cloudflareAPIKeyPtr := flag.String("api-key", "", "cloudflare api key")
-	flag.Parse()
+// command is a subcommand of the tool.
+type command struct {
+	name string
+	help string
+	run  func(ctx context.Context, args []string) error
+}
 
-	if *cloudflareAccountIDPtr == "" || *cloudflareAPIKeyPtr == "" {
-		flag.Usage()
-		return
+var commands = []command{
+	{name: "secure", help: "bring the images to the desired state (default)", run: secureCmd},
+	{name: "plan", help: "write the changes secure would make to a plan file", run: planCmd},
+	{name: "apply", help: "execute the changes of a plan file", run: applyCmd},
+	{name: "retry", help: "re-attempt the changes that failed in a previous run", run: retryCmd},
+	{name: "migrate", help: "snapshot the account, create a signing key, secure the images in canary batches and report", run: migrateCmd},
+	{name: "ingest-logs", help: "secure the images the access logs show served without a signed url", run: ingestLogsCmd},
+	{name: "drift", help: "report images that drifted from the desired state since a stored inventory", run: driftCmd},
+	{name: "snapshot", help: "write the inventory of the account to a file", run: snapshotCmd},
+	{name: "diff", help: "show the images added, removed and whose protection flipped between two inventories", run: diffCmd},
+	{name: "stats", help: "show the images stored against the plan allowance and how many are protected", run: statsCmd},
+	{name: "check", help: "print the number of images unprotected against the policy, for monitoring", run: checkCmd},
+	{name: "verify-url", help: "check the signature of a signed delivery url and when it expires", run: verifyURLCmd},
+	{name: "sign-urls", help: "write signed delivery urls of the images, expiring after a ttl, as csv or json", run: signURLsCmd},
+	{name: "variants", help: "list the variants of the images of the account, or of an image", run: variantsCmd},
+	{name: "audit", help: "verify the chain of hashes of an audit log written with -audit-log", run: auditCmd},
+	{name: "report", help: "write a markdown or html report of the protection of the account", run: reportCmd},
+	{name: "browse", help: "browse the images in a terminal ui and secure a selection of them", run: browseCmd},
+	{name: "config", help: "validate the flags of secure, its policy and secrets, without contacting cloudflare", run: configCmd},
+	{name: "doctor", help: "check the credentials, the account and its images subscription before running in production", run: doctorCmd},
+	{name: "bench", help: "measure the throughput of the securing pipeline against a fake server", run: benchCmd},
+	{name: "version", help: "print the version of the tool", run: versionCmd},
+}
+
+func init() {
+	// The completion command lists the commands, it can't be listed with them.
+	commands = append(commands, command{name: "completion", help: "print the completion script of bash, zsh or fish", run: completionCmd})
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [command] [flags]\n\ncommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.help)
 	}
+	fmt.Fprintf(os.Stderr, "\nrun '%s <command> -h' for the flags of a command\n", os.Args[0])
+}
 
-	httpCli := http.DefaultClient
-	httpCli.Timeout = time.Second * 15
+func main() {
+	args := os.Args[1:]
 
-	cloudflareCli := cloudflareclient.New(httpCli, *cloudflareAccountIDPtr, *cloudflareAPIKeyPtr)
+	// Without a command, or when it starts with flags, the secure command runs.
+	name := "secure"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	unprotectedImages, err := cloudflareCli.GetUnprotectedImages()
-	if err != nil {
-		log.Fatalln("failed to get unprotected images:", err)
+	if len(args) > 0 && (args[0] == "-version" || args[0] == "--version") {
+		name = "version"
 	}
 
-	var wg sync.WaitGroup
+	if name == "help" {
+		usage()
+		return
+	}
 
-	for _, imageID := range unprotectedImages {
-		wg.Add(1)
+	// Interrupting cancels the context, letting the commands wrap up and report what they did.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		go func(wg *sync.WaitGroup, id string) {
-			defer wg.Done()
-			if err := cloudflareCli.SecureImage(id); err != nil {
-				log.Printf("failed to secure image '%s': %s", id, err)
-				return
+	for _, c := range commands {
+		if c.name == name {
+			if err := runCommand(ctx, c, args); err != nil {
+				stop()
+				slog.Error(err.Error())
+				os.Exit(exitCode(err))
 			}
+			return
+		}
+	}
+
+	usage()
+	os.Exit(exitUsage)
+}
+
+// The exit codes of the tool, for the schedulers and pipelines running it to tell the outcomes apart.
+const (
+	exitOK = 0
+	// exitFailed is for the runs failing, to connect, list the images or otherwise.
+	exitFailed = 1
+	// exitUsage is for the unknown commands and the flags failing to parse.
+	exitUsage = 2
+	// exitGate is for the runs not meeting -fail-if-unprotected or -max-failures.
+	exitGate = 3
+	// exitInterrupted is for the runs interrupted by a signal.
+	exitInterrupted = 4
+	// exitTimedOut is for the runs stopped by -run-timeout.
+	exitTimedOut = 5
+)
 
-			log.Printf("successfully secured image '%s'", id)
-		}(&wg, imageID)
+// exitCode returns the exit code of the outcome of a command.
+func exitCode(err error) int {
+	var (
+		gateErr   gateError
+		nagiosErr nagiosError
+	)
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &nagiosErr):
+		return nagiosErr.code
+	case errors.As(err, &gateErr):
+		return exitGate
+	case errors.Is(err, errRunTimeout):
+		return exitTimedOut
+	case errors.Is(err, errInterrupted):
+		return exitInterrupted
+	default:
+		return exitFailed
 	}
-	wg.Wait()
+}
 
-	// Fetch gain to see if they are still unprotected images left.
-	unprotectedImages, err = cloudflareCli.GetUnprotectedImages()
+// runCommand runs the command within its span, flushing the spans before returning.
+func runCommand(ctx context.Context, c command, args []string) error {
+	shutdownTracing, err := setupTracing(ctx)
 	if err != nil {
-		log.Fatalln("failed to get images id:", err)
+		return fmt.Errorf("failed to set up tracing: %s", err)
 	}
 
-	if len(unprotectedImages) > 0 {
-		log.Printf("%d images left unprotected", len(unprotectedImages))
-	}
+	defer func() {
+		// The run context may be cancelled already, the spans are flushed regardless.
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
 
-	log.Println("done")
+	ctx, span := startCommandSpan(ctx, c.name)
+	defer span.End()
+
+	err = c.run(ctx, args)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
 }
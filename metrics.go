@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// metrics are the counters of a long running process, exposed in the prometheus text format.
+type metrics struct {
+	imagesScanned     atomic.Int64
+	imagesSecured     atomic.Int64
+	imagesMadePublic  atomic.Int64
+	imagesFailed      atomic.Int64
+	apiRequests       atomic.Int64
+	apiErrors         atomic.Int64
+	apiRateLimited    atomic.Int64
+	imagesUnprotected atomic.Int64
+}
+
+// The methods below are no-ops on nil metrics, so callers don't need to check they are enabled.
+
+func (m *metrics) scanned(n int) {
+	if m == nil {
+		return
+	}
+	m.imagesScanned.Add(int64(n))
+}
+
+func (m *metrics) observeChange(c change, err error) {
+	if m == nil {
+		return
+	}
+
+	switch {
+	case err != nil:
+		m.imagesFailed.Add(1)
+	case c.RequireSignedURLs:
+		m.imagesSecured.Add(1)
+	default:
+		m.imagesMadePublic.Add(1)
+	}
+}
+
+// setRemaining sets the unprotected images gauge from the changes still pending.
+func (m *metrics) setRemaining(remaining []change) {
+	if m == nil {
+		return
+	}
+
+	var unprotected int64
+	for _, c := range remaining {
+		if c.RequireSignedURLs {
+			unprotected++
+		}
+	}
+	m.imagesUnprotected.Store(unprotected)
+}
+
+// transport wraps an http transport to count the requests made to the API and their errors.
+func (m *metrics) transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		m.apiRequests.Add(1)
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			m.apiErrors.Add(1)
+			return nil, err
+		}
+
+		if resp.StatusCode >= 400 {
+			m.apiErrors.Add(1)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			m.apiRateLimited.Add(1)
+		}
+		return resp, nil
+	})
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	write := func(name, kind, help string, v int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, v)
+	}
+
+	write("securecloudflareimg_images_scanned_total", "counter", "Images listed from the account.", m.imagesScanned.Load())
+	write("securecloudflareimg_images_secured_total", "counter", "Images updated to require signed URLs.", m.imagesSecured.Load())
+	write("securecloudflareimg_images_made_public_total", "counter", "Images updated to not require signed URLs.", m.imagesMadePublic.Load())
+	write("securecloudflareimg_images_failed_total", "counter", "Image updates that failed.", m.imagesFailed.Load())
+	write("securecloudflareimg_api_requests_total", "counter", "Requests made to the Cloudflare API.", m.apiRequests.Load())
+	write("securecloudflareimg_api_errors_total", "counter", "Requests to the Cloudflare API that failed or got an error status.", m.apiErrors.Load())
+	write("securecloudflareimg_api_rate_limited_total", "counter", "Requests to the Cloudflare API rejected with 429 Too Many Requests.", m.apiRateLimited.Load())
+	write("securecloudflareimg_images_unprotected", "gauge", "Images left unprotected at the end of the last pass.", m.imagesUnprotected.Load())
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// serve serves the handler on the address until the context is done.
+func serve(ctx context.Context, addr string, handler http.Handler) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("listening", "addr", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("server failed", "addr", addr, "error", err)
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+type migratingKey struct{}
+
+// migrating tells whether secure runs for migrate, the pass being wrapped in the snapshots,
+// the signing key and the report of the rollout.
+func migrating(ctx context.Context) bool {
+	v, _ := ctx.Value(migratingKey{}).(bool)
+	return v
+}
+
+// migrateCmd packages the rollout of the signed URLs: it snapshots the account, creates the
+// signing key or reuses the existing one before any image is secured, secures the images in
+// canary batches, rotating a reused key with -rotate-key only once the canary is confirmed,
+// signs example URLs with the key and writes a report of the migration, taking the flags of
+// secure along with its own.
+func migrateCmd(ctx context.Context, args []string) error {
+	return secureCmd(context.WithValue(ctx, migratingKey{}, true), args)
+}
+
+const (
+	// defaultMigrationCanary is the canary of the migrations not given -canary.
+	defaultMigrationCanary = 5
+	// migrationExamplesTTL is how long the example signed URLs of the report are valid for.
+	migrationExamplesTTL = 24 * time.Hour
+)
+
+// migrationConfig holds the flags of migrate, and the state of the migration along the pass.
+type migrationConfig struct {
+	dir         string
+	keyName     string
+	examples    int
+	variant     string
+	deliveryURL string
+	rotateKey   bool
+
+	startedAt time.Time
+	before    *inventory
+	key       *cloudflareclient.SigningKey
+	// keyAction is what was done with the signing key: created, reused or, once confirmed, rotated.
+	keyAction string
+}
+
+func (c *migrationConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.dir, "migration-dir", "", "directory to write the snapshots, the example signed urls and the report of the migration to")
+	fs.StringVar(&c.keyName, "migration-key-name", "migration-"+time.Now().UTC().Format("20060102"), "name of the signing key to sign the urls with, created before any image is secured, or reused if it exists")
+	fs.BoolVar(&c.rotateKey, "rotate-key", false, "rotate the -migration-key-name signing key if it exists once the canary is confirmed, the urls signed with its previous value no longer being valid")
+	fs.IntVar(&c.examples, "examples", 5, "number of secured images to sign example urls of with the new key")
+	fs.StringVar(&c.variant, "variant", "public", "variant of the images the example urls are for")
+	fs.StringVar(&c.deliveryURL, "delivery-url", "https://"+deliveryHost, "where the images are delivered from, or https://<zone>/cdn-cgi/imagedelivery to serve them from a zone of the account")
+}
+
+func (c *migrationConfig) validate() error {
+	switch {
+	case c.dir == "":
+		return errors.New("-migration-dir is required")
+	case c.keyName == "":
+		return errors.New("-migration-key-name is required")
+	case c.examples < 0:
+		return errors.New("-examples cannot be negative")
+	case c.variant == "":
+		return errors.New("-variant is required")
+	}
+
+	if _, err := c.deliveryBase(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// deliveryBase returns the -delivery-url the example URLs are for.
+func (c *migrationConfig) deliveryBase() (*url.URL, error) {
+	base, err := url.Parse(strings.TrimSuffix(c.deliveryURL, "/"))
+	if err != nil || (base.Scheme != "https" && base.Scheme != "http") || base.Host == "" {
+		return nil, fmt.Errorf("-delivery-url must be an http or https url: %q", c.deliveryURL)
+	}
+	return base, nil
+}
+
+// begin snapshots the account into before.json and makes the signing key ready before any
+// image is secured, for the images not to require signed URLs nobody can sign.
+func (c *migrationConfig) begin(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI, accountID string) error {
+	c.startedAt = time.Now()
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("could not create migration directory: %s", err)
+	}
+
+	images, err := cli.ListImages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %s", err)
+	}
+
+	c.before = newInventory(accountID, images)
+	if err := writeInventory(filepath.Join(c.dir, "before.json"), c.before); err != nil {
+		return err
+	}
+	slog.Info("account snapshotted before the migration", "images", len(images), "file", filepath.Join(c.dir, "before.json"))
+
+	return c.ensureKey(ctx, cli)
+}
+
+// ensureKey makes the signing key ready: the existing key of that name is reused, for a
+// migration run again not to invalidate the URLs already signed with it, or created.
+func (c *migrationConfig) ensureKey(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI) error {
+	keys, err := cli.SigningKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list signing keys: %s", err)
+	}
+
+	if i := slices.IndexFunc(keys, func(k cloudflareclient.SigningKey) bool { return k.Name == c.keyName }); i >= 0 {
+		c.key, c.keyAction = &keys[i], "reused"
+		slog.Info("reusing the signing key", "key", c.keyName)
+		return nil
+	}
+
+	key, err := cli.CreateSigningKey(ctx, c.keyName)
+	if err != nil {
+		return fmt.Errorf("failed to create signing key '%s': %s", c.keyName, err)
+	}
+	c.key, c.keyAction = key, "created"
+	slog.Info("signing key ready", "key", c.keyName, "action", c.keyAction)
+	return nil
+}
+
+// rotate rotates the existing signing key with -rotate-key, once the canary is confirmed or
+// after a clean run. The key created by the migration is left as it is.
+func (c *migrationConfig) rotate(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI) error {
+	if !c.rotateKey || c.keyAction != "reused" {
+		return nil
+	}
+
+	slog.Warn("rotating the signing key, the urls signed with its previous value stop working", "key", c.keyName)
+	key, err := cli.CreateSigningKey(ctx, c.keyName)
+	if err != nil {
+		return fmt.Errorf("failed to rotate signing key '%s': %s", c.keyName, err)
+	}
+	c.key, c.keyAction = key, "rotated"
+	slog.Info("signing key rotated", "key", c.keyName)
+	return nil
+}
+
+// finish snapshots the account into after.json, signs the example URLs of the images secured
+// by the pass into signed-urls.csv and writes report.md. The report is written whatever the
+// outcome of the pass, runErr, for the operator to tell where the migration stopped.
+func (c *migrationConfig) finish(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI, accountHash string, sum *runSummary, runErr error) error {
+	// The pass may have been interrupted, the migration is wrapped up regardless.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+
+	var after *inventory
+	if images, err := cli.ListImages(ctx); err != nil {
+		slog.Error("failed to snapshot the account after the migration", "error", err)
+	} else {
+		after = newInventory(c.before.AccountID, images)
+		if err := writeInventory(filepath.Join(c.dir, "after.json"), after); err != nil {
+			slog.Error("failed to snapshot the account after the migration", "error", err)
+		}
+	}
+
+	// The key is rotated after any clean run, neither interrupted nor skipping or failing an image,
+	// canary or not; rotate does nothing if the confirmed canary has already rotated it.
+	if runErr == nil && sum != nil && !sum.Interrupted && sum.Skipped == 0 && sum.Failed == 0 {
+		if err := c.rotate(ctx, cli); err != nil {
+			slog.Error(err.Error())
+		}
+	}
+
+	var secured []string
+	if sum != nil {
+		secured = sum.SecuredIDs
+	}
+
+	examples, err := c.signExamples(ctx, cli, accountHash, secured)
+	if err != nil {
+		slog.Error("failed to sign example urls", "error", err)
+	} else if err := c.writeExamples(examples); err != nil {
+		slog.Error("failed to write example urls", "error", err)
+	}
+
+	name := filepath.Join(c.dir, "report.md")
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("could not write migration report: %s", err)
+	}
+	defer f.Close()
+
+	if err := c.writeReport(f, after, sum, examples, runErr); err != nil {
+		return fmt.Errorf("could not write migration report: %s", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not write migration report: %s", err)
+	}
+	slog.Info("migration report written", "file", name)
+	return nil
+}
+
+// signExamples signs the URLs of the first -examples images secured with the new key. Without
+// -account-hash, the hash is the one of the delivery URLs of the first of them.
+func (c *migrationConfig) signExamples(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI, accountHash string, secured []string) ([]signedURL, error) {
+	ids := secured[:min(c.examples, len(secured))]
+	if len(ids) == 0 || c.key == nil {
+		return nil, nil
+	}
+
+	if accountHash == "" {
+		img, err := cli.GetImage(ctx, ids[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get image %s for the account hash: %s", ids[0], err)
+		}
+
+		for _, v := range img.Variants {
+			if hash, _, err := parseDeliveryURL(v); err == nil {
+				accountHash = hash
+				break
+			}
+		}
+
+		if accountHash == "" {
+			return nil, errors.New("no account hash in the delivery urls of the images, set -account-hash")
+		}
+	}
+
+	base, err := c.deliveryBase()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(migrationExamplesTTL).Truncate(time.Second).UTC()
+	examples := make([]signedURL, 0, len(ids))
+	for _, id := range ids {
+		u, err := cloudflareclient.SignURL(deliveryURL(base, accountHash, id, c.variant), c.key.Value, expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign url of image %s: %s", id, err)
+		}
+		examples = append(examples, signedURL{ImageID: id, Variant: c.variant, URL: u, ExpiresAt: expiresAt})
+	}
+	return examples, nil
+}
+
+func (c *migrationConfig) writeExamples(examples []signedURL) error {
+	f, err := os.Create(filepath.Join(c.dir, "signed-urls.csv"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := writeSignedURLsCSV(f, examples); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// writeReport writes the markdown report of the migration. The value of the signing key is
+// left out of it, the report being meant to be shared.
+func (c *migrationConfig) writeReport(w io.Writer, after *inventory, sum *runSummary, examples []signedURL, runErr error) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Signed URL migration of account %s\n\n", c.before.AccountID)
+	fmt.Fprintf(&b, "Started at %s, finished at %s.\n\n", c.startedAt.UTC().Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339))
+
+	status := "completed"
+	switch {
+	case runErr != nil:
+		status = "stopped: " + runErr.Error()
+	case sum == nil:
+		status = "stopped before securing the images"
+	case sum.Interrupted:
+		status = "interrupted"
+	case sum.Skipped > 0:
+		status = "held back after the canary"
+	case sum.Failed > 0:
+		status = "completed with failures"
+	}
+	fmt.Fprintf(&b, "Status: %s.\n\n", status)
+
+	b.WriteString("## Before\n\n")
+	writeMigrationCounts(&b, c.before, "before.json")
+
+	b.WriteString("## Signing key\n\n")
+	switch {
+	case c.keyAction == "rotated":
+		fmt.Fprintf(&b, "Rotated the signing key `%s`, the URLs signed with its previous value no longer being valid.", c.keyName)
+	case c.keyAction == "reused" && c.rotateKey:
+		fmt.Fprintf(&b, "Reused the existing signing key `%s`, not rotated, the rollout not being confirmed.", c.keyName)
+	case c.keyAction == "reused":
+		fmt.Fprintf(&b, "Reused the existing signing key `%s`.", c.keyName)
+	case c.keyAction == "created":
+		fmt.Fprintf(&b, "Created the signing key `%s`.", c.keyName)
+	}
+	if c.key != nil {
+		b.WriteString(" Its value is listed by the API and on the images page of the dashboard, it is left out of this report.\n\n")
+	}
+
+	b.WriteString("## Rollout\n\n")
+	if sum != nil {
+		b.WriteString("| | |\n| --- | --- |\n")
+		fmt.Fprintf(&b, "| secured | %d |\n", sum.Secured)
+		if sum.Failed > 0 {
+			fmt.Fprintf(&b, "| failed | %d (%s) |\n", sum.Failed, formatFailureClasses(sum.FailureClasses))
+		} else {
+			fmt.Fprintf(&b, "| failed | 0 |\n")
+		}
+		fmt.Fprintf(&b, "| skipped | %d (%d excluded, %d drafts, %d too recent, %d not attempted) |\n", sum.Excluded+sum.Drafts+sum.TooRecent+sum.Skipped, sum.Excluded, sum.Drafts, sum.TooRecent, sum.Skipped)
+		if sum.Unknown > 0 {
+			fmt.Fprintf(&b, "| unknown | %d |\n", sum.Unknown)
+		}
+		fmt.Fprintf(&b, "| remaining unprotected | %d |\n", sum.RemainingUnprotected)
+		fmt.Fprintf(&b, "| duration | %s |\n\n", sum.Duration.Round(time.Second))
+	} else {
+		b.WriteString("No image was secured.\n\n")
+	}
+
+	b.WriteString("## After\n\n")
+	if after != nil {
+		writeMigrationCounts(&b, after, "after.json")
+	} else {
+		b.WriteString("The account could not be snapshotted after the migration.\n\n")
+	}
+
+	b.WriteString("## Signed URL examples\n\n")
+	if len(examples) > 0 {
+		fmt.Fprintf(&b, "Signed with `%s`, valid until %s, also written to signed-urls.csv:\n\n", c.keyName, examples[0].ExpiresAt.Format(time.RFC3339))
+		for _, e := range examples {
+			fmt.Fprintf(&b, "- %s: <%s>\n", e.ImageID, e.URL)
+		}
+		b.WriteString("\n")
+	} else {
+		b.WriteString("No example URL was signed, for want of a secured image or of the signing key.\n\n")
+	}
+
+	b.WriteString("## Next steps\n\n")
+	b.WriteString("- Sign the delivery URLs of the apps with the new key, `sign-urls` signing them meanwhile.\n")
+	b.WriteString("- Check the 403s of the secured images with `verify-url`.\n")
+	switch {
+	case sum != nil && sum.Skipped > 0:
+		b.WriteString("- Run `secure` again to secure the images held back.\n")
+	case sum != nil && sum.Failed > 0:
+		b.WriteString("- Run `retry` with the summary of the run to re-attempt the failed changes.\n")
+	}
+	b.WriteString("- Run `drift` against after.json to catch the images exposed since.\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMigrationCounts writes the images of the inventory and how many require signed URLs.
+func writeMigrationCounts(b *strings.Builder, inv *inventory, file string) {
+	var protected int
+	for _, img := range inv.Images {
+		if img.RequireSignedURLs {
+			protected++
+		}
+	}
+	fmt.Fprintf(b, "%d images, %d requiring signed URLs and %d not, as of %s (%s).\n\n", len(inv.Images), protected, len(inv.Images)-protected, inv.TakenAt.Format(time.RFC3339), file)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/cloudflareclient/cloudflaretest"
+)
+
+func TestMigrationKey(t *testing.T) {
+	existing := cloudflareclient.SigningKey{Name: "migration", Value: "existing-value"}
+
+	tests := []struct {
+		name      string
+		keys      []cloudflareclient.SigningKey
+		rotateKey bool
+		// begun and rotated are the actions on the key after begin and after rotate.
+		begun, rotated string
+		// puts are the keys created or rotated after begin and after rotate.
+		puts, putsRotated int
+	}{
+		{"created", nil, false, "created", "created", 1, 1},
+		{"created without rotating", nil, true, "created", "created", 1, 1},
+		{"reused", []cloudflareclient.SigningKey{existing}, false, "reused", "reused", 0, 0},
+		{"rotated once confirmed", []cloudflareclient.SigningKey{existing}, true, "reused", "rotated", 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := cloudflaretest.NewServer("account", "token", cloudflareclient.Image{ID: "a"})
+			defer srv.Close()
+			srv.SetSigningKeys(tt.keys...)
+			cli := srv.Client()
+
+			c := migrationConfig{dir: t.TempDir(), keyName: "migration", rotateKey: tt.rotateKey}
+			if err := c.begin(context.Background(), cli, "account"); err != nil {
+				t.Fatalf("begin: %s", err)
+			}
+
+			// The key is ready before any image is secured.
+			if c.key == nil || c.keyAction != tt.begun {
+				t.Fatalf("key %v %q after begin, want %q", c.key, c.keyAction, tt.begun)
+			}
+			if n := countPuts(srv); n != tt.puts {
+				t.Errorf("%d keys created after begin, want %d", n, tt.puts)
+			}
+			if img, _ := srv.Image("a"); img.RequireSignedURLs {
+				t.Error("image secured by begin")
+			}
+
+			if err := c.rotate(context.Background(), cli); err != nil {
+				t.Fatalf("rotate: %s", err)
+			}
+			if c.keyAction != tt.rotated {
+				t.Errorf("key %q after rotate, want %q", c.keyAction, tt.rotated)
+			}
+			if n := countPuts(srv); n != tt.putsRotated {
+				t.Errorf("%d keys created after rotate, want %d", n, tt.putsRotated)
+			}
+		})
+	}
+}
+
+func TestMigrationBeginFailsWithoutKey(t *testing.T) {
+	srv := cloudflaretest.NewServer("account", "token")
+	defer srv.Close()
+	srv.Fail(cloudflaretest.Failure{Method: http.MethodPut, StatusCode: http.StatusForbidden})
+
+	c := migrationConfig{dir: t.TempDir(), keyName: "migration"}
+	if err := c.begin(context.Background(), srv.Client(), "account"); err == nil {
+		t.Fatal("begin succeeded without a signing key, want an error")
+	}
+}
+
+func countPuts(srv *cloudflaretest.Server) int {
+	var n int
+	for _, r := range srv.Requests() {
+		if r.Method == http.MethodPut {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// The exit codes of the Nagios plugins, which the monitoring reads the state of the check from.
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+var nagiosStates = map[int]string{
+	nagiosOK:       "OK",
+	nagiosWarning:  "WARNING",
+	nagiosCritical: "CRITICAL",
+	nagiosUnknown:  "UNKNOWN",
+}
+
+// nagiosError is the outcome of a check other than OK, exiting with the code of its state.
+type nagiosError struct {
+	code int
+	error
+}
+
+// nagiosThresholds are the numbers of images unprotected from which the check is in the warning
+// and critical states, zero disabling the threshold.
+type nagiosThresholds struct {
+	warn, crit int
+}
+
+func (t nagiosThresholds) state(unprotected int) int {
+	switch {
+	case t.crit > 0 && unprotected >= t.crit:
+		return nagiosCritical
+	case t.warn > 0 && unprotected >= t.warn:
+		return nagiosWarning
+	default:
+		return nagiosOK
+	}
+}
+
+// writeNagios prints the status line of the check with the count as performance data,
+// returning a nagiosError unless the check is OK.
+func writeNagios(w io.Writer, unprotected int, t nagiosThresholds) error {
+	code := t.state(unprotected)
+	fmt.Fprintf(w, "%s - %d unprotected images | unprotected=%d;%s;%s;0\n", nagiosStates[code], unprotected, unprotected, nagiosThreshold(t.warn), nagiosThreshold(t.crit))
+	if code != nagiosOK {
+		return nagiosError{code, fmt.Errorf("%d images unprotected", unprotected)}
+	}
+	return nil
+}
+
+// writeNagiosUnknown prints the status line of a check which couldn't count the images.
+func writeNagiosUnknown(w io.Writer, err error) error {
+	fmt.Fprintf(w, "%s - %s\n", nagiosStates[nagiosUnknown], err)
+	return nagiosError{nagiosUnknown, err}
+}
+
+// nagiosThreshold is a threshold of the performance data, left empty when disabled.
+func nagiosThreshold(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
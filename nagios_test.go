@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNagiosOutput(t *testing.T) {
+	tests := []struct {
+		args string
+		want bool
+	}{
+		{"", false},
+		{"-output nagios", true},
+		{"--output nagios", true},
+		{"-output=nagios", true},
+		{"-bogus -output nagios", true},
+		{"-output table", false},
+		{"-output nagios -output table", false},
+		{"-output table -output=nagios", true},
+		{"-output", false},
+		{"-- -output nagios", false},
+		{"-outputs nagios", false},
+	}
+
+	for _, tt := range tests {
+		if got := nagiosOutput(strings.Fields(tt.args)); got != tt.want {
+			t.Errorf("nagiosOutput(%q) = %t, want %t", tt.args, got, tt.want)
+		}
+	}
+}
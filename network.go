@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// networkConfig holds the flags configuring how the cloudflare api is reached
+// from a locked-down network, through an egress proxy or a tls inspecting gateway.
+type networkConfig struct {
+	proxy      string
+	caBundle   string
+	clientCert string
+	clientKey  string
+	// headers are added to every request, for the gateways authenticating them.
+	headers headerFlag
+}
+
+// headerFlag collects repeated --header "Key: Value" flags.
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	pairs := make([]string, 0, len(h))
+	for k, values := range h {
+		for _, v := range values {
+			pairs = append(pairs, k+": "+v)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (h headerFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, ":")
+	if k = strings.TrimSpace(k); !ok || k == "" || strings.ContainsAny(k, " \t") {
+		return fmt.Errorf("expected \"Key: Value\", got '%s'", value)
+	}
+
+	switch k = http.CanonicalHeaderKey(k); k {
+	case "Authorization", "X-Auth-Email", "X-Auth-Key", "Content-Type", "Host":
+		return fmt.Errorf("the %s header is set by the tool", k)
+	}
+	http.Header(h).Add(k, strings.TrimSpace(v))
+	return nil
+}
+
+func (c *networkConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.proxy, "proxy", "", "http or https proxy url to reach the cloudflare api through, defaults to the HTTPS_PROXY environment variable")
+	fs.StringVar(&c.caBundle, "ca-bundle", "", "PEM file of the certificate authorities to trust in addition to the system ones")
+	fs.StringVar(&c.clientCert, "client-cert", "", "PEM file of the client certificate to present, for mutual tls")
+	fs.StringVar(&c.clientKey, "client-key", "", "PEM file of the key of -client-cert")
+	c.headers = headerFlag{}
+	fs.Var(c.headers, "header", "\"Key: Value\" header to add to every api request, for a gateway authenticating them (repeatable)")
+}
+
+// transport returns the transport configured by the flags, or nil when they aren't set.
+func (c *networkConfig) transport() (*http.Transport, error) {
+	if c.proxy == "" && c.caBundle == "" && c.clientCert == "" && c.clientKey == "" {
+		return nil, nil
+	}
+
+	t := cloudflareclient.NewTransport()
+
+	if c.proxy != "" {
+		u, err := url.Parse(c.proxy)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("-proxy must be an http or https url: %q", c.proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig := t.TLSClientConfig
+
+	if c.caBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := os.ReadFile(c.caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca bundle: %s", err)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in ca bundle %s", c.caBundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (c.clientCert == "") != (c.clientKey == "") {
+		return nil, errors.New("-client-cert and -client-key must be used together")
+	}
+
+	if c.clientCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.clientCert, c.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
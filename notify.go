@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxNotifiedIDs is how many failed image ids are listed in notifications.
+const maxNotifiedIDs = 20
+
+// notifyWebhook posts the summary of the run to a Slack or Discord incoming webhook,
+// telling them apart by the webhook URL.
+func notifyWebhook(ctx context.Context, webhookURL string, sum *runSummary) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %s", err)
+	}
+
+	text := summaryText(sum)
+
+	var payload any = map[string]string{"text": text}
+	if isDiscordWebhook(u) {
+		payload = map[string]string{"content": text}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("could not encode payload: %s", err)
+	}
+
+	return postJSON(ctx, webhookURL, body, nil)
+}
+
+func isDiscordWebhook(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com")
+}
+
+// summaryText is the human readable summary of the run used in notifications.
+func summaryText(sum *runSummary) string {
+	var b strings.Builder
+
+	status := "finished"
+	if sum.Interrupted {
+		status = "was interrupted"
+	}
+
+	fmt.Fprintf(&b, "securecloudflareimg run on account %s %s in %s\n", sum.AccountID, status, sum.Duration.Round(time.Second))
+	fmt.Fprintf(&b, "secured: %d, made public: %d, failed: %d, skipped: %d, intentionally public: %d, drafts: %d, too recent: %d\n",
+		sum.Secured, sum.MadePublic, sum.Failed, sum.Skipped, sum.Excluded, sum.Drafts, sum.TooRecent)
+	fmt.Fprintf(&b, "remaining unprotected: %d", sum.RemainingUnprotected)
+
+	if sum.Failed > 0 {
+		fmt.Fprintf(&b, "\nfailures: %s", formatFailureClasses(sum.FailureClasses))
+	}
+
+	if len(sum.FailedIDs) > 0 {
+		ids := sum.FailedIDs
+		if len(ids) > maxNotifiedIDs {
+			ids = ids[:maxNotifiedIDs]
+		}
+
+		fmt.Fprintf(&b, "\nfailed images: %s", strings.Join(ids, ", "))
+		if len(sum.FailedIDs) > len(ids) {
+			fmt.Fprintf(&b, " and %d more", len(sum.FailedIDs)-len(ids))
+		}
+	}
+	return b.String()
+}
+
+// postReport posts the summary of the run as JSON. When a secret is given, the body is signed
+// with HMAC-SHA256 and the hex signature sent in the X-Signature-256 header as "sha256=<signature>".
+func postReport(ctx context.Context, u, secret string, sum *runSummary) error {
+	body, err := json.Marshal(struct {
+		Event   string      `json:"event"`
+		Summary *runSummary `json:"summary"`
+	}{
+		Event:   "run.completed",
+		Summary: sum,
+	})
+	if err != nil {
+		return fmt.Errorf("could not encode report: %s", err)
+	}
+
+	return postJSON(ctx, u, body, signatureHeader(secret, body))
+}
+
+// signatureHeader signs the body with HMAC-SHA256 in the X-Signature-256 header,
+// empty when there is no secret.
+func signatureHeader(secret string, body []byte) http.Header {
+	header := http.Header{}
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return header
+}
+
+// validateReportURL makes sure reports are only posted over https, but to local endpoints.
+func validateReportURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid report webhook url: %s", err)
+	}
+
+	switch {
+	case u.Scheme == "https":
+		return nil
+	case u.Scheme == "http" && isLoopback(u.Hostname()):
+		return nil
+	default:
+		return fmt.Errorf("report webhook url must use https: %s", s)
+	}
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// postJSON posts the JSON body to the URL with the given extra headers, expecting a 2xx response.
+func postJSON(ctx context.Context, u string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("could not prepare request: %s", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+
+	resp, err := notifyHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// notifyHTTPClient sends the notifications, separately from the cloudflare client.
+var notifyHTTPClient = &http.Client{Timeout: 15 * time.Second}
@@ -0,0 +1,746 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/policy"
+	"github.com/getsentry/sentry-go"
+)
+
+// options holds the flags shared by the commands.
+type options struct {
+	accountID string
+	apiKey    string
+	// apiKeyFile and apiKeySecret tell where to read the api key from instead of -api-key.
+	apiKeyFile   string
+	apiKeySecret string
+	// credentialSource and credentialItem tell where to read the api key from for local usage.
+	credentialSource string
+	credentialItem   string
+	authEmail        string
+	authKey          string
+	apiBaseURL       string
+	maxAttempts      int
+	rateLimit        float64
+	breakerThreshold int
+	breakerCoolDown  time.Duration
+	requestTimeout   time.Duration
+	debug            bool
+	// runTimeout bounds a run, each pass of the daemons.
+	runTimeout time.Duration
+	// imageTimeout bounds the update of each image, retries included.
+	imageTimeout    time.Duration
+	listAPI         string
+	listConcurrency int
+	pageSize        int
+	batchToken      bool
+	batchURL        string
+	network         networkConfig
+	// transport is the transport configured by the network flags, nil for the default one.
+	transport *http.Transport
+	// skipPreflight skips checking the credentials before using the client.
+	skipPreflight bool
+
+	filenameGlob string
+	shard        string
+	metadata     metadataFlag
+	excludeIDs   string
+	excludeFile  string
+	idsFile      string
+	// accessLogs are the access logs the images to secure are read from, for ingest-logs.
+	accessLogs *accessLogsConfig
+	// migration is the migration the pass is the rollout of, for migrate.
+	migration   *migrationConfig
+	accountHash string
+	policyFile  string
+	minAge      time.Duration
+	ownerKeys   string
+
+	concurrency int
+	adaptive    bool
+	// maxChanges is the number of changes a run may make at most, negative for no limit.
+	maxChanges int
+	// limiter adapts the concurrency to the responses of the API, with -adaptive-concurrency.
+	limiter    *adaptiveLimiter
+	failedOut  string
+	progress   string
+	tagSecured bool
+	quarantine quarantineConfig
+	canary     canaryConfig
+	cpuProfile string
+	memProfile string
+	lockFile   string
+	// lock is held for the duration of the run, taken from -lock-file.
+	lock       *runLock
+	auditFile  string
+	auditActor string
+	// audit records the changes attempted, opened from -audit-log.
+	audit *auditLog
+	// usage counts the requests made to the API, for the run summary.
+	usage *apiUsage
+
+	summaryOut string
+	// output is how the summary is printed: as a table, along with GitHub Actions annotations,
+	// or as the result of a Terraform external data source.
+	output string
+	// failIfUnprotected and maxFailures make the run fail when its outcome
+	// doesn't meet the protection guarantees, a negative maxFailures not limiting them.
+	failIfUnprotected bool
+	maxFailures       int
+	notifyWebhook     string
+	reportWebhook     string
+	// reportWebhookSecret signs the reports, read from the environment to keep it off the command line.
+	reportWebhookSecret string
+	smtp                smtpConfig
+	// mailer sends the report emails, set up from the smtp flags.
+	mailer *mailer
+	owners ownersConfig
+	// ownerNotifier tells the owners of the images secured, set up from the owners flags.
+	ownerNotifier *ownerNotifier
+	alertKind     string
+	alerter       alerter
+	// statsd publishes the metrics of the runs, set up from -statsd-addr.
+	statsdAddr   string
+	statsdPrefix string
+	statsd       *statsdEmitter
+	// events publishes the changes to a message bus, set up from -publish-events.
+	publishEvents string
+	eventSink     eventSinkRef
+	events        *eventPublisher
+	// errReporter reports the failed runs to Sentry, set up from -sentry-dsn.
+	sentryDSN         string
+	sentryEnvironment string
+	errReporter       *errorReporter
+
+	logFormat string
+	logLevel  string
+	logTarget string
+	logFile   logFileConfig
+	// validating is set by config validate, for nothing to be opened or connected to.
+	validating bool
+
+	// middlewares wrap the transport of the http client, the first one outermost.
+	middlewares []cloudflareclient.Middleware
+}
+
+// parse registers the flags shared by every command, parses the arguments
+// and sets up what the shared flags configure.
+func (o *options) parse(fs *flag.FlagSet, args []string) error {
+	if err := o.parseArgs(fs, args); err != nil {
+		return err
+	}
+	return o.startLogging()
+}
+
+// parseArgs registers the logging flags and parses the arguments, leaving the logging to
+// startLogging, for flags given otherwise than as arguments to be set in between.
+func (o *options) parseArgs(fs *flag.FlagSet, args []string) error {
+	fs.StringVar(&o.logFormat, "log-format", "text", "log format, text or json")
+	fs.StringVar(&o.logLevel, "log-level", "info", "minimum log level, debug, info, warn or error")
+	fs.StringVar(&o.logTarget, "log-target", "stderr", "where to send the logs, stderr (or -log-file), syslog or journald, for a system service")
+	o.logFile.registerFlags(fs)
+	return parseFlags(fs, args)
+}
+
+// startLogging checks the logging flags and sets up the logger.
+func (o *options) startLogging() error {
+	if o.debug {
+		o.logLevel = "debug"
+	}
+
+	switch o.logTarget {
+	case "stderr":
+	case "syslog", "journald":
+		if o.logFile.name != "" {
+			return fmt.Errorf("-log-file cannot be used with -log-target %s", o.logTarget)
+		}
+	default:
+		return fmt.Errorf("invalid -log-target '%s', expected stderr, syslog or journald", o.logTarget)
+	}
+
+	if err := o.logFile.validate(); err != nil {
+		return err
+	}
+
+	switch {
+	case o.validating:
+		// config validate neither opens the log file nor connects to the log target.
+		return setupLogging(stderr, o.logFormat, o.logLevel)
+	case o.logTarget != "stderr":
+		return setupSinkLogging(o.logTarget, o.logFormat, o.logLevel)
+	case o.logFile.name == "":
+		return setupLogging(stderr, o.logFormat, o.logLevel)
+	}
+	return setupLogging(o.logFile.open(), o.logFormat, o.logLevel)
+}
+
+// registerClientFlags registers the flags needed to talk to cloudflare.
+func (o *options) registerClientFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.accountID, "account-id", "", "cloudflare account id")
+	fs.StringVar(&o.apiKey, "api-key", "", "cloudflare api key")
+	fs.StringVar(&o.apiKeyFile, "api-key-file", "", "file to read the cloudflare api key from, instead of -api-key")
+	fs.StringVar(&o.apiKeySecret, "api-key-secret", "", "secret to read the cloudflare api key from, instead of -api-key: vault://<mount>/<path>#<key>, aws-sm://<name>[#<key>] or gcp-sm://<project>/<secret>[#<key>]")
+	fs.StringVar(&o.credentialSource, "credential-source", "", "read the cloudflare api key from the os keychain or 1password, instead of -api-key")
+	fs.StringVar(&o.credentialItem, "credential-item", "", "keychain account the api key is stored under, defaults to -account-id, or 1password secret reference (op://<vault>/<item>/<field>)")
+	fs.StringVar(&o.authEmail, "auth-email", "", "email of the cloudflare user, to authenticate with their global api key instead of -api-key")
+	fs.StringVar(&o.authKey, "auth-key", "", "legacy global api key of the cloudflare user, to authenticate with instead of -api-key")
+	fs.StringVar(&o.apiBaseURL, "api-base-url", cloudflareclient.DefaultBaseURL, "base url of the cloudflare api, to go through a gateway or talk to a mock server")
+	fs.IntVar(&o.maxAttempts, "max-attempts", cloudflareclient.DefaultRetryPolicy.MaxAttempts, "maximum number of times a request failing with a network error, a 429 or a 5xx is sent")
+	fs.StringVar(&o.listAPI, "list-api", "v2", "images listing to use, v2 with up to 10000 images per page or v1 with 100")
+	fs.IntVar(&o.listConcurrency, "list-concurrency", 4, "number of pages of the v1 listing fetched concurrently")
+	fs.IntVar(&o.pageSize, "page-size", 0, "number of images per page of the listing, trading more requests for smaller responses, up to 100 with v1 and 10000 with v2, 0 for the most")
+	fs.BoolVar(&o.batchToken, "batch-token", false, "update the images through the batch api with a batch token, which isn't rate limited, for bulk runs")
+	fs.StringVar(&o.batchURL, "batch-url", cloudflareclient.DefaultBatchURL, "base url of the cloudflare images batch api")
+	o.network.registerFlags(fs)
+	fs.BoolVar(&o.debug, "debug", false, "log every api request and its response at debug level, with the credentials redacted")
+	fs.BoolVar(&o.skipPreflight, "skip-preflight", false, "skip checking the credentials and their permissions on the account before starting")
+	fs.Float64Var(&o.rateLimit, "rate-limit", 0, "maximum number of requests per second sent to the api, 0 for no limit (cloudflare allows 4 on average)")
+	fs.DurationVar(&o.requestTimeout, "request-timeout", 15*time.Second, "how long to wait for the response to each attempt of a request to the api, 0 for no limit")
+	fs.IntVar(&o.breakerThreshold, "circuit-breaker", 10, "number of consecutive requests failing with a network error or a 5xx that pauses the run during an api outage, 0 to never pause")
+	fs.DurationVar(&o.breakerCoolDown, "circuit-cool-down", time.Minute, "how long the run is paused when the circuit breaker trips")
+}
+
+// registerSelectionFlags registers the flags selecting the images to operate on.
+func (o *options) registerSelectionFlags(fs *flag.FlagSet) {
+	o.metadata = metadataFlag{}
+	fs.StringVar(&o.shard, "shard", "", "only operate on the shard index/count of the images (e.g. 0/4), for several instances to share a huge account")
+	fs.StringVar(&o.filenameGlob, "filename-glob", "", "only secure images whose filename matches the glob pattern")
+	fs.Var(o.metadata, "metadata", "only secure images with the metadata key=value (repeatable)")
+	fs.StringVar(&o.excludeIDs, "exclude-ids", "", "comma separated ids of intentionally public images to skip")
+	fs.StringVar(&o.excludeFile, "exclude-file", "", "file with the ids or the delivery urls of intentionally public images to skip, one per line")
+	fs.StringVar(&o.idsFile, "ids-file", "", "file with the ids or the delivery urls of the images to secure, one per line, or - for stdin")
+	fs.StringVar(&o.accountHash, "account-hash", "", "account hash of the delivery urls given with -ids-file or read from the access logs, the urls of other accounts being skipped")
+	fs.StringVar(&o.ownerKeys, "owner-keys", defaultOwnerKeys, "comma separated metadata keys telling who uploaded an image, the first one set being reported as its owner")
+	fs.DurationVar(&o.minAge, "min-age", 0, "skip the images uploaded less than this ago, counted apart from the drafts, for the application uploading them to set them up first")
+	fs.StringVar(&o.policyFile, "policy", "", "YAML policy file with the protection rules, defaults to requiring signed URLs for every image")
+}
+
+// registerReportFlags registers the flags telling where to deliver the summary of a run.
+func (o *options) registerReportFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.output, "output", "table", "how to print the run summary, table, github for github actions annotations of the images failed and unprotected along with the table, or terraform-external for the json result of a terraform external data source")
+	fs.StringVar(&o.summaryOut, "summary-out", "", "file to write the JSON run summary to at the end of the run")
+	fs.StringVar(&o.notifyWebhook, "notify-webhook", "", "slack or discord incoming webhook url to post the run summary to")
+	o.smtp.registerFlags(fs)
+	o.owners.registerFlags(fs)
+	fs.StringVar(&o.alertKind, "alert", "", "raise an on-call alert while images remain unprotected after a run, with pagerduty or opsgenie")
+	fs.StringVar(&o.statsdAddr, "statsd-addr", "", "statsd or datadog agent address to publish the counters and timing of every run to (e.g. localhost:8125)")
+	fs.StringVar(&o.statsdPrefix, "statsd-prefix", "securecloudflareimg", "prefix of the names of the statsd metrics")
+	fs.StringVar(&o.publishEvents, "publish-events", "", "message bus to publish an event to for every image changed and every image remaining unprotected, sns://<topic arn>, sqs://<queue url without https>, kafka://<brokers>/<topic> or nats://<host>:<port>/<subject>")
+	fs.StringVar(&o.reportWebhook, "report-webhook", "", "https url to post the JSON run report to, signed with the "+reportSecretEnv+" environment variable if set")
+}
+
+const reportSecretEnv = "SECURECLOUDFLAREIMG_REPORT_SECRET"
+
+func (o *options) validateReport() error {
+	if o.output != "table" && o.output != "github" && o.output != "terraform-external" {
+		return fmt.Errorf("invalid -output '%s', expected table, github or terraform-external", o.output)
+	}
+
+	if o.smtp.addr != "" {
+		m, err := newMailer(o.smtp)
+		if err != nil {
+			return err
+		}
+		o.mailer = m
+	}
+
+	n, err := newOwnerNotifier(o.owners, o.ownerKeys, o.mailer)
+	if err != nil {
+		return err
+	}
+	o.ownerNotifier = n
+
+	if o.alertKind != "" {
+		a, err := newAlerter(o.alertKind)
+		if err != nil {
+			return err
+		}
+		o.alerter = a
+	}
+
+	if o.statsdAddr != "" {
+		if _, _, err := net.SplitHostPort(o.statsdAddr); err != nil {
+			return fmt.Errorf("invalid -statsd-addr: %s", err)
+		}
+	}
+
+	if o.publishEvents != "" {
+		ref, err := parseEventSink(o.publishEvents)
+		if err != nil {
+			return err
+		}
+		o.eventSink = ref
+	}
+
+	if o.sentryDSN != "" {
+		if _, err := sentry.NewDsn(o.sentryDSN); err != nil {
+			return fmt.Errorf("invalid -sentry-dsn: %s", err)
+		}
+	}
+
+	if o.reportWebhook == "" {
+		return nil
+	}
+
+	o.reportWebhookSecret = os.Getenv(reportSecretEnv)
+	return validateReportURL(o.reportWebhook)
+}
+
+// registerGateFlags registers the flags making a run fail when its outcome isn't good enough,
+// to use it as a gate in deployment pipelines.
+func (o *options) registerGateFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&o.failIfUnprotected, "fail-if-unprotected", false, "exit with an error when images remain unprotected at the end of the run")
+	fs.IntVar(&o.maxFailures, "max-failures", -1, "exit with an error when more changes than this fail, -1 for no limit")
+}
+
+// gateError is the error of a run whose outcome doesn't meet the guarantees asked for.
+type gateError struct {
+	error
+}
+
+// gate returns a gateError when the outcome of the run doesn't meet the guarantees asked for.
+func (o *options) gate(sum *runSummary) error {
+	if o.maxFailures >= 0 && sum.Failed > o.maxFailures {
+		return gateError{fmt.Errorf("%d changes failed, more than -max-failures %d", sum.Failed, o.maxFailures)}
+	}
+
+	if o.failIfUnprotected && sum.RemainingUnprotected > 0 {
+		return gateError{fmt.Errorf("%d images remain unprotected", sum.RemainingUnprotected)}
+	}
+	return nil
+}
+
+// registerApplyFlags registers the flags tuning how changes are applied.
+func (o *options) registerApplyFlags(fs *flag.FlagSet) {
+	fs.IntVar(&o.concurrency, "concurrency", 10, "number of images updated concurrently, the maximum with -adaptive-concurrency")
+	fs.BoolVar(&o.adaptive, "adaptive-concurrency", false, "start updating one image at a time, ramping up to -concurrency while requests succeed and halving on 429 and 5xx responses")
+	fs.IntVar(&o.maxChanges, "max-changes", -1, "number of images a run may modify at most, the other changes being held back and reported as skipped, -1 for no limit")
+	fs.StringVar(&o.failedOut, "failed-out", "", "file to write the failed changes to at the end of the run, for the retry command")
+	fs.BoolVar(&o.tagSecured, "tag-secured", false, "add secured_by and secured_at to the metadata of the images secured, keeping their other metadata")
+	fs.DurationVar(&o.runTimeout, "run-timeout", 0, "maximum duration of a run, or of each pass when running as a daemon, after which it stops like when interrupted, 0 for no limit")
+	fs.DurationVar(&o.imageTimeout, "image-timeout", 0, "maximum duration of the update of each image, retries included, after which it is reported as failed and the run moves on, 0 for no limit")
+	fs.StringVar(&o.cpuProfile, "cpuprofile", "", "file to write a cpu profile of the run to, for go tool pprof")
+	fs.StringVar(&o.memProfile, "memprofile", "", "file to write a heap profile to at the end of the run, for go tool pprof")
+	fs.StringVar(&o.lockFile, "lock-file", "", "file to lock for the duration of the run, failing right away if another run holds it")
+	fs.StringVar(&o.auditFile, "audit-log", "", "file to append a JSON line to for every change attempted, chained by hashes to make tampering evident, checked with audit verify")
+	fs.StringVar(&o.auditActor, "audit-actor", "", "who is making the changes, recorded in the audit log, defaults to user@host")
+	fs.StringVar(&o.progress, "progress", "auto", "show the progress as a bar with the rate and ETA (bar), as a log line every 10s (lines), or a bar on terminals and lines otherwise (auto), in place of the log line of each image secured, or not at all (off) logging every image")
+}
+
+var errMissingCredentials = errors.New("-account-id and -api-key, or -auth-email and -auth-key, are required")
+
+func (o *options) validateClient(ctx context.Context) error {
+	if err := o.readAPIKey(ctx); err != nil {
+		return err
+	}
+
+	if o.accountID == "" || (o.apiKey == "" && o.authKey == "") {
+		return errMissingCredentials
+	}
+
+	if o.apiKey != "" && o.authKey != "" {
+		return errors.New("-api-key and -auth-key cannot be used together")
+	}
+
+	if (o.authEmail == "") != (o.authKey == "") {
+		return errors.New("-auth-email and -auth-key must be used together")
+	}
+
+	u, err := url.Parse(o.apiBaseURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("-api-base-url must be an http or https url: %q", o.apiBaseURL)
+	}
+
+	if o.listAPI != "v1" && o.listAPI != "v2" {
+		return fmt.Errorf("invalid -list-api '%s', expected v1 or v2", o.listAPI)
+	}
+
+	if o.listConcurrency < 1 {
+		return errors.New("-list-concurrency must be at least 1")
+	}
+
+	maxPageSize := cloudflareclient.MaxV2PageSize
+	if o.listAPI == "v1" {
+		maxPageSize = cloudflareclient.MaxPageSize
+	}
+	if o.pageSize != 0 && (o.pageSize < cloudflareclient.MinPageSize || o.pageSize > maxPageSize) {
+		return fmt.Errorf("-page-size must be between %d and %d with the %s listing", cloudflareclient.MinPageSize, maxPageSize, o.listAPI)
+	}
+
+	t, err := o.network.transport()
+	if err != nil {
+		return err
+	}
+	o.transport = t
+
+	if o.maxAttempts < 1 {
+		return errors.New("-max-attempts must be at least 1")
+	}
+
+	if o.rateLimit < 0 {
+		return errors.New("-rate-limit cannot be negative")
+	}
+
+	if o.breakerThreshold < 0 {
+		return errors.New("-circuit-breaker cannot be negative")
+	}
+
+	if o.requestTimeout < 0 {
+		return errors.New("-request-timeout cannot be negative")
+	}
+	return nil
+}
+
+// readAPIKey reads the api key from the file or the secret backend given in the options.
+func (o *options) readAPIKey(ctx context.Context) error {
+	var set int
+	for _, v := range []string{o.apiKey, o.apiKeyFile, o.apiKeySecret, o.credentialSource} {
+		if v != "" {
+			set++
+		}
+	}
+
+	if set > 1 {
+		return errors.New("only one of -api-key, -api-key-file, -api-key-secret and -credential-source can be used")
+	}
+
+	switch {
+	case o.apiKeyFile != "":
+		key, err := readSecretFile(o.apiKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read api key file: %s", err)
+		}
+		o.apiKey = key
+	case o.apiKeySecret != "":
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		key, err := readSecret(ctx, o.apiKeySecret)
+		if err != nil {
+			return fmt.Errorf("failed to read api key: %s", err)
+		}
+		o.apiKey = key
+	case o.credentialSource != "":
+		source, err := newCredentialSource(o.credentialSource)
+		if err != nil {
+			return err
+		}
+
+		item := o.credentialItem
+		if item == "" && o.credentialSource == "keychain" {
+			item = o.accountID
+		}
+
+		if item == "" {
+			return fmt.Errorf("-credential-item is required to read the api key from %s", o.credentialSource)
+		}
+
+		key, err := source.fetch(ctx, item, "")
+		if err != nil {
+			return fmt.Errorf("failed to read api key from %s: %s", o.credentialSource, err)
+		}
+
+		if o.apiKey = strings.TrimSpace(key); o.apiKey == "" {
+			return fmt.Errorf("api key read from %s is empty", o.credentialSource)
+		}
+	}
+	return nil
+}
+
+func (o *options) validateApply() error {
+	if o.concurrency < 1 {
+		return errors.New("-concurrency must be at least 1")
+	}
+
+	if o.runTimeout < 0 {
+		return errors.New("-run-timeout cannot be negative")
+	}
+
+	if o.imageTimeout < 0 {
+		return errors.New("-image-timeout cannot be negative")
+	}
+
+	switch o.progress {
+	case "auto", "bar", "lines", "off":
+	default:
+		return fmt.Errorf("invalid -progress '%s', expected auto, bar, lines or off", o.progress)
+	}
+
+	o.usage = &apiUsage{}
+	o.middlewares = append(o.middlewares, o.usage.transport)
+
+	if o.adaptive {
+		o.limiter = newAdaptiveLimiter(o.concurrency)
+		o.middlewares = append(o.middlewares, o.limiter.transport)
+	}
+
+	if o.auditFile != "" {
+		if info, err := os.Stat(filepath.Dir(o.auditFile)); err != nil || !info.IsDir() {
+			return fmt.Errorf("invalid -audit-log '%s', its directory doesn't exist", o.auditFile)
+		}
+	}
+	return nil
+}
+
+// openSinks opens the audit log and connects to statsd, the bus of -publish-events and sentry,
+// once the options are validated and the command is about to run: config validate stops before.
+func (o *options) openSinks(ctx context.Context) error {
+	if o.auditFile != "" {
+		actor := o.auditActor
+		if actor == "" {
+			actor = defaultAuditActor()
+		}
+
+		audit, err := openAuditLog(o.auditFile, actor)
+		if err != nil {
+			return err
+		}
+		o.audit = audit
+	}
+
+	if o.statsdAddr != "" {
+		e, err := newStatsdEmitter(o.statsdAddr, o.statsdPrefix)
+		if err != nil {
+			return err
+		}
+		o.statsd = e
+	}
+
+	if o.publishEvents != "" {
+		sink, err := o.eventSink.connect(ctx)
+		if err != nil {
+			return err
+		}
+		o.events = newEventPublisher(sink, o.accountID)
+	}
+
+	if o.sentryDSN != "" {
+		r, err := newErrorReporter(o.sentryDSN, o.sentryEnvironment)
+		if err != nil {
+			return err
+		}
+		o.errReporter = r
+	}
+	return nil
+}
+
+// errRunTimeout is the cause of the cancellation of a run lasting longer than -run-timeout.
+var errRunTimeout = errors.New("run timed out")
+
+// runContext returns the context of a run, cancelled after -run-timeout, if any.
+func (o *options) runContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.runTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, o.runTimeout, fmt.Errorf("%w after %s", errRunTimeout, o.runTimeout))
+}
+
+// lockRun takes the lock of the -lock-file, if any, for the run to release once done.
+func (o *options) lockRun() error {
+	if o.lockFile == "" {
+		return nil
+	}
+
+	lock, err := acquireLock(o.lockFile)
+	if err != nil {
+		return err
+	}
+	o.lock = lock
+	return nil
+}
+
+// newApplier returns an applier of the changes recording them to the audit log, if any.
+func (o *options) newApplier(cli cloudflareclient.CloudflareImagesAPI) *applier {
+	a := newApplier(cli, o.concurrency)
+	a.maxChanges = o.maxChanges
+	a.tagSecured = o.tagSecured
+	a.imageTimeout = o.imageTimeout
+	a.limiter = o.limiter
+	a.quarantine = o.quarantine.enabled
+	a.errReporter = o.errReporter
+	a.accountID = o.accountID
+	a.observe(o.audit.record)
+	a.observe(o.ownerNotifier.observe)
+	a.observe(o.events.observe)
+	return a
+}
+
+func (o *options) newClient() *cloudflareclient.Client {
+	retry := cloudflareclient.DefaultRetryPolicy
+	retry.MaxAttempts = o.maxAttempts
+
+	auth := cloudflareclient.WithAPIToken(o.apiKey)
+	if o.authKey != "" {
+		auth = cloudflareclient.WithAPIKey(o.authEmail, o.authKey)
+	}
+
+	clientOpts := []cloudflareclient.Option{
+		auth,
+		cloudflareclient.WithBaseURL(o.apiBaseURL),
+		cloudflareclient.WithUserAgent(userAgent()),
+		cloudflareclient.WithHeaders(http.Header(o.network.headers)),
+		cloudflareclient.WithRequestTimeout(o.requestTimeout),
+		cloudflareclient.WithRetryPolicy(retry),
+		cloudflareclient.WithRateLimit(o.rateLimit, 1),
+		cloudflareclient.WithCircuitBreaker(cloudflareclient.CircuitBreaker{
+			Threshold: o.breakerThreshold,
+			CoolDown:  o.breakerCoolDown,
+			OnTrip: func(err error, coolDown time.Duration) {
+				slog.Error("the cloudflare api looks down, pausing the requests", "cool_down", coolDown, "error", err)
+			},
+			OnReset: func() {
+				slog.Info("the cloudflare api is back, resuming the requests")
+			},
+		}),
+		cloudflareclient.WithTransportMiddleware(o.middlewares...),
+		cloudflareclient.WithListConcurrency(o.listConcurrency),
+		cloudflareclient.WithPageSize(o.pageSize),
+	}
+
+	if o.debug {
+		clientOpts = append(clientOpts, cloudflareclient.WithLogger(slog.Default()))
+	}
+
+	if o.transport != nil {
+		clientOpts = append(clientOpts, cloudflareclient.WithHTTPClient(&http.Client{Transport: o.transport}))
+	}
+
+	if o.listAPI == "v2" {
+		clientOpts = append(clientOpts, cloudflareclient.WithImagesV2Listing())
+	}
+
+	if o.batchToken {
+		clientOpts = append(clientOpts, cloudflareclient.WithBatchToken(o.batchURL))
+	}
+	return cloudflareclient.New(o.accountID, clientOpts...)
+}
+
+// connect returns a client after checking the credentials have the permissions
+// needed on the account, the write permission only if checkWrite is set.
+func (o *options) connect(ctx context.Context, checkWrite bool) (*cloudflareclient.Client, error) {
+	cli := o.newClient()
+	if o.skipPreflight {
+		return cli, nil
+	}
+
+	if err := cli.Preflight(ctx, checkWrite); err != nil {
+		var permErr *cloudflareclient.PermissionError
+		if errors.As(err, &permErr) {
+			return nil, fmt.Errorf("preflight failed: the credentials need the %s permission on account %s, grant it to the api token or check -account-id: %s",
+				permErr.Permission, permErr.AccountID, permErr.Err)
+		}
+		return nil, fmt.Errorf("preflight failed: %s", err)
+	}
+
+	slog.Debug("preflight passed", "check_write", checkWrite)
+	return cli, nil
+}
+
+// selection is the set of images a command operates on and their desired state.
+type selection struct {
+	filter imageFilter
+	policy *policy.Policy
+	// explicitIDs are the ids given with -ids-file, if any.
+	explicitIDs []string
+	// ownerKeys are the metadata keys telling who uploaded an image.
+	ownerKeys []string
+}
+
+// readsStdin tells whether the images to secure are read from stdin, leaving it unavailable for the prompts.
+func (o *options) readsStdin() bool {
+	return o.idsFile == "-" || (o.accessLogs != nil && slices.Contains(splitList(o.accessLogs.files), "-"))
+}
+
+// setExplicitIDs restricts the selection to the explicit ids, secured without listing the account.
+func (s *selection) setExplicitIDs(ids []string) {
+	s.explicitIDs = ids
+	s.filter.ids = map[string]bool{}
+	for _, id := range ids {
+		s.filter.ids[id] = true
+	}
+}
+
+// loadSelection reads the exclude, ids and policy files given in the options.
+func (o *options) loadSelection() (*selection, error) {
+	if o.idsFile != "" && o.policyFile != "" {
+		return nil, errors.New("-ids-file and -policy cannot be used together")
+	}
+
+	if o.accessLogs != nil && (o.idsFile != "" || o.policyFile != "") {
+		return nil, errors.New("-ids-file and -policy cannot be used with ingest-logs, the images are read from the access logs")
+	}
+
+	excluded := map[string]bool{}
+	for _, id := range splitList(o.excludeIDs) {
+		excluded[id] = true
+	}
+
+	if o.excludeFile != "" {
+		ids, err := readIDsFile(o.excludeFile, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exclude file: %s", err)
+		}
+
+		for _, id := range ids {
+			excluded[id] = true
+		}
+	}
+
+	sel := selection{
+		filter: imageFilter{
+			filenameGlob: o.filenameGlob,
+			metadata:     o.metadata,
+			excluded:     excluded,
+			minAge:       o.minAge,
+		},
+		policy:    policy.Default(),
+		ownerKeys: splitList(o.ownerKeys),
+	}
+
+	switch {
+	case o.accessLogs != nil:
+		ids, err := o.accessLogs.read(o.accountHash)
+		if err != nil {
+			return nil, err
+		}
+		sel.setExplicitIDs(ids)
+	case o.idsFile != "":
+		ids, err := readIDsFile(o.idsFile, o.accountHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ids file: %s", err)
+		}
+		sel.setExplicitIDs(ids)
+	}
+
+	if err := sel.filter.validate(); err != nil {
+		return nil, err
+	}
+
+	s, err := parseShard(o.shard)
+	if err != nil {
+		return nil, err
+	}
+	sel.filter.shard = s
+
+	if o.policyFile != "" {
+		p, err := policy.Load(o.policyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load policy: %s", err)
+		}
+		sel.policy = p
+	}
+	return &sel, nil
+}
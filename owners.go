@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/mail"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// defaultOwnerKeys are the metadata keys telling who uploaded an image, looked up in order.
+const defaultOwnerKeys = "uploader,owner"
+
+// imageOwner returns the value of the first owner key set in the metadata, empty if none is.
+func imageOwner(meta map[string]any, keys []string) string {
+	for _, k := range keys {
+		if v, ok := meta[k]; ok && v != nil {
+			if owner := strings.TrimSpace(fmt.Sprint(v)); owner != "" {
+				return owner
+			}
+		}
+	}
+	return ""
+}
+
+// ownersConfig holds the flags notifying the owners of the images secured.
+type ownersConfig struct {
+	webhook       string
+	email         bool
+	emailTemplate string
+}
+
+func (c *ownersConfig) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.webhook, "notify-owners-webhook", "", "https url to post a JSON event to for every owner of images secured, signed like the reports")
+	fs.BoolVar(&c.email, "notify-owners-email", false, "email the owners of the images secured, when the owner is an email address, through the -smtp-addr server")
+	fs.StringVar(&c.emailTemplate, "owner-email-template", "", "text/template file of the owner emails, given .AccountID, .Owner and .ImageIDs, the first line being the subject")
+}
+
+// ownerNotifier tells the owners of the images, found in their metadata, that they were secured,
+// rather than changing how their images are served under them silently.
+// Its methods are no-ops on a nil ownerNotifier.
+type ownerNotifier struct {
+	keys    []string
+	webhook string
+	secret  string
+	// mailer sends the emails to the owners, nil when they aren't emailed.
+	mailer *mailer
+	tmpl   *template.Template
+
+	mu sync.Mutex
+	// secured are the ids of the images secured since the last notification, by owner.
+	secured map[string][]string
+}
+
+var defaultOwnerEmail = template.Must(template.New("owner").Parse(`Your Cloudflare Images were secured
+Hello,
+
+The following images of Cloudflare account {{.AccountID}}, uploaded by {{.Owner}}, were
+accessible by anyone knowing their URL. They now require signed URLs:
+{{range .ImageIDs}}
+  - {{.}}{{end}}
+
+Serving them requires signing their URLs from now on. If an image must stay public,
+ask for it to be added to the intentionally public images.
+`))
+
+// newOwnerNotifier returns the notifier configured by the flags, nil when the owners aren't notified.
+func newOwnerNotifier(cfg ownersConfig, ownerKeys string, m *mailer) (*ownerNotifier, error) {
+	if cfg.webhook == "" && !cfg.email {
+		return nil, nil
+	}
+
+	n := ownerNotifier{keys: splitList(ownerKeys), webhook: cfg.webhook, tmpl: defaultOwnerEmail, secured: map[string][]string{}}
+	if len(n.keys) == 0 {
+		n.keys = splitList(defaultOwnerKeys)
+	}
+
+	if cfg.webhook != "" {
+		if err := validateReportURL(cfg.webhook); err != nil {
+			return nil, err
+		}
+		n.secret = os.Getenv(reportSecretEnv)
+	}
+
+	if cfg.email {
+		if m == nil {
+			return nil, errors.New("-notify-owners-email requires the -smtp-addr, -smtp-from and -smtp-to flags")
+		}
+		n.mailer = m
+	}
+
+	if cfg.emailTemplate != "" {
+		tmpl, err := template.ParseFiles(cfg.emailTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load owner email template: %s", err)
+		}
+		n.tmpl = tmpl
+	}
+	return &n, nil
+}
+
+// observe is an applier observer collecting the images secured by owner.
+// The owner of the images secured without being listed isn't known.
+func (n *ownerNotifier) observe(c change, err error) {
+	if n == nil || err != nil || c.alreadySecured || !c.RequireSignedURLs {
+		return
+	}
+
+	owner := imageOwner(c.meta, n.keys)
+	if owner == "" {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.secured[owner] = append(n.secured[owner], c.ImageID)
+}
+
+// ownerEvent is the JSON event posted to -notify-owners-webhook.
+type ownerEvent struct {
+	Event     string   `json:"event"`
+	AccountID string   `json:"account_id"`
+	Owner     string   `json:"owner"`
+	ImageIDs  []string `json:"image_ids"`
+}
+
+// notify tells every owner about their images secured since the last call.
+// A notification that fails is logged, the images won't be notified again.
+func (n *ownerNotifier) notify(ctx context.Context, accountID string) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	secured := n.secured
+	n.secured = map[string][]string{}
+	n.mu.Unlock()
+
+	for _, owner := range slices.Sorted(maps.Keys(secured)) {
+		ev := ownerEvent{Event: "images.secured", AccountID: accountID, Owner: owner, ImageIDs: secured[owner]}
+		slices.Sort(ev.ImageIDs)
+
+		if n.webhook != "" {
+			if err := n.post(ctx, ev); err != nil {
+				slog.Error("failed to notify owner", "owner", owner, "images", len(ev.ImageIDs), "error", err)
+			}
+		}
+
+		// The owner comes from the metadata, it is only emailed when it is a well formed address.
+		if addr, err := mail.ParseAddress(owner); n.mailer != nil && err == nil {
+			if err := n.email(addr.Address, ev); err != nil {
+				slog.Error("failed to email owner", "owner", owner, "images", len(ev.ImageIDs), "error", err)
+			}
+		}
+	}
+}
+
+func (n *ownerNotifier) post(ctx context.Context, ev ownerEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("could not encode event: %s", err)
+	}
+
+	return postJSON(ctx, n.webhook, body, signatureHeader(n.secret, body))
+}
+
+func (n *ownerNotifier) email(to string, ev ownerEvent) error {
+	var b strings.Builder
+	if err := n.tmpl.Execute(&b, ev); err != nil {
+		return fmt.Errorf("could not render email: %s", err)
+	}
+
+	subject, body, _ := strings.Cut(b.String(), "\n")
+	return n.mailer.sendTo([]string{to}, strings.TrimSpace(subject), body)
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const planVersion = 1
+
+// plan is the set of changes written by the plan command and executed by the apply command.
+type plan struct {
+	Version   int       `json:"version"`
+	AccountID string    `json:"account_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Changes   []change  `json:"changes"`
+	Excluded  []string  `json:"excluded,omitempty"`
+	Drafts    []string  `json:"drafts,omitempty"`
+	TooRecent []string  `json:"too_recent,omitempty"`
+	Unknown   []string  `json:"unknown,omitempty"`
+}
+
+func writePlan(name string, p *plan) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode plan: %s", err)
+	}
+
+	if err := os.WriteFile(name, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not write plan: %s", err)
+	}
+	return nil
+}
+
+func readPlan(name string) (*plan, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read plan: %s", err)
+	}
+
+	var p plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("could not decode plan: %s", err)
+	}
+
+	if p.Version != planVersion {
+		return nil, fmt.Errorf("unsupported plan version %d", p.Version)
+	}
+	return &p, nil
+}
+
+// planCmd writes the changes the secure command would make to a plan file, without making them.
+func planCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	opts.registerSelectionFlags(fs)
+	outPtr := fs.String("out", "plan.json", "file to write the plan to")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	sel, err := opts.loadSelection()
+	if err != nil {
+		return err
+	}
+
+	cli, err := opts.connect(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	planned, err := planChanges(ctx, cli, sel)
+	if err != nil {
+		return err
+	}
+	changes, excluded := planned.changes, planned.excluded
+	logDrafts(planned.drafts)
+	logTooRecent(planned.tooRecent)
+
+	p := plan{
+		Version:   planVersion,
+		AccountID: opts.accountID,
+		CreatedAt: time.Now().UTC(),
+		Changes:   changes,
+		Excluded:  excluded,
+		Drafts:    planned.drafts,
+		TooRecent: planned.tooRecent,
+		Unknown:   planned.unknown,
+	}
+
+	if err := writePlan(*outPtr, &p); err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		fmt.Printf("image '%s' will be %s\n", c.ImageID, c.verb())
+	}
+
+	for _, id := range excluded {
+		fmt.Printf("image '%s' is intentionally public\n", id)
+	}
+
+	fmt.Printf("plan: %d changes, written to %s\n", len(changes), *outPtr)
+	return nil
+}
+
+// applyCmd executes exactly the changes of a plan file.
+func applyCmd(ctx context.Context, args []string) error {
+	return planFileCmd(ctx, args, "apply", "plan", "plan file written by the plan command")
+}
+
+// retryCmd re-attempts only the changes that failed in a previous run.
+func retryCmd(ctx context.Context, args []string) error {
+	return planFileCmd(ctx, args, "retry", "failed-file", "file with the failed changes written by -failed-out")
+}
+
+// planFileCmd runs a command executing the changes of the plan file given with the named flag.
+func planFileCmd(ctx context.Context, args []string, name, fileFlag, fileUsage string) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	opts.registerApplyFlags(fs)
+	opts.registerReportFlags(fs)
+	opts.registerGateFlags(fs)
+	planPtr := fs.String(fileFlag, "", fileUsage)
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if *planPtr == "" {
+		fs.Usage()
+		return fmt.Errorf("-%s is required", fileFlag)
+	}
+
+	p, err := readPlan(*planPtr)
+	if err != nil {
+		return err
+	}
+
+	if opts.accountID == "" {
+		opts.accountID = p.AccountID
+	}
+
+	if opts.accountID != p.AccountID {
+		return fmt.Errorf("plan was made for account '%s', not '%s'", p.AccountID, opts.accountID)
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	if err := opts.validateApply(); err != nil {
+		return err
+	}
+
+	if err := opts.validateReport(); err != nil {
+		return err
+	}
+
+	if opts.smtp.digest == "daily" {
+		return errors.New("-email-digest daily requires the secure command with -watch or -schedule")
+	}
+
+	if err := opts.openSinks(ctx); err != nil {
+		return err
+	}
+
+	stopProfiling, err := opts.startProfiling()
+	if err != nil {
+		return err
+	}
+	defer stopProfiling()
+
+	if err := opts.lockRun(); err != nil {
+		return err
+	}
+	defer opts.lock.release()
+
+	cli, err := opts.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := opts.runContext(ctx)
+	defer cancel()
+
+	start := time.Now()
+	usageStart := opts.usage.snapshot()
+	a := opts.newApplier(cli)
+
+	prog := newProgress(opts.progress, opts.logFormat)
+	prog.planned(len(p.Changes))
+	prog.listingDone()
+	a.observe(prog.observe)
+	a.quiet = prog.shown()
+
+	res := a.apply(ctx, p.Changes)
+	prog.end()
+	sum := newRunSummary(opts.accountID, start, usageStart, &planned{changes: p.Changes}, res)
+
+	if err := opts.writeFailed(res); err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		sum.Interrupted = true
+		opts.report(ctx, sum)
+		return interruption(ctx)
+	}
+
+	// Fetch again to see if any change of the plan didn't go through.
+	images, err := cli.ListImages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %s", err)
+	}
+
+	current := make(map[string]bool, len(images))
+	for _, image := range images {
+		current[image.ID] = image.RequireSignedURLs
+	}
+
+	var remaining []change
+	for _, c := range p.Changes {
+		if requireSignedURLs, ok := current[c.ImageID]; !ok || requireSignedURLs != c.RequireSignedURLs {
+			remaining = append(remaining, c)
+		}
+	}
+	sum.setRemaining(remaining)
+	sum.Duration = time.Since(start)
+	opts.report(ctx, sum)
+
+	slog.Info("done")
+	return opts.gate(sum)
+}
+
+// writeFailed writes the failed changes of the run as a plan for the retry command,
+// if -failed-out was given.
+func (o *options) writeFailed(res *applyResult) error {
+	if o.failedOut == "" {
+		return nil
+	}
+
+	p := plan{
+		Version:   planVersion,
+		AccountID: o.accountID,
+		CreatedAt: time.Now().UTC(),
+		Changes:   res.failed,
+	}
+
+	if err := writePlan(o.failedOut, &p); err != nil {
+		return fmt.Errorf("failed to write failed changes: %s", err)
+	}
+
+	if len(res.failed) > 0 {
+		slog.Warn("failed changes written, re-attempt them with the retry command", "failed", len(res.failed), "file", o.failedOut)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Package policy describes the desired protection state of Cloudflare images.
+//
+// A policy is a list of rules evaluated in order, the first rule matching
+// an image decides its action, falling back to the policy default:
+//
+//	default: require
+//	rules:
+//	  - name: public assets
+//	    match:
+//	      metadata:
+//	        visibility: public
+//	    action: allow
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is what the policy wants for an image.
+type Action string
+
+const (
+	// Require makes the image require signed URLs.
+	Require Action = "require"
+	// Allow leaves the image as it is, signed or not.
+	Allow Action = "allow"
+	// Public makes the image accessible without signed URLs.
+	Public Action = "public"
+)
+
+// Policy is a set of ordered rules with a default action.
+type Policy struct {
+	Default Action `yaml:"default"`
+	Rules   []Rule `yaml:"rules"`
+}
+
+// Rule applies an action to the images it matches.
+type Rule struct {
+	Name   string `yaml:"name"`
+	Match  Match  `yaml:"match"`
+	Action Action `yaml:"action"`
+}
+
+// Match selects images by filename glob and metadata key=value pairs.
+// An empty match selects every image.
+type Match struct {
+	Filename string            `yaml:"filename"`
+	Metadata map[string]string `yaml:"metadata"`
+}
+
+// Default is the policy used when no policy file is given:
+// every image must require signed URLs.
+func Default() *Policy {
+	return &Policy{Default: Require}
+}
+
+// Load reads and validates the YAML policy file at the given path.
+func Load(name string) (*Policy, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not open policy file: %s", err)
+	}
+	defer f.Close()
+
+	var p Policy
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("could not decode policy file: %s", err)
+	}
+
+	if p.Default == "" {
+		p.Default = Require
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks the actions and filename globs of the policy.
+func (p *Policy) Validate() error {
+	if !p.Default.valid() {
+		return fmt.Errorf("invalid default action '%s'", p.Default)
+	}
+
+	for i, r := range p.Rules {
+		if !r.Action.valid() {
+			return fmt.Errorf("rule %d: invalid action '%s'", i+1, r.Action)
+		}
+
+		if r.Match.Filename != "" {
+			if _, err := path.Match(r.Match.Filename, ""); err != nil {
+				return fmt.Errorf("rule %d: invalid filename glob '%s': %s", i+1, r.Match.Filename, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the action for the image and the name of the rule that decided it.
+// The rule name is empty when the default action applies.
+func (p *Policy) Evaluate(image cloudflareclient.Image) (Action, string) {
+	for i, r := range p.Rules {
+		if r.Match.matches(image) {
+			name := r.Name
+			if name == "" {
+				name = fmt.Sprintf("rule %d", i+1)
+			}
+			return r.Action, name
+		}
+	}
+	return p.Default, ""
+}
+
+// Desired returns the desired requireSignedURLs value for the image,
+// and false if the policy leaves the image as it is.
+func (p *Policy) Desired(image cloudflareclient.Image) (requireSignedURLs bool, ok bool) {
+	action, _ := p.Evaluate(image)
+	switch action {
+	case Require:
+		return true, true
+	case Public:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func (a Action) valid() bool {
+	switch a {
+	case Require, Allow, Public:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m Match) matches(image cloudflareclient.Image) bool {
+	if m.Filename != "" {
+		if ok, _ := path.Match(m.Filename, image.Filename); !ok {
+			return false
+		}
+	}
+
+	for k, want := range m.Metadata {
+		got, ok := image.Meta[k]
+		if !ok || fmt.Sprint(got) != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+)
+
+// registerPprofRoutes registers the pprof endpoints on the mux, under /debug/pprof/.
+func registerPprofRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// startProfiling starts the cpu profile of -cpuprofile, if any, returning the function
+// to call at the end of the run to stop it and write the heap profile of -memprofile.
+func (o *options) startProfiling() (func(), error) {
+	var cpu *os.File
+	if o.cpuProfile != "" {
+		f, err := os.Create(o.cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cpu profile: %s", err)
+		}
+
+		if err := runtimepprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start cpu profile: %s", err)
+		}
+		cpu = f
+	}
+
+	return func() {
+		if cpu != nil {
+			runtimepprof.StopCPUProfile()
+			cpu.Close()
+		}
+
+		if o.memProfile != "" {
+			if err := writeHeapProfile(o.memProfile); err != nil {
+				slog.Error("failed to write memory profile", "file", o.memProfile, "error", err)
+			}
+		}
+	}, nil
+}
+
+func writeHeapProfile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Up to date statistics of the memory still in use.
+	runtime.GC()
+	return runtimepprof.WriteHeapProfile(f)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	progressBarInterval  = 200 * time.Millisecond
+	progressLineInterval = 10 * time.Second
+	progressBarWidth     = 30
+)
+
+// stderr is where the logs are written, below the progress bar when one is shown.
+var stderr = &statusLineWriter{out: os.Stderr}
+
+// statusLineWriter writes to a terminal keeping a status line, the progress bar,
+// at the bottom: the line is cleared before writing and redrawn after.
+type statusLineWriter struct {
+	mu   sync.Mutex
+	out  *os.File
+	line string
+}
+
+func (w *statusLineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.line == "" {
+		return w.out.Write(p)
+	}
+
+	fmt.Fprint(w.out, "\r\033[K")
+	n, err := w.out.Write(p)
+	fmt.Fprint(w.out, w.line)
+	return n, err
+}
+
+// setLine replaces the status line, an empty line removing it.
+func (w *statusLineWriter) setLine(line string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	fmt.Fprint(w.out, "\r\033[K"+line)
+	w.line = line
+}
+
+// isTerminal tells whether the file is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// progress follows the changes applied during the securing phase, shown as a progress
+// bar with the rate and ETA on terminals, or as a periodic log line otherwise.
+// Its methods are no-ops on a nil progress.
+type progress struct {
+	bar   bool
+	start time.Time
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu        sync.Mutex
+	total     int
+	processed int
+	failed    int
+	// listed is set once every change is known, the total being final.
+	listed bool
+}
+
+// newProgress starts following the progress in the given mode: auto for a bar on
+// terminals and log lines otherwise, bar, lines, or off which returns nil.
+func newProgress(mode, logFormat string) *progress {
+	bar := false
+	switch mode {
+	case "off":
+		return nil
+	case "bar":
+		bar = true
+	case "auto":
+		bar = isTerminal(os.Stderr) && logFormat != "json"
+	}
+
+	p := progress{
+		bar:   bar,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go p.run()
+	return &p
+}
+
+func (p *progress) run() {
+	defer close(p.done)
+
+	interval := progressLineInterval
+	if p.bar {
+		interval = progressBarInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			if p.bar {
+				stderr.setLine("")
+			}
+			return
+		case <-ticker.C:
+			if p.bar {
+				stderr.setLine(p.barLine())
+			} else {
+				p.logLine()
+			}
+		}
+	}
+}
+
+// shown tells whether the progress is shown, as a bar or as periodic log lines,
+// replacing the log line of each change.
+func (p *progress) shown() bool {
+	return p != nil
+}
+
+// planned adds n changes to the total.
+func (p *progress) planned(n int) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += n
+}
+
+// listingDone tells every change is known.
+func (p *progress) listingDone() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.listed = true
+}
+
+func (p *progress) observe(_ change, err error) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.processed++
+	if err != nil {
+		p.failed++
+	}
+}
+
+// end stops following the progress, removing the bar.
+func (p *progress) end() {
+	if p == nil {
+		return
+	}
+
+	close(p.stop)
+	<-p.done
+}
+
+// snapshot returns the counters along with the rate of changes per second
+// and the estimated time left, zero until the total is known.
+func (p *progress) snapshot() (total, processed, failed int, listed bool, rate float64, eta time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		rate = float64(p.processed) / elapsed
+	}
+
+	if p.listed && rate > 0 {
+		eta = time.Duration(float64(p.total-p.processed) / rate * float64(time.Second))
+	}
+	return p.total, p.processed, p.failed, p.listed, rate, eta
+}
+
+func (p *progress) barLine() string {
+	total, processed, failed, listed, rate, eta := p.snapshot()
+
+	filled := 0
+	if total > 0 {
+		filled = min(processed*progressBarWidth/total, progressBarWidth)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s%s] %d/%d", strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), processed, total)
+	if !listed {
+		b.WriteString("+ listing")
+	} else if total > 0 {
+		fmt.Fprintf(&b, " %d%%", processed*100/total)
+	}
+
+	fmt.Fprintf(&b, " %.1f/s", rate)
+	if failed > 0 {
+		fmt.Fprintf(&b, " %d failed", failed)
+	}
+
+	if listed {
+		fmt.Fprintf(&b, " ETA %s", eta.Round(time.Second))
+	}
+	return b.String()
+}
+
+func (p *progress) logLine() {
+	total, processed, failed, listed, rate, eta := p.snapshot()
+
+	attrs := []any{"processed", processed, "total", total, "failed", failed, "rate", fmt.Sprintf("%.1f/s", rate)}
+	if listed {
+		attrs = append(attrs, "eta", eta.Round(time.Second))
+	} else {
+		attrs = append(attrs, "listing", true)
+	}
+	slog.Info("progress", attrs...)
+}
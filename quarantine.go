@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// quarantinedAtKey is the metadata key recording when an image was quarantined.
+const quarantinedAtKey = "quarantined_at"
+
+// quarantineConfig holds the flags quarantining the offending images: tagging them
+// when they are secured and, for the ones matching a dangerous pattern, deleting them
+// once they have been quarantined for the grace period.
+type quarantineConfig struct {
+	enabled    bool
+	deleteGlob string
+	grace      time.Duration
+}
+
+func (c *quarantineConfig) registerFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.enabled, "quarantine", false, "tag the images secured with "+quarantinedAtKey+" in their metadata, keeping the time of their first quarantine")
+	fs.StringVar(&c.deleteGlob, "quarantine-delete", "", "filename glob of dangerous images to delete once quarantined for -quarantine-grace, requires -quarantine")
+	fs.DurationVar(&c.grace, "quarantine-grace", 7*24*time.Hour, "how long images stay quarantined before -quarantine-delete deletes them")
+}
+
+func (c *quarantineConfig) validate() error {
+	if c.deleteGlob == "" {
+		return nil
+	}
+
+	if !c.enabled {
+		return errors.New("-quarantine-delete requires -quarantine")
+	}
+
+	if _, err := path.Match(c.deleteGlob, ""); err != nil {
+		return fmt.Errorf("invalid -quarantine-delete glob '%s': %s", c.deleteGlob, err)
+	}
+
+	if c.grace <= 0 {
+		return errors.New("-quarantine-grace must be positive")
+	}
+	return nil
+}
+
+// expired tells whether the image matches the dangerous pattern and its grace period is over.
+func (c *quarantineConfig) expired(img cloudflareclient.Image, now time.Time) bool {
+	if c.deleteGlob == "" {
+		return false
+	}
+
+	if ok, _ := path.Match(c.deleteGlob, img.Filename); !ok {
+		return false
+	}
+
+	v, ok := img.Meta[quarantinedAtKey].(string)
+	if !ok {
+		return false
+	}
+
+	at, err := time.Parse(time.RFC3339, v)
+	return err == nil && now.Sub(at) >= c.grace
+}
+
+// purge deletes the images whose quarantine expired with the applier, for the deletes to be
+// audited, published and counted against -max-changes like the changes, returning the ids
+// of the images deleted. Images that fail to be deleted or are held back are left for the next run.
+func (c *quarantineConfig) purge(ctx context.Context, a *applier, ids []string) map[string]bool {
+	deleted := map[string]bool{}
+	if len(ids) == 0 {
+		return deleted
+	}
+
+	deletes := make([]change, 0, len(ids))
+	for _, id := range ids {
+		deletes = append(deletes, change{ImageID: id, delete: true})
+	}
+
+	res := a.apply(ctx, deletes)
+	for _, d := range res.applied {
+		deleted[d.ImageID] = true
+	}
+
+	if len(deleted) > 0 {
+		slog.Warn("deleted quarantined images", "deleted", len(deleted), "grace", c.grace)
+	}
+	return deleted
+}
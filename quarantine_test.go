@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/cloudflareclient/cloudflaretest"
+)
+
+func TestQuarantinePurge(t *testing.T) {
+	srv := cloudflaretest.NewServer("account", "token",
+		cloudflareclient.Image{ID: "a", RequireSignedURLs: true},
+		cloudflareclient.Image{ID: "b", RequireSignedURLs: true},
+		cloudflareclient.Image{ID: "c", RequireSignedURLs: true},
+	)
+	defer srv.Close()
+
+	name := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := openAuditLog(name, "tester@host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newApplier(srv.Client(), 1)
+	a.observe(audit.record)
+
+	// One change was made by the pass already, one delete is left within -max-changes.
+	a.maxChanges, a.dispatched = 2, 1
+
+	c := quarantineConfig{enabled: true, deleteGlob: "*"}
+	deleted := c.purge(context.Background(), a, []string{"a", "b", "c"})
+	audit.f.Close()
+
+	if len(deleted) != 1 || !deleted["a"] {
+		t.Fatalf("deleted %v, want only a within -max-changes", deleted)
+	}
+
+	if len(srv.Images()) != 2 {
+		t.Errorf("account has %d images left, want 2", len(srv.Images()))
+	}
+
+	lines := readLines(t, name)
+	if len(lines) != 1 {
+		t.Fatalf("audit log has %d lines, want the delete alone", len(lines))
+	}
+
+	var rec auditRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.ImageID != "a" || rec.Action != "delete" || rec.Result != "ok" {
+		t.Errorf("audit record is %+v, want the delete of a", rec)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// reportCmd writes a report of the protection of the account, in markdown or html
+// for security reviews, or in sarif for security tooling. It doesn't modify the images.
+func reportCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	opts.registerSelectionFlags(fs)
+	formatPtr := fs.String("format", "markdown", "format of the report, markdown, html or sarif")
+	outPtr := fs.String("out", "", "file to write the report to, defaults to stdout")
+	attributePtr := fs.Bool("attribute-uploads", false, "find who uploaded each unprotected image in the audit log of the account, requiring the Account Audit Logs Read permission")
+	historyPtr := fs.String("history", "", "glob of inventory files written by -inventory-out, to chart the unprotected images over time")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	if *formatPtr != "markdown" && *formatPtr != "html" && *formatPtr != "sarif" {
+		return fmt.Errorf("-format must be markdown, html or sarif, not '%s'", *formatPtr)
+	}
+
+	sel, err := opts.loadSelection()
+	if err != nil {
+		return err
+	}
+
+	if sel.filter.ids != nil {
+		return errors.New("-ids-file cannot be used with report, it covers the whole account")
+	}
+
+	var history []*inventory
+	if *historyPtr != "" {
+		history, err = readHistory(*historyPtr, opts.accountID)
+		if err != nil {
+			return err
+		}
+	}
+
+	cli, err := opts.connect(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	images, err := cli.ListImages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %s", err)
+	}
+
+	r := newSecurityReport(opts.accountID, images, sel, history)
+	if *attributePtr {
+		if err := attributeUploads(ctx, cli, r); err != nil {
+			return err
+		}
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outPtr != "" {
+		f, err := os.Create(*outPtr)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %s", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch *formatPtr {
+	case "html":
+		err = htmlReport.Execute(out, r)
+	case "sarif":
+		err = writeSARIF(out, r)
+	default:
+		err = markdownReport.Execute(out, r)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report: %s", err)
+	}
+	return nil
+}
+
+// readHistory reads the inventories matching the glob, oldest first.
+func readHistory(glob, accountID string) ([]*inventory, error) {
+	names, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -history glob: %s", err)
+	}
+
+	var history []*inventory
+	for _, name := range names {
+		inv, err := readInventory(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+
+		if inv.AccountID != accountID {
+			return nil, fmt.Errorf("inventory %s was taken for account '%s', not '%s'", name, inv.AccountID, accountID)
+		}
+		history = append(history, inv)
+	}
+
+	slices.SortFunc(history, func(a, b *inventory) int {
+		return a.TakenAt.Compare(b.TakenAt)
+	})
+	return history, nil
+}
+
+// securityReport is the content of a report.
+type securityReport struct {
+	AccountID   string
+	GeneratedAt time.Time
+
+	Total       int
+	Protected   int
+	Unprotected int
+	// PublicAgainstPolicy are the images protected while the policy wants them public.
+	PublicAgainstPolicy int
+	Excluded            int
+	Drafts              int
+	// TooRecent are the images uploaded less than -min-age ago, left as they are.
+	TooRecent int
+
+	// Offending are the images which should require signed URLs but don't.
+	Offending []reportImage
+	// Attributed tells whether the uploads of the offending images were looked up in the audit log.
+	Attributed bool
+	// History is the number of unprotected images over time, ending with now.
+	History []reportPoint
+}
+
+type reportImage struct {
+	ID       string
+	Filename string
+	Uploaded time.Time
+	// Owner is who uploaded the image, from the owner keys of its metadata, if any.
+	Owner string
+	// UploadedBy is who uploaded the image according to the audit log, with -attribute-uploads.
+	UploadedBy string
+	// Metadata is the metadata of the image, which usually tells who uploaded it.
+	Metadata string
+}
+
+type reportPoint struct {
+	At          time.Time
+	Unprotected int
+	// Bar is the width of the point in the chart, up to reportChartWidth.
+	Bar int
+}
+
+// reportChartWidth is the width of the longest bar of the history chart.
+const reportChartWidth = 40
+
+func newSecurityReport(accountID string, images []cloudflareclient.Image, sel *selection, history []*inventory) *securityReport {
+	r := securityReport{
+		AccountID:   accountID,
+		GeneratedAt: time.Now().UTC(),
+		Total:       len(images),
+		Protected:   countProtected(images),
+	}
+
+	changes, excluded, drafts, tooRecent := sel.filter.changes(images, sel.policy)
+	r.Excluded, r.Drafts, r.TooRecent = len(excluded), len(drafts), len(tooRecent)
+
+	byID := make(map[string]cloudflareclient.Image, len(images))
+	for _, img := range images {
+		byID[img.ID] = img
+	}
+
+	for _, c := range changes {
+		if !c.RequireSignedURLs {
+			r.PublicAgainstPolicy++
+			continue
+		}
+
+		img := byID[c.ImageID]
+		r.Offending = append(r.Offending, reportImage{
+			ID:       img.ID,
+			Filename: img.Filename,
+			Uploaded: img.Uploaded,
+			Owner:    imageOwner(img.Meta, sel.ownerKeys),
+			Metadata: formatMetadata(img.Meta),
+		})
+	}
+	r.Unprotected = len(r.Offending)
+
+	// The oldest images first, they have been exposed the longest.
+	slices.SortFunc(r.Offending, func(a, b reportImage) int {
+		return a.Uploaded.Compare(b.Uploaded)
+	})
+
+	for _, inv := range history {
+		changes, _, _, _ := sel.filter.changes(inv.Images, sel.policy)
+		n := 0
+		for _, c := range changes {
+			if c.RequireSignedURLs {
+				n++
+			}
+		}
+		r.History = append(r.History, reportPoint{At: inv.TakenAt, Unprotected: n})
+	}
+
+	if len(r.History) > 0 {
+		r.History = append(r.History, reportPoint{At: r.GeneratedAt, Unprotected: r.Unprotected})
+
+		highest := 0
+		for _, p := range r.History {
+			highest = max(highest, p.Unprotected)
+		}
+
+		if highest > 0 {
+			for i := range r.History {
+				r.History[i].Bar = r.History[i].Unprotected * reportChartWidth / highest
+			}
+		}
+	}
+	return &r
+}
+
+// formatMetadata formats the metadata as key=value pairs, sorted by key.
+func formatMetadata(meta map[string]any) string {
+	pairs := make([]string, 0, len(meta))
+	for k, v := range meta {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+var reportFuncs = map[string]any{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.DateOnly)
+	},
+	"datetime": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04 MST")
+	},
+	"bar": func(n int) string {
+		return strings.Repeat("█", n)
+	},
+	"cell": func(s string) string {
+		return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+	},
+}
+
+var markdownReport = template.Must(template.New("report").Funcs(reportFuncs).Parse(`# Cloudflare Images protection report
+
+Account ` + "`{{.AccountID}}`" + `, generated {{datetime .GeneratedAt}}.
+
+| | Images |
+|---|---:|
+| Total | {{.Total}} |
+| Requiring signed URLs | {{.Protected}} |
+| Unprotected against the policy | {{.Unprotected}} |
+| Protected against the policy | {{.PublicAgainstPolicy}} |
+| Intentionally public | {{.Excluded}} |
+| Drafts | {{.Drafts}} |
+| Uploaded less than -min-age ago | {{.TooRecent}} |
+{{if .History}}
+## Unprotected images over time
+
+` + "```" + `
+{{range .History}}{{datetime .At}} {{bar .Bar}} {{.Unprotected}}
+{{end}}` + "```" + `
+{{end}}
+## Unprotected images
+{{if .Offending}}
+| Image | Filename | Uploaded | Owner |{{if .Attributed}} Uploaded by |{{end}} Metadata |
+|---|---|---|---|{{if .Attributed}}---|{{end}}---|
+{{range .Offending}}| ` + "`{{.ID}}`" + ` | {{cell .Filename}} | {{date .Uploaded}} | {{cell .Owner}} |{{if $.Attributed}} {{cell .UploadedBy}} |{{end}} {{cell .Metadata}} |
+{{end}}{{else}}
+Every image is protected.
+{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Cloudflare Images protection report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.bar { background: #c0392b; height: 1em; display: inline-block; }
+</style>
+</head>
+<body>
+<h1>Cloudflare Images protection report</h1>
+<p>Account <code>{{.AccountID}}</code>, generated {{datetime .GeneratedAt}}.</p>
+<table>
+<tr><th>Total</th><td>{{.Total}}</td></tr>
+<tr><th>Requiring signed URLs</th><td>{{.Protected}}</td></tr>
+<tr><th>Unprotected against the policy</th><td>{{.Unprotected}}</td></tr>
+<tr><th>Protected against the policy</th><td>{{.PublicAgainstPolicy}}</td></tr>
+<tr><th>Intentionally public</th><td>{{.Excluded}}</td></tr>
+<tr><th>Drafts</th><td>{{.Drafts}}</td></tr>
+<tr><th>Uploaded less than -min-age ago</th><td>{{.TooRecent}}</td></tr>
+</table>
+{{if .History}}
+<h2>Unprotected images over time</h2>
+<table>
+{{range .History}}<tr><td>{{datetime .At}}</td><td><span class="bar" style="width: {{.Bar}}em"></span> {{.Unprotected}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Unprotected images</h2>
+{{if .Offending}}
+<table>
+<tr><th>Image</th><th>Filename</th><th>Uploaded</th><th>Owner</th>{{if .Attributed}}<th>Uploaded by</th>{{end}}<th>Metadata</th></tr>
+{{range .Offending}}<tr><td><code>{{.ID}}</code></td><td>{{.Filename}}</td><td>{{date .Uploaded}}</td><td>{{.Owner}}</td>{{if $.Attributed}}<td>{{.UploadedBy}}</td>{{end}}<td>{{.Metadata}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>Every image is protected.</p>
+{{end}}
+</body>
+</html>
+`))
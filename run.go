@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// errImageTimeout is the cause of the updates of the images lasting longer than -image-timeout.
+var errImageTimeout = errors.New("image timed out")
+
+// interruption returns why the run stopped before the end, lasting longer than -run-timeout
+// or errInterrupted.
+func interruption(ctx context.Context) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errRunTimeout) {
+		return cause
+	}
+	return errInterrupted
+}
+
+// planned is what a run is about to do.
+type planned struct {
+	changes []change
+	// excluded are the images left as they are because they are intentionally public.
+	excluded []string
+	// drafts are the images left as they are because they are still being uploaded.
+	drafts []string
+	// tooRecent are the images left as they are because they were uploaded less than -min-age ago.
+	tooRecent []string
+	// unknown are the explicit ids of images the account doesn't have.
+	unknown []string
+	// images are the images of the account, nil when operating on explicit ids.
+	images []cloudflareclient.Image
+	// listed and protected are the number of images listed, and of those already
+	// requiring signed URLs, zero when operating on explicit ids.
+	listed    int
+	protected int
+}
+
+// planChanges works out the changes needed for the selected images to reach
+// the desired state, along with the excluded images left as they are.
+func planChanges(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI, sel *selection) (*planned, error) {
+	var p planned
+
+	if sel.filter.ids != nil {
+		changes, excluded := sel.filter.explicitChanges(sel.explicitIDs)
+		p.changes, p.drafts, p.tooRecent, p.unknown = checkExplicit(ctx, cli, sel.filter, changes)
+		p.excluded = excluded
+		logUnknown(p.unknown)
+		return &p, nil
+	}
+
+	images, err := cli.ListImages(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %s", err)
+	}
+
+	p.images = images
+	p.changes, p.excluded, p.drafts, p.tooRecent = sel.filter.changes(images, sel.policy)
+	p.listed, p.protected = len(images), countProtected(images)
+	return &p, nil
+}
+
+// explicitCheckConcurrency is the number of explicit images looked up concurrently before a run.
+const explicitCheckConcurrency = 10
+
+// checkExplicit looks the images of the explicit changes up before they are applied, leaving
+// out the ones the account doesn't have, the drafts and the ones uploaded less than -min-age ago. The changes of the images found carry
+// their metadata, the ones already requiring signed URLs being left to be skipped when applied.
+// The images failing to be looked up otherwise are kept, to fail when applied.
+func checkExplicit(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI, f imageFilter, changes []change) (checked []change, drafts, tooRecent, unknown []string) {
+	type lookup struct {
+		img *cloudflareclient.Image
+		err error
+	}
+
+	lookups := make([]lookup, len(changes))
+	sem := make(chan struct{}, explicitCheckConcurrency)
+	var wg sync.WaitGroup
+	for i, c := range changes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			img, err := cli.GetImage(ctx, c.ImageID)
+			lookups[i] = lookup{img, err}
+		}()
+	}
+	wg.Wait()
+
+	for i, c := range changes {
+		img, err := lookups[i].img, lookups[i].err
+		switch code, _ := statusCode(err); {
+		case code == http.StatusNotFound:
+			unknown = append(unknown, c.ImageID)
+		case err != nil:
+			slog.Warn("could not look the image up before securing it", "image_id", c.ImageID, "error", err)
+			checked = append(checked, c)
+		case img.Draft:
+			drafts = append(drafts, c.ImageID)
+		case f.recent(*img):
+			tooRecent = append(tooRecent, c.ImageID)
+		default:
+			if !img.RequireSignedURLs {
+				c.meta = knownMeta(*img)
+			}
+			checked = append(checked, c)
+		}
+	}
+	return checked, drafts, tooRecent, unknown
+}
+
+// countProtected returns the number of images already requiring signed URLs.
+func countProtected(images []cloudflareclient.Image) int {
+	n := 0
+	for _, img := range images {
+		if img.RequireSignedURLs {
+			n++
+		}
+	}
+	return n
+}
+
+// changeStream lists the images page by page as its changes are consumed, so that
+// only a page of images is held in memory at a time and the changes of a page are
+// applied before the next one is fetched.
+type changeStream struct {
+	cli      cloudflareclient.CloudflareImagesAPI
+	sel      *selection
+	status   *runStatus
+	progress *progress
+
+	// scanned is the number of images listed so far, and protected the number of
+	// those already requiring signed URLs.
+	scanned   int
+	protected int
+	// excluded are the images left as they are because they are intentionally public.
+	excluded []string
+	// drafts are the images left as they are because they are still being uploaded.
+	drafts []string
+	// tooRecent are the images left as they are because they were uploaded less than -min-age ago.
+	tooRecent []string
+	// err is the error which ended the listing, if any.
+	err error
+}
+
+// changes yields the changes needed for the selected images to reach the desired state.
+func (cs *changeStream) changes(ctx context.Context) iter.Seq[change] {
+	return func(yield func(change) bool) {
+		for img, err := range cs.cli.Images(ctx) {
+			if err != nil {
+				cs.err = err
+				return
+			}
+			cs.scanned++
+			if img.RequireSignedURLs {
+				cs.protected++
+			}
+
+			changes, excluded, drafts, tooRecent := cs.sel.filter.changes([]cloudflareclient.Image{img}, cs.sel.policy)
+			logExcluded(excluded)
+			logDrafts(drafts)
+			logTooRecent(tooRecent)
+			cs.excluded = append(cs.excluded, excluded...)
+			cs.drafts = append(cs.drafts, drafts...)
+			cs.tooRecent = append(cs.tooRecent, tooRecent...)
+
+			for _, c := range changes {
+				cs.status.planned(1)
+				cs.progress.planned(1)
+				if !yield(c) {
+					return
+				}
+			}
+		}
+		cs.progress.listingDone()
+	}
+}
+
+// applyResult is the outcome of applying a set of changes.
+type applyResult struct {
+	applied []change
+	failed  []change
+	// failures are the numbers of failed changes by class of error.
+	failures map[string]int
+	// alreadySecured are the changes not needed, the image requiring signed URLs already.
+	alreadySecured []change
+	// skipped are the changes not attempted because the run was interrupted,
+	// or held back after the canary or past -max-changes.
+	skipped []change
+}
+
+// applier applies changes to images.
+type applier struct {
+	cli         cloudflareclient.CloudflareImagesAPI
+	concurrency int
+	// quiet logs the changes applied at the debug level, when the progress is shown otherwise.
+	quiet bool
+	// tagSecured adds secured_by and secured_at to the metadata of the images secured,
+	// when their metadata is known.
+	tagSecured bool
+	// limiter adapts the number of changes in flight below concurrency, if set.
+	limiter *adaptiveLimiter
+	// maxChanges is the number of changes dispatched at most over the lifetime of the
+	// applier, the others being held back, negative for no limit.
+	maxChanges int
+	dispatched int
+	// imageTimeout bounds the update of each image, retries included, if not zero.
+	imageTimeout time.Duration
+	// quarantine adds quarantined_at to the metadata of the images secured, when their
+	// metadata is known, keeping the time of their first quarantine.
+	quarantine bool
+	// observers are notified of the outcome of every change attempted,
+	// one at a time so they don't need to synchronize.
+	observers []func(c change, err error)
+	// errReporter reports the panics of the workers, for the account.
+	errReporter *errorReporter
+	accountID   string
+}
+
+// countFailure counts the failure of a change by the class of its error.
+func (r *applyResult) countFailure(err error) {
+	if r.failures == nil {
+		r.failures = make(map[string]int)
+	}
+	r.failures[classifyFailure(err)]++
+}
+
+func newApplier(cli cloudflareclient.CloudflareImagesAPI, concurrency int) *applier {
+	return &applier{cli: cli, concurrency: concurrency, maxChanges: -1}
+}
+
+// limitReached tells whether the applier dispatched -max-changes changes, holding back the others.
+func (a *applier) limitReached() bool {
+	return a.maxChanges >= 0 && a.dispatched >= a.maxChanges
+}
+
+// observe adds a function notified of the outcome of every change attempted.
+func (a *applier) observe(fn func(c change, err error)) {
+	a.observers = append(a.observers, fn)
+}
+
+// update applies the change, tagging the image when securing it if asked to.
+// The metadata is replaced as a whole, so it is only tagged when its current value is known.
+// Images secured without being listed are checked first, to skip the ones secured already.
+func (a *applier) update(ctx context.Context, c change) error {
+	if c.delete {
+		return a.cli.DeleteImage(ctx, c.ImageID)
+	}
+
+	if c.RequireSignedURLs && c.meta == nil {
+		return a.cli.SecureImage(ctx, c.ImageID)
+	}
+
+	if (!a.tagSecured && !a.quarantine) || !c.RequireSignedURLs {
+		return a.cli.SetRequireSignedURLs(ctx, c.ImageID, c.RequireSignedURLs)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	meta := maps.Clone(c.meta)
+	if a.tagSecured {
+		meta["secured_by"] = "securecloudflareimg"
+		meta["secured_at"] = now
+	}
+	if _, ok := meta[quarantinedAtKey]; a.quarantine && !ok {
+		meta[quarantinedAtKey] = now
+	}
+	return a.cli.UpdateImage(ctx, c.ImageID, cloudflareclient.ImageUpdate{RequireSignedURLs: true, Metadata: meta})
+}
+
+// updateWithin applies the change within -image-timeout, if set, for an image whose requests
+// keep failing not to hold a worker for the whole run.
+func (a *applier) updateWithin(ctx context.Context, c change) error {
+	if a.imageTimeout <= 0 {
+		return a.update(ctx, c)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, a.imageTimeout, errImageTimeout)
+	defer cancel()
+
+	err := a.update(ctx, c)
+	if err != nil && errors.Is(context.Cause(ctx), errImageTimeout) {
+		return fmt.Errorf("%w after %s: %w", errImageTimeout, a.imageTimeout, err)
+	}
+	return err
+}
+
+// apply updates the images with the configured number of concurrent workers,
+// logging the outcome of each change. Once the context is done no new change
+// is started, but the ones in flight are waited for.
+func (a *applier) apply(ctx context.Context, changes []change) *applyResult {
+	res, consumed := a.applySeq(ctx, slices.Values(changes))
+	res.skipped = append(res.skipped, changes[consumed:]...)
+	return res
+}
+
+// applyStream applies the changes as they are yielded, like apply. The changes
+// not yielded yet when the context is done aren't known, nor reported as skipped.
+func (a *applier) applyStream(ctx context.Context, changes iter.Seq[change]) *applyResult {
+	res, _ := a.applySeq(ctx, changes)
+	return res
+}
+
+// applySeq applies the changes, returning how many were consumed, either dispatched
+// to the workers or held back past maxChanges.
+func (a *applier) applySeq(ctx context.Context, changes iter.Seq[change]) (*applyResult, int) {
+	var (
+		res applyResult
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+	)
+
+	queue := make(chan change)
+
+	for i := 0; i < a.concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer a.errReporter.recoverPanic(a.accountID)
+			for c := range queue {
+				// Requests in flight are not cancelled, so an interruption
+				// doesn't leave us wondering whether they went through.
+				a.limiter.acquire()
+				start := time.Now()
+				err := a.updateWithin(context.WithoutCancel(ctx), c)
+				duration := time.Since(start)
+				a.limiter.release()
+
+				if errors.Is(err, cloudflareclient.ErrAlreadySecured) {
+					c.alreadySecured, err = true, nil
+				}
+
+				mu.Lock()
+				switch {
+				case err != nil:
+					res.failed = append(res.failed, c)
+					res.countFailure(err)
+				case c.alreadySecured:
+					res.alreadySecured = append(res.alreadySecured, c)
+				default:
+					res.applied = append(res.applied, c)
+				}
+
+				for _, fn := range a.observers {
+					fn(c, err)
+				}
+				mu.Unlock()
+
+				attrs := []any{"image_id", c.ImageID, "require_signed_urls", c.RequireSignedURLs, "duration", duration}
+				action := "update"
+				if c.delete {
+					attrs = []any{"image_id", c.ImageID, "duration", duration}
+					action = "delete"
+				}
+
+				if err != nil {
+					attrs = append(attrs, "attempt", cloudflareclient.Attempts(err))
+					if code, ok := statusCode(err); ok {
+						attrs = append(attrs, "status_code", code)
+					}
+					slog.Error("failed to "+action+" image", append(attrs, "error", err)...)
+					continue
+				}
+				level := slog.LevelInfo
+				if a.quiet {
+					level = slog.LevelDebug
+				}
+
+				if c.alreadySecured {
+					slog.Log(ctx, level, "skipping image: already secured", attrs...)
+					continue
+				}
+				slog.Log(ctx, level, "successfully "+c.verb()+" image", append(attrs, "status_code", http.StatusOK)...)
+			}
+		}()
+	}
+
+	var consumed int
+
+dispatch:
+	for c := range changes {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// The changes past the limit are still consumed, to report them as skipped.
+		if a.limitReached() {
+			res.skipped = append(res.skipped, c)
+			consumed++
+			continue
+		}
+
+		select {
+		case queue <- c:
+			consumed++
+			a.dispatched++
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if len(res.skipped) > 0 {
+		slog.Warn("changes held back, reaching -max-changes", "max_changes", a.maxChanges, "held_back", len(res.skipped))
+	}
+	return &res, consumed
+}
+
+func logExcluded(excluded []string) {
+	for _, id := range excluded {
+		slog.Info("skipping image: intentionally public", "image_id", id)
+	}
+}
+
+func logUnknown(unknown []string) {
+	for _, id := range unknown {
+		slog.Warn("skipping image: not found in the account", "image_id", id)
+	}
+}
+
+func logDrafts(drafts []string) {
+	for _, id := range drafts {
+		slog.Info("skipping image: draft, upload not completed", "image_id", id)
+	}
+}
+
+func logTooRecent(tooRecent []string) {
+	for _, id := range tooRecent {
+		slog.Info("skipping image: uploaded less than -min-age ago", "image_id", id)
+	}
+}
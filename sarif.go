@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// sarifRuleID is the id of the rule the unprotected images are reported under.
+const sarifRuleID = "unprotected-image"
+
+// sarifLog is the subset of SARIF 2.1.0 needed to report the unprotected images,
+// for GitHub code scanning and security dashboards to ingest them as alerts.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+	// Properties are the numbers of images left as they are, which aren't results.
+	Properties map[string]int `json:"properties"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+	Help             sarifMessage `json:"help"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// writeSARIF writes the unprotected images of the report as SARIF results, one per image.
+func writeSARIF(w io.Writer, r *securityReport) error {
+	results := make([]sarifResult, 0, len(r.Offending))
+	for _, img := range r.Offending {
+		text := fmt.Sprintf("image %s is publicly accessible without a signed URL", img.ID)
+		if img.Filename != "" {
+			text = fmt.Sprintf("image %s (%s) is publicly accessible without a signed URL", img.ID, img.Filename)
+		}
+		if img.Owner != "" {
+			text += ", uploaded by " + img.Owner
+		}
+		if img.UploadedBy != "" {
+			text += ", uploaded by " + img.UploadedBy + " according to the audit log"
+		}
+		if img.Metadata != "" {
+			text += ", metadata: " + img.Metadata
+		}
+
+		// There is no file to point at, the image is located within the account.
+		name := "cloudflare-images/" + r.AccountID + "/" + img.ID
+		results = append(results, sarifResult{
+			RuleID:  sarifRuleID,
+			Level:   "error",
+			Message: sarifMessage{Text: text},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: name}},
+				LogicalLocations: []sarifLogicalLocation{{
+					Name:               img.ID,
+					FullyQualifiedName: strings.ReplaceAll(name, "/", "."),
+					Kind:               "resource",
+				}},
+			}},
+			PartialFingerprints: map[string]string{"imageId/v1": r.AccountID + "/" + img.ID},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "securecloudflareimg",
+				InformationURI: "https://github.com/alesr/securecloudflareimage",
+				Rules: []sarifRule{{
+					ID:               sarifRuleID,
+					ShortDescription: sarifMessage{Text: "Image accessible without a signed URL"},
+					FullDescription:  sarifMessage{Text: "The image doesn't require signed URLs, anyone knowing its delivery URL can access it."},
+					Help:             sarifMessage{Text: "Secure the image with the secure command, or add it to the intentionally public images with -exclude-ids or -exclude-file."},
+				}},
+			}},
+			Results: results,
+			Properties: map[string]int{
+				"excluded":  r.Excluded,
+				"drafts":    r.Drafts,
+				"tooRecent": r.TooRecent,
+			},
+		}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
@@ -0,0 +1,201 @@
+// Package schedule parses standard 5-field cron expressions
+// (minute hour day-of-month month day-of-week) and computes their next activation.
+//
+// Each field accepts '*', single values, ranges ("1-5"), lists ("1,15")
+// and steps ("*/6", "0-30/10"). Day-of-week goes from 0 (Sunday) to 6, 7 is also Sunday.
+// As in cron, when both day-of-month and day-of-week are restricted, a day matching either runs.
+//
+// The activations are in wall clock time: across a daylight saving time transition, the times
+// skipped when the clocks go forward don't activate, and the ones repeated when they go back
+// activate only the first time.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar are set when the day fields are unrestricted.
+	domStar, dowStar bool
+}
+
+type bounds struct {
+	name     string
+	min, max int
+}
+
+var fields = []bounds{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses a 5-field cron expression.
+func Parse(expr string) (*Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("expected %d fields in cron expression '%s', got %d", len(fields), expr, len(parts))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in cron expression '%s': %s", fields[i].name, expr, err)
+		}
+		bits[i] = b
+	}
+
+	// Sunday can be written 0 or 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			s, err := strconv.Atoi(stepStr)
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step '%s'", stepStr)
+			}
+			step = s
+		}
+
+		lo, hi := b.min, b.max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+
+			var err error
+			if lo, err = parseValue(loStr, b); err != nil {
+				return 0, err
+			}
+
+			hi = lo
+			if isRange {
+				if hi, err = parseValue(hiStr, b); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// "5/10" means from 5 to the maximum every 10.
+				hi = b.max
+			}
+
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range '%s'", rng)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bounds) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s'", s)
+	}
+
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, b.min, b.max)
+	}
+	return v, nil
+}
+
+// ErrNeverActivates is returned by Next for the schedules that never activate (e.g. "0 0 30 2 *").
+var ErrNeverActivates = errors.New("schedule never activates")
+
+// Next returns the first activation time strictly after t, in the location of t.
+// It returns ErrNeverActivates if there is none within the search limit of five years.
+func (s *Schedule) Next(t time.Time) (time.Time, error) {
+	after := wallClock(t)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// Five years are enough to find any activation, including the leap day ones.
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = skipTo(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = skipTo(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()))
+			continue
+		}
+
+		if !has(s.hour, t.Hour()) {
+			t = skipTo(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location()))
+			continue
+		}
+
+		// The minutes repeated when the clocks go back activated already.
+		if !has(s.minute, t.Minute()) || !wallClock(t).After(after) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t, nil
+	}
+	return time.Time{}, ErrNeverActivates
+}
+
+// skipTo returns next, the start of the month, day or hour after t, unless the clocks skip it:
+// time.Date then normalizes it before t, and the minute after t is returned instead for the
+// search to carry on minute by minute past the transition.
+func skipTo(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Minute)
+}
+
+// wallClock returns the minute t shows on the clock of its location, as a UTC time to compare them.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := has(s.dom, t.Day())
+	dowMatch := has(s.dow, int(t.Weekday()))
+
+	switch {
+	case s.domStar && s.dowStar:
+		return true
+	case s.domStar:
+		return dowMatch
+	case s.dowStar:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
@@ -0,0 +1,208 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"empty expression", ""},
+		{"too few fields", "0 * * *"},
+		{"too many fields", "0 * * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "0 24 * * *"},
+		{"day of month zero", "0 0 0 * *"},
+		{"day of month out of range", "0 0 32 * *"},
+		{"month zero", "0 0 1 0 *"},
+		{"month out of range", "0 0 1 13 *"},
+		{"day of week out of range", "0 0 * * 8"},
+		{"negative value", "-1 * * * *"},
+		{"not a number", "a * * * *"},
+		{"zero step", "*/0 * * * *"},
+		{"negative step", "*/-2 * * * *"},
+		{"empty step", "*/ * * * *"},
+		{"step not a number", "*/x * * * *"},
+		{"reversed range", "30-10 * * * *"},
+		{"range out of range", "0 20-25 * * *"},
+		{"open range", "10- * * * *"},
+		{"empty list item", "1,,2 * * * *"},
+		{"trailing comma", "1, * * * *"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.expr); err == nil {
+				t.Errorf("Parse(%q) succeeded, want an error", tt.expr)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want string
+	}{
+		{"every minute", "* * * * *", "2026-03-10 10:15", "2026-03-10 10:16"},
+		{"strictly after", "15 10 * * *", "2026-03-10 10:15", "2026-03-11 10:15"},
+		{"step of hours", "0 */6 * * *", "2026-03-10 07:00", "2026-03-10 12:00"},
+		{"step of hours across midnight", "0 */6 * * *", "2026-03-10 18:00", "2026-03-11 00:00"},
+		{"step from a value", "5/20 * * * *", "2026-03-10 10:26", "2026-03-10 10:45"},
+		{"stepped range", "0-30/10 * * * *", "2026-03-10 10:31", "2026-03-10 11:00"},
+		{"range", "0 9-17 * * *", "2026-03-10 17:30", "2026-03-11 09:00"},
+		{"list", "0 0 1,15 * *", "2026-03-02 00:00", "2026-03-15 00:00"},
+		{"month", "0 0 1 6 *", "2026-07-01 00:00", "2027-06-01 00:00"},
+		{"day of week", "0 8 * * 1", "2026-03-10 08:00", "2026-03-16 08:00"},
+		{"day of week range", "0 8 * * 1-5", "2026-03-13 09:00", "2026-03-16 08:00"},
+		{"sunday as 0", "0 0 * * 0", "2026-03-10 00:00", "2026-03-15 00:00"},
+		{"sunday as 7", "0 0 * * 7", "2026-03-10 00:00", "2026-03-15 00:00"},
+		{"range up to 7", "0 0 * * 6-7", "2026-03-09 00:00", "2026-03-14 00:00"},
+		// April 1st is a Wednesday, before the first Sunday of April, the 5th.
+		{"day of month or day of week, month first", "0 0 1 * 0", "2026-03-31 12:00", "2026-04-01 00:00"},
+		{"day of month or day of week, week first", "0 0 15 * 0", "2026-03-02 00:00", "2026-03-08 00:00"},
+		{"day of month and unrestricted day of week", "0 0 15 * *", "2026-03-02 00:00", "2026-03-15 00:00"},
+		{"leap day", "0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %s", tt.expr, err)
+			}
+
+			got, err := s.Next(at(tt.from))
+			if err != nil {
+				t.Fatalf("Next: %s", err)
+			}
+
+			if want := at(tt.want); !got.Equal(want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got.Format(time.DateTime), want.Format(time.DateTime))
+			}
+		})
+	}
+}
+
+func TestNextNeverActivates(t *testing.T) {
+	for _, expr := range []string{"0 0 31 2 *", "0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		s, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %s", expr, err)
+		}
+
+		next, err := s.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+		if !errors.Is(err, ErrNeverActivates) {
+			t.Errorf("Next of %q = %s, %v, want ErrNeverActivates", expr, next, err)
+		}
+	}
+}
+
+func TestNextDaylightSavingTime(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %s", err)
+	}
+
+	// The clocks go forward from 2:00 to 3:00 on 2026-03-08, and back from 2:00 to 1:00 on 2026-11-01.
+	type test struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}
+
+	tests := []test{
+		{
+			name: "time skipped going forward",
+			expr: "30 2 * * *",
+			from: time.Date(2026, 3, 8, 0, 0, 0, 0, loc),
+			want: time.Date(2026, 3, 9, 2, 30, 0, 0, loc),
+		},
+		{
+			name: "hourly going forward",
+			expr: "0 * * * *",
+			from: time.Date(2026, 3, 8, 1, 30, 0, 0, loc),
+			want: time.Date(2026, 3, 8, 3, 0, 0, 0, loc),
+		},
+		{
+			name: "time after the jump going forward",
+			expr: "0 3 * * *",
+			from: time.Date(2026, 3, 8, 0, 0, 0, 0, loc),
+			want: time.Date(2026, 3, 8, 3, 0, 0, 0, loc),
+		},
+		{
+			name: "time repeated going back, first time",
+			expr: "30 1 * * *",
+			from: time.Date(2026, 11, 1, 0, 0, 0, 0, loc),
+			want: time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "time repeated going back, second time",
+			expr: "30 1 * * *",
+			from: time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC).In(loc),
+			want: time.Date(2026, 11, 2, 1, 30, 0, 0, loc),
+		},
+		{
+			name: "hourly going back",
+			expr: "0 * * * *",
+			from: time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC).In(loc),
+			want: time.Date(2026, 11, 1, 2, 0, 0, 0, loc),
+		},
+	}
+
+	// In Santiago, the clocks go forward from midnight to 1:00 on 2026-09-06, skipping the start of the day.
+	if santiago, err := time.LoadLocation("America/Santiago"); err == nil {
+		tests = append(tests, []test{
+			{
+				name: "day starting after midnight",
+				expr: "0 3 * * *",
+				from: time.Date(2026, 9, 5, 12, 0, 0, 0, santiago),
+				want: time.Date(2026, 9, 6, 3, 0, 0, 0, santiago),
+			},
+			{
+				name: "midnight skipped going forward",
+				expr: "0 0 * * *",
+				from: time.Date(2026, 9, 5, 12, 0, 0, 0, santiago),
+				want: time.Date(2026, 9, 7, 0, 0, 0, 0, santiago),
+			},
+		}...)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %s", tt.expr, err)
+			}
+
+			got, err := s.Next(tt.from)
+			if err != nil {
+				t.Fatalf("Next: %s", err)
+			}
+
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want.In(tt.from.Location()))
+			}
+
+			if got.Location() != tt.from.Location() {
+				t.Errorf("Next(%s) is in %s, want %s", tt.from, got.Location(), tt.from.Location())
+			}
+		})
+	}
+}
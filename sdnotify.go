@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends the state to systemd, for the services of Type=notify, doing nothing
+// when not run by systemd.
+// https://www.freedesktop.org/software/systemd/man/latest/sd_notify.html
+func sdNotify(state string) {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return
+	}
+
+	// Abstract sockets are given with a leading @.
+	if name[0] == '@' {
+		name = "\x00" + name[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		slog.Warn("failed to notify systemd", "state", state, "error", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		slog.Warn("failed to notify systemd", "state", state, "error", err)
+	}
+}
+
+// watchdogInterval returns the interval systemd expects to be pinged at, from WatchdogSec=,
+// zero when the watchdog isn't enabled for this process.
+func watchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// watchdog pings the systemd watchdog at half its interval, until the context is done,
+// as long as the daemon isn't hung: while a pass running makes no progress for longer
+// than the interval the pings stop, for systemd to restart the service.
+func (s *securer) watchdog(ctx context.Context, interval time.Duration) {
+	defer s.opts.errReporter.recoverPanic(s.opts.accountID)
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	var stalled bool
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if d := s.status.stalledFor(); d >= interval {
+			if !stalled {
+				slog.Error("the pass is making no progress, stopping the watchdog pings", "stalled_for", d.Round(time.Second))
+			}
+			stalled = true
+			continue
+		}
+
+		stalled = false
+		sdNotify("WATCHDOG=1")
+	}
+}
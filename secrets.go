@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"golang.org/x/oauth2/google"
+)
+
+var gcpSecretManagerURL = "https://secretmanager.googleapis.com/v1"
+
+// secretBackend fetches a secret by name from a secret manager. The key, if not
+// empty, selects a field of a secret holding a JSON object.
+type secretBackend interface {
+	fetch(ctx context.Context, name, key string) (string, error)
+}
+
+func newSecretBackend(scheme string) (secretBackend, error) {
+	switch scheme {
+	case "vault":
+		return newVault()
+	case "aws-sm":
+		return awsSecretsManager{}, nil
+	case "gcp-sm":
+		return gcpSecretManager{}, nil
+	default:
+		return nil, fmt.Errorf("invalid secret backend '%s', expected vault, aws-sm or gcp-sm", scheme)
+	}
+}
+
+// readSecret reads a secret given as <backend>://<name>[#<key>]:
+//
+//	vault://secret/cloudflare#token             key of a vault kv v2 secret, at <mount>/<path>
+//	aws-sm://prod/cloudflare                    aws secrets manager secret, by name or arn
+//	gcp-sm://my-project/cloudflare-token#token  latest version of a gcp secret manager secret
+func readSecret(ctx context.Context, ref string) (string, error) {
+	scheme, rest, ok := strings.Cut(ref, "://")
+	if !ok || rest == "" {
+		return "", fmt.Errorf("invalid secret '%s', expected <backend>://<name>[#<key>]", ref)
+	}
+
+	name, key, _ := strings.Cut(rest, "#")
+
+	backend, err := newSecretBackend(scheme)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := backend.fetch(ctx, name, key)
+	if err != nil {
+		return "", fmt.Errorf("could not read secret '%s': %s", ref, err)
+	}
+
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("secret '%s' is empty", ref)
+	}
+	return secret, nil
+}
+
+// readSecretFile reads a secret from a file, such as a mounted kubernetes or docker secret.
+func readSecretFile(name string) (string, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	secret := strings.TrimSpace(string(b))
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty", name)
+	}
+	return secret, nil
+}
+
+// jsonField returns the field of a secret holding a JSON object, or the secret if key is empty.
+func jsonField(secret, key string) (string, error) {
+	if key == "" {
+		return secret, nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object to read '%s' from: %s", key, err)
+	}
+	return stringField(fields, key)
+}
+
+func stringField(fields map[string]any, key string) (string, error) {
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no '%s' key", key)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("secret '%s' key is not a string", key)
+	}
+	return s, nil
+}
+
+// vault reads secrets from the kv v2 secrets engine of HashiCorp Vault, configured
+// by the standard VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE environment variables.
+type vault struct {
+	addr      string
+	token     string
+	namespace string
+}
+
+func newVault() (*vault, error) {
+	v := vault{
+		addr:      strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+	}
+
+	if v.addr == "" || v.token == "" {
+		return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are required to read secrets from vault")
+	}
+	return &v, nil
+}
+
+func (v *vault) fetch(ctx context.Context, name, key string) (string, error) {
+	mount, path, ok := strings.Cut(name, "/")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("expected vault://<mount>/<path>#<key>")
+	}
+
+	reqURL := fmt.Sprintf("%s/v1/%s/data/%s", v.addr, url.PathEscape(mount), path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not prepare request: %s", err)
+	}
+
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := getJSON(req, &secret); err != nil {
+		return "", err
+	}
+	return stringField(secret.Data.Data, key)
+}
+
+// awsSecretsManager reads secrets from AWS Secrets Manager, with the credentials
+// and region of the default AWS configuration chain (environment, shared config, instance role).
+type awsSecretsManager struct{}
+
+func (awsSecretsManager) fetch(ctx context.Context, name, key string) (string, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not load aws configuration: %s", err)
+	}
+
+	out, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if out.SecretString == nil {
+		return "", fmt.Errorf("secret is binary, expected a string")
+	}
+	return jsonField(*out.SecretString, key)
+}
+
+// gcpSecretManager reads the latest version of secrets from GCP Secret Manager,
+// with the application default credentials.
+type gcpSecretManager struct{}
+
+func (gcpSecretManager) fetch(ctx context.Context, name, key string) (string, error) {
+	project, secretName, ok := strings.Cut(name, "/")
+	if !ok || secretName == "" {
+		return "", fmt.Errorf("expected gcp-sm://<project>/<secret>[#<key>]")
+	}
+
+	httpCli, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return "", fmt.Errorf("could not find gcp credentials: %s", err)
+	}
+
+	reqURL := fmt.Sprintf("%s/projects/%s/secrets/%s/versions/latest:access", gcpSecretManagerURL, url.PathEscape(project), url.PathEscape(secretName))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not prepare request: %s", err)
+	}
+
+	var version struct {
+		Payload struct {
+			// Data is base64 encoded, which encoding/json decodes into a byte slice.
+			Data []byte `json:"data"`
+		} `json:"payload"`
+	}
+	if err := getJSONWith(httpCli, req, &version); err != nil {
+		return "", err
+	}
+	return jsonField(string(version.Payload.Data), key)
+}
+
+func getJSON(req *http.Request, v any) error {
+	return getJSONWith(notifyHTTPClient, req, v)
+}
+
+// getJSONWith sends the request and decodes the JSON response into v.
+func getJSONWith(httpCli *http.Client, req *http.Request, v any) error {
+	resp, err := httpCli.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not send request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code: %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("could not decode response: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,669 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/schedule"
+)
+
+// secureCmd brings the selected images to the desired state in one go,
+// or over and over again when watching, running on a schedule or serving.
+func secureCmd(ctx context.Context, args []string) (err error) {
+	fs := flag.NewFlagSet("secure", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	opts.registerSelectionFlags(fs)
+	opts.registerApplyFlags(fs)
+	opts.registerReportFlags(fs)
+	opts.registerGateFlags(fs)
+	opts.quarantine.registerFlags(fs)
+	opts.canary.registerFlags(fs)
+	if ingestingLogs(ctx) {
+		opts.accessLogs = &accessLogsConfig{}
+		opts.accessLogs.registerFlags(fs)
+	}
+	if migrating(ctx) {
+		opts.migration = &migrationConfig{}
+		opts.migration.registerFlags(fs)
+	}
+	// Migrations and access logs make a single pass, over what they planned themselves.
+	var (
+		daemonFlags daemonConfig
+		resume      bool
+	)
+	if !migrating(ctx) && !ingestingLogs(ctx) {
+		daemonFlags.registerFlags(fs)
+	}
+	if !migrating(ctx) {
+		fs.BoolVar(&resume, "resume", false, "resume the interrupted run recorded in the -checkpoint file instead of listing the images")
+	}
+	inventoryOutPtr := fs.String("inventory-out", "", "file to write the inventory of the account to at the end of the run, for the drift command")
+	checkpointPtr := fs.String("checkpoint", "", "file to record the progress of the run to, so it can be resumed")
+	interactivePtr := fs.Bool("interactive", false, "show the images about to be changed and ask for confirmation, for all of them or one by one")
+	fs.StringVar(&opts.sentryDSN, "sentry-dsn", os.Getenv(sentryDSNEnv), "sentry dsn to report the failed runs and the panics to, defaults to the "+sentryDSNEnv+" environment variable")
+	fs.StringVar(&opts.sentryEnvironment, "sentry-environment", "", "environment the errors reported to sentry are tagged with (e.g. production)")
+	oncePtr := fs.Bool("once", false, "make a single pass and exit, writing its outcome to -termination-log, for kubernetes jobs")
+	terminationLogPtr := fs.String("termination-log", defaultTerminationLog, "file to write the json outcome of the pass to with -once, if it exists, for the status of the kubernetes job")
+	opts.validating = validatingConfig(ctx)
+	if err := opts.parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	// Terraform always writes the query of the data source to stdin, an empty object at least.
+	// Its flags are set before the logging is started, for the logging flags to apply.
+	if opts.output == "terraform-external" && !isTerminal(os.Stdin) {
+		if err := readTerraformQuery(os.Stdin, fs); err != nil {
+			return err
+		}
+	}
+
+	if err := opts.startLogging(); err != nil {
+		return err
+	}
+
+	// s is set once ready to make the pass, the termination message reporting its summary.
+	var s *securer
+	if *oncePtr && !validatingConfig(ctx) {
+		defer func() {
+			var sum *runSummary
+			if s != nil {
+				sum = s.status.last
+			}
+
+			if err := writeTerminationMessage(*terminationLogPtr, sum, err); err != nil {
+				slog.Error("failed to write termination message", "file", *terminationLogPtr, "error", err)
+			}
+		}()
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	if err := opts.validateApply(); err != nil {
+		return err
+	}
+
+	if err := opts.validateReport(); err != nil {
+		return err
+	}
+
+	if err := opts.quarantine.validate(); err != nil {
+		return err
+	}
+
+	if opts.migration != nil {
+		if err := opts.migration.validate(); err != nil {
+			return err
+		}
+
+		// Migrations secure the images in canary batches, confirmed unless soaking.
+		if !opts.canary.enabled() {
+			opts.canary.percent = defaultMigrationCanary
+		}
+		if opts.canary.soak == 0 && !opts.canary.confirm {
+			opts.canary.confirm = true
+		}
+	}
+
+	if err := opts.canary.validate(); err != nil {
+		return err
+	}
+
+	if opts.accessLogs != nil {
+		if err := opts.accessLogs.validate(); err != nil {
+			return err
+		}
+	}
+
+	daemon := daemonFlags.enabled()
+
+	if *oncePtr && daemon {
+		return errors.New("-once cannot be used with -watch, -schedule, -serve, -grpc-addr or -upload-queue")
+	}
+
+	if opts.smtp.digest == "daily" && !daemon {
+		return errors.New("-email-digest daily requires -watch, -schedule, -serve or -grpc-addr")
+	}
+
+	if daemonFlags.watch > 0 && daemonFlags.schedule != "" {
+		return errors.New("-watch and -schedule cannot be used together")
+	}
+
+	if resume && *checkpointPtr == "" {
+		return errors.New("-resume requires -checkpoint")
+	}
+
+	if resume && daemon {
+		return errors.New("-resume cannot be used with -watch, -schedule, -serve or -grpc-addr")
+	}
+
+	if *interactivePtr && daemon {
+		return errors.New("-interactive cannot be used with -watch, -schedule, -serve or -grpc-addr")
+	}
+
+	if opts.canary.enabled() && daemon {
+		return errors.New("-canary cannot be used with -watch, -schedule, -serve or -grpc-addr")
+	}
+
+	if opts.canary.confirm && opts.readsStdin() {
+		return errors.New("-canary-confirm cannot be used with -ids-file - or -access-logs -, stdin answers the confirmation")
+	}
+
+	if *interactivePtr && opts.readsStdin() {
+		return errors.New("-interactive cannot be used with -ids-file - or -access-logs -, stdin answers the confirmation")
+	}
+
+	if opts.output == "terraform-external" && daemon {
+		return errors.New("-output terraform-external cannot be used with -watch, -schedule, -serve or -grpc-addr")
+	}
+
+	if opts.output == "terraform-external" && (*interactivePtr || opts.canary.confirm || opts.readsStdin()) {
+		return errors.New("-output terraform-external cannot be used with -interactive, -canary-confirm, -ids-file - or -access-logs -, stdin is the query of terraform")
+	}
+
+	if (opts.failIfUnprotected || opts.maxFailures >= 0) && daemon {
+		return errors.New("-fail-if-unprotected and -max-failures cannot be used with -watch, -schedule, -serve or -grpc-addr")
+	}
+
+	if daemonFlags.pprof && daemonFlags.serveAddr == "" && daemonFlags.metricsAddr == "" {
+		return errors.New("-pprof requires -serve or -metrics-addr")
+	}
+
+	if daemonFlags.uploadEvents && daemonFlags.serveAddr == "" {
+		return errors.New("-upload-events requires -serve")
+	}
+
+	var uploadSrc uploadSource
+	if daemonFlags.uploadQueue != "" {
+		src, err := newUploadSource(daemonFlags.uploadQueue)
+		if err != nil {
+			return err
+		}
+		uploadSrc = src
+	}
+
+	if daemonFlags.metricsAddr != "" && !daemon {
+		return errors.New("-metrics-addr requires -watch, -schedule, -serve or -grpc-addr")
+	}
+
+	var sched *schedule.Schedule
+	if daemonFlags.schedule != "" {
+		s, err := schedule.Parse(daemonFlags.schedule)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.Next(time.Now()); err != nil {
+			return fmt.Errorf("invalid -schedule '%s': %w", daemonFlags.schedule, err)
+		}
+		sched = s
+	}
+
+	sel, err := opts.loadSelection()
+	if err != nil {
+		return err
+	}
+
+	// The configuration is valid, config validate stops before opening anything or contacting cloudflare.
+	if validatingConfig(ctx) {
+		return nil
+	}
+
+	if err := opts.openSinks(ctx); err != nil {
+		return err
+	}
+	defer opts.errReporter.flush()
+
+	var m *metrics
+	if daemonFlags.metricsAddr != "" {
+		m = &metrics{}
+		opts.middlewares = append(opts.middlewares, m.transport)
+	}
+
+	// Daemons hold the lock for as long as they run.
+	stopProfiling, err := opts.startProfiling()
+	if err != nil {
+		return err
+	}
+	defer stopProfiling()
+
+	if err := opts.lockRun(); err != nil {
+		return err
+	}
+	defer opts.lock.release()
+
+	cli, err := opts.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	s = &securer{
+		cli:          cli,
+		metrics:      m,
+		status:       &runStatus{},
+		opts:         &opts,
+		sel:          sel,
+		inventoryOut: *inventoryOutPtr,
+		checkpoint:   *checkpointPtr,
+		resume:       resume,
+	}
+
+	if *interactivePtr {
+		s.confirmer = newConfirmer(os.Stdin, os.Stdout)
+	}
+
+	if daemon {
+		go s.warmUp(ctx)
+	}
+
+	// The notifications received over http and from the queue are secured alike.
+	var uploads *uploadSecurer
+	if daemonFlags.uploadEvents || uploadSrc != nil {
+		uploads = newUploadSecurer(s)
+		go uploads.run(ctx)
+	}
+
+	if uploadSrc != nil {
+		slog.Info("receiving upload notifications", "queue", daemonFlags.uploadQueue)
+		go consumeUploads(ctx, uploadSrc, uploads)
+	}
+
+	if daemonFlags.serveAddr != "" {
+		mux := http.NewServeMux()
+		s.registerRoutes(ctx, mux)
+		s.registerHealthRoutes(mux)
+		if daemonFlags.uploadEvents {
+			secret := os.Getenv(uploadsSecretEnv)
+			if secret == "" {
+				slog.Warn("upload notifications aren't authenticated, " + uploadsSecretEnv + " isn't set")
+			}
+			uploads.registerRoutes(mux, secret)
+		}
+		if daemonFlags.pprof {
+			registerPprofRoutes(mux)
+		}
+
+		// The metrics can be served along with the api.
+		if m != nil && daemonFlags.metricsAddr == daemonFlags.serveAddr {
+			mux.Handle("/metrics", m)
+			m = nil
+		}
+		go serve(ctx, daemonFlags.serveAddr, opts.errReporter.recoverHandler(opts.accountID, mux))
+	}
+
+	if daemonFlags.grpcAddr != "" {
+		go serveGRPC(ctx, daemonFlags.grpcAddr, s)
+	}
+
+	if m != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m)
+		s.registerHealthRoutes(mux)
+		if daemonFlags.pprof && daemonFlags.serveAddr == "" {
+			registerPprofRoutes(mux)
+		}
+		go serve(ctx, daemonFlags.metricsAddr, opts.errReporter.recoverHandler(opts.accountID, mux))
+	}
+
+	pass := func() error {
+		return s.pass(ctx)
+	}
+
+	if daemon {
+		sdNotify("READY=1")
+		defer sdNotify("STOPPING=1")
+
+		if interval := watchdogInterval(); interval > 0 {
+			go s.watchdog(ctx, interval)
+		}
+	}
+
+	switch {
+	case daemonFlags.watch > 0:
+		slog.Info("watching for images to secure", "interval", daemonFlags.watch)
+		for {
+			// A failed pass is retried on the next tick rather than stopping the watch.
+			if err := pass(); err != nil && !errors.Is(err, errInterrupted) {
+				slog.Error("pass failed", "error", err)
+			}
+
+			if !sleepUntil(ctx, time.Now().Add(daemonFlags.watch)) {
+				slog.Info("stopped watching")
+				return nil
+			}
+		}
+	case sched != nil:
+		for {
+			next, err := sched.Next(time.Now())
+			if err != nil {
+				return fmt.Errorf("schedule '%s' never runs: %w", daemonFlags.schedule, err)
+			}
+
+			slog.Info("waiting for next pass", "next", next.Format(time.RFC3339))
+			if !sleepUntil(ctx, next) {
+				slog.Info("stopped schedule")
+				return nil
+			}
+
+			if err := pass(); err != nil && !errors.Is(err, errInterrupted) {
+				slog.Error("pass failed", "error", err)
+			}
+		}
+	case daemonFlags.serveAddr != "" || daemonFlags.grpcAddr != "" || uploadSrc != nil:
+		<-ctx.Done()
+
+		// Let a run triggered over http or grpc wrap up and report.
+		s.wait()
+		slog.Info("stopped serving")
+		return nil
+	case opts.migration != nil:
+		if err := opts.migration.begin(ctx, cli, opts.accountID); err != nil {
+			return err
+		}
+
+		passErr := pass()
+		if err := opts.migration.finish(ctx, cli, opts.accountHash, s.status.last, passErr); err != nil {
+			slog.Error(err.Error())
+		}
+		if passErr != nil {
+			return passErr
+		}
+		return opts.gate(s.status.last)
+	default:
+		if err := pass(); err != nil {
+			return err
+		}
+		return opts.gate(s.status.last)
+	}
+}
+
+// sleepUntil waits until the given time, returning false if the context is done first.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// securer runs securing passes, one at a time.
+type securer struct {
+	cli          cloudflareclient.CloudflareImagesAPI
+	opts         *options
+	sel          *selection
+	inventoryOut string
+	checkpoint   string
+	resume       bool
+	metrics      *metrics
+	status       *runStatus
+	readiness    readiness
+	// confirmer asks to approve the changes before they are applied, if interactive.
+	confirmer *confirmer
+
+	// mu is held while a pass runs.
+	mu sync.Mutex
+}
+
+// pass runs a pass, waiting for the one running to finish first.
+func (s *securer) pass(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.runPass(ctx)
+}
+
+// tryPass starts a pass in the background unless one is running already, returning whether it started.
+func (s *securer) tryPass(ctx context.Context) bool {
+	if !s.mu.TryLock() {
+		return false
+	}
+
+	go func() {
+		defer s.mu.Unlock()
+
+		if err := s.runPass(ctx); err != nil && !errors.Is(err, errInterrupted) {
+			slog.Error("pass failed", "error", err)
+		}
+	}()
+	return true
+}
+
+// wait waits for the running pass, if any, to finish.
+func (s *securer) wait() {
+	s.mu.Lock()
+	s.mu.Unlock()
+}
+
+func (s *securer) runPass(ctx context.Context) error {
+	defer s.opts.errReporter.recoverPanic(s.opts.accountID)
+
+	s.status.begin()
+
+	ctx, cancel := s.opts.runContext(ctx)
+	defer cancel()
+
+	sum, err := s.run(ctx)
+	s.status.end(sum, err)
+
+	if err != nil && !errors.Is(err, errInterrupted) {
+		s.opts.errReporter.captureRun(err, s.opts.accountID, sum)
+	}
+
+	// Only the first pass can resume a previous run.
+	s.resume = false
+	return err
+}
+
+// run lists the images, applies the changes and reports what is left.
+func (s *securer) run(ctx context.Context) (*runSummary, error) {
+	start := time.Now()
+	usageStart := s.opts.usage.snapshot()
+
+	a := s.opts.newApplier(s.cli)
+	if s.metrics != nil {
+		a.observe(s.metrics.observeChange)
+	}
+	a.observe(s.status.observe)
+
+	prog := newProgress(s.opts.progress, s.opts.logFormat)
+	defer prog.end()
+	a.observe(prog.observe)
+	a.quiet = prog.shown()
+
+	var (
+		p       *planned
+		res     *applyResult
+		listErr error
+	)
+	if s.pipelined() {
+		// The changes are applied as the pages of images are listed.
+		cs := &changeStream{cli: s.cli, sel: s.sel, status: s.status, progress: prog}
+		res = a.applyStream(ctx, cs.changes(ctx))
+		s.metrics.scanned(cs.scanned)
+
+		p = &planned{excluded: cs.excluded, drafts: cs.drafts, tooRecent: cs.tooRecent, listed: cs.scanned, protected: cs.protected}
+		if cs.err != nil && ctx.Err() == nil {
+			listErr = fmt.Errorf("failed to list images: %s", cs.err)
+		}
+	} else {
+		var (
+			cp  *checkpoint
+			err error
+		)
+		p, cp, err = s.prepare(ctx)
+		if err != nil {
+			return nil, err
+		}
+		logExcluded(p.excluded)
+		logDrafts(p.drafts)
+		logTooRecent(p.tooRecent)
+		s.metrics.scanned(len(p.images))
+		s.status.planned(len(p.changes))
+		prog.planned(len(p.changes))
+		prog.listingDone()
+
+		if cp != nil {
+			a.observe(cp.record)
+		}
+
+		if s.opts.canary.enabled() {
+			res = s.applyCanary(ctx, a, p.changes)
+		} else {
+			res = a.apply(ctx, p.changes)
+		}
+
+		if cp != nil {
+			if len(res.failed) == 0 && len(res.skipped) == 0 {
+				if err := cp.remove(); err != nil {
+					slog.Error("failed to remove checkpoint", "file", s.checkpoint, "error", err)
+				}
+			} else {
+				cp.Close()
+				slog.Warn("run can be resumed with -checkpoint and -resume", "file", s.checkpoint)
+			}
+		}
+	}
+
+	sum := newRunSummary(s.opts.accountID, start, usageStart, p, res)
+	sum.Shard = s.sel.filter.shard.String()
+
+	if err := s.opts.writeFailed(res); err != nil {
+		return nil, err
+	}
+
+	// Changes were made before the listing failed, they are reported regardless.
+	if listErr != nil {
+		s.opts.report(ctx, sum)
+		return sum, listErr
+	}
+
+	if ctx.Err() != nil {
+		if s.pipelined() {
+			slog.Warn("the images not listed before the interruption are not counted as remaining")
+		}
+
+		sum.Interrupted = true
+		s.opts.report(ctx, sum)
+		return sum, interruption(ctx)
+	}
+
+	// Fetch gain to see if they are still images not in the desired state,
+	// holding on to the images only for the inventory.
+	var (
+		images    []cloudflareclient.Image
+		remaining []change
+		expired   []string
+	)
+	now := time.Now()
+	for img, err := range s.cli.Images(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list images: %s", err)
+		}
+
+		if s.opts.quarantine.expired(img, now) && s.sel.filter.match(img) && !s.sel.filter.excluded[img.ID] {
+			expired = append(expired, img.ID)
+		}
+
+		if s.inventoryOut != "" {
+			images = append(images, img)
+		}
+
+		changes, _, _, _ := s.sel.filter.changes([]cloudflareclient.Image{img}, s.sel.policy)
+		remaining = append(remaining, changes...)
+	}
+
+	s.readiness.listed.Store(true)
+
+	// The images are deleted only after a pass that went through every change, counting
+	// against what is left of -max-changes. Those left are deleted on the next run, they are secured already.
+	var deleted map[string]bool
+	switch {
+	case len(expired) == 0:
+	case len(res.failed) > 0 || len(res.skipped) > 0:
+		slog.Warn("not deleting the quarantined images, changes failed or were held back", "expired", len(expired), "failed", len(res.failed), "held_back", len(res.skipped))
+	default:
+		purger := s.opts.newApplier(s.cli)
+		purger.dispatched = a.dispatched
+		deleted = s.opts.quarantine.purge(ctx, purger, expired)
+		sum.Deleted = len(deleted)
+	}
+
+	sum.setRemaining(remaining)
+	s.metrics.setRemaining(remaining)
+
+	if s.inventoryOut != "" {
+		images = slices.DeleteFunc(images, func(img cloudflareclient.Image) bool { return deleted[img.ID] })
+		if err := writeInventory(s.inventoryOut, newInventory(s.opts.accountID, images)); err != nil {
+			return nil, err
+		}
+	}
+
+	sum.Duration = time.Since(start)
+	s.opts.report(ctx, sum)
+
+	slog.Info("done")
+	return sum, nil
+}
+
+// pipelined tells whether the changes of the pass can be applied as the images are listed,
+// rather than listing every image first. Checkpoints and confirmations need to know all the changes upfront.
+func (s *securer) pipelined() bool {
+	return !s.resume && s.checkpoint == "" && s.sel.filter.ids == nil && s.confirmer == nil && !s.opts.canary.enabled()
+}
+
+// prepare works out the changes of the pass, either from the checkpoint
+// being resumed or from the images of the account, starting a new checkpoint if needed.
+func (s *securer) prepare(ctx context.Context) (*planned, *checkpoint, error) {
+	if s.resume {
+		cp, header, pending, err := resumeCheckpoint(s.checkpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if header.AccountID != s.opts.accountID {
+			cp.Close()
+			return nil, nil, fmt.Errorf("checkpoint was made for account '%s', not '%s'", header.AccountID, s.opts.accountID)
+		}
+
+		slog.Info("resuming run", "started_at", header.StartedAt.Format(time.RFC3339), "pending", len(pending), "changes", len(header.Changes))
+		return &planned{changes: pending}, cp, nil
+	}
+
+	p, err := planChanges(ctx, s.cli, s.sel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if s.confirmer != nil {
+		if p.changes, err = s.confirmer.confirm(p); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if s.checkpoint == "" {
+		return p, nil, nil
+	}
+
+	cp, err := createCheckpoint(s.checkpoint, s.opts.accountID, p.changes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, cp, nil
+}
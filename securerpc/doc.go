@@ -0,0 +1,4 @@
+// Package securerpc is the gRPC API of the securing service, generated from securerpc.proto.
+package securerpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative securerpc.proto
@@ -0,0 +1,253 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: securerpc.proto
+
+package securerpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SecureService_SecureAll_FullMethodName       = "/securecloudflareimg.v1.SecureService/SecureAll"
+	SecureService_SecureImages_FullMethodName    = "/securecloudflareimg.v1.SecureService/SecureImages"
+	SecureService_ListUnprotected_FullMethodName = "/securecloudflareimg.v1.SecureService/ListUnprotected"
+	SecureService_GetRunStatus_FullMethodName    = "/securecloudflareimg.v1.SecureService/GetRunStatus"
+)
+
+// SecureServiceClient is the client API for SecureService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SecureService secures the images of a Cloudflare account, like the secure command.
+type SecureServiceClient interface {
+	// SecureAll starts a run over the images of the account in the background.
+	// It fails with FAILED_PRECONDITION if a run is in progress.
+	SecureAll(ctx context.Context, in *SecureAllRequest, opts ...grpc.CallOption) (*SecureAllResponse, error)
+	// SecureImages secures the given images and returns the outcome of each of them.
+	// Intentionally public images are excluded, drafts and images uploaded less than
+	// -min-age ago skipped. It fails with FAILED_PRECONDITION if a run is in progress.
+	SecureImages(ctx context.Context, in *SecureImagesRequest, opts ...grpc.CallOption) (*SecureImagesResponse, error)
+	// ListUnprotected lists the images not in the desired state.
+	ListUnprotected(ctx context.Context, in *ListUnprotectedRequest, opts ...grpc.CallOption) (*ListUnprotectedResponse, error)
+	// GetRunStatus reports the progress of the current or last run.
+	GetRunStatus(ctx context.Context, in *GetRunStatusRequest, opts ...grpc.CallOption) (*RunStatus, error)
+}
+
+type secureServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSecureServiceClient(cc grpc.ClientConnInterface) SecureServiceClient {
+	return &secureServiceClient{cc}
+}
+
+func (c *secureServiceClient) SecureAll(ctx context.Context, in *SecureAllRequest, opts ...grpc.CallOption) (*SecureAllResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SecureAllResponse)
+	err := c.cc.Invoke(ctx, SecureService_SecureAll_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *secureServiceClient) SecureImages(ctx context.Context, in *SecureImagesRequest, opts ...grpc.CallOption) (*SecureImagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SecureImagesResponse)
+	err := c.cc.Invoke(ctx, SecureService_SecureImages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *secureServiceClient) ListUnprotected(ctx context.Context, in *ListUnprotectedRequest, opts ...grpc.CallOption) (*ListUnprotectedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListUnprotectedResponse)
+	err := c.cc.Invoke(ctx, SecureService_ListUnprotected_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *secureServiceClient) GetRunStatus(ctx context.Context, in *GetRunStatusRequest, opts ...grpc.CallOption) (*RunStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RunStatus)
+	err := c.cc.Invoke(ctx, SecureService_GetRunStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SecureServiceServer is the server API for SecureService service.
+// All implementations must embed UnimplementedSecureServiceServer
+// for forward compatibility.
+//
+// SecureService secures the images of a Cloudflare account, like the secure command.
+type SecureServiceServer interface {
+	// SecureAll starts a run over the images of the account in the background.
+	// It fails with FAILED_PRECONDITION if a run is in progress.
+	SecureAll(context.Context, *SecureAllRequest) (*SecureAllResponse, error)
+	// SecureImages secures the given images and returns the outcome of each of them.
+	// Intentionally public images are excluded, drafts and images uploaded less than
+	// -min-age ago skipped. It fails with FAILED_PRECONDITION if a run is in progress.
+	SecureImages(context.Context, *SecureImagesRequest) (*SecureImagesResponse, error)
+	// ListUnprotected lists the images not in the desired state.
+	ListUnprotected(context.Context, *ListUnprotectedRequest) (*ListUnprotectedResponse, error)
+	// GetRunStatus reports the progress of the current or last run.
+	GetRunStatus(context.Context, *GetRunStatusRequest) (*RunStatus, error)
+	mustEmbedUnimplementedSecureServiceServer()
+}
+
+// UnimplementedSecureServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSecureServiceServer struct{}
+
+func (UnimplementedSecureServiceServer) SecureAll(context.Context, *SecureAllRequest) (*SecureAllResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SecureAll not implemented")
+}
+func (UnimplementedSecureServiceServer) SecureImages(context.Context, *SecureImagesRequest) (*SecureImagesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SecureImages not implemented")
+}
+func (UnimplementedSecureServiceServer) ListUnprotected(context.Context, *ListUnprotectedRequest) (*ListUnprotectedResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListUnprotected not implemented")
+}
+func (UnimplementedSecureServiceServer) GetRunStatus(context.Context, *GetRunStatusRequest) (*RunStatus, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetRunStatus not implemented")
+}
+func (UnimplementedSecureServiceServer) mustEmbedUnimplementedSecureServiceServer() {}
+func (UnimplementedSecureServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeSecureServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SecureServiceServer will
+// result in compilation errors.
+type UnsafeSecureServiceServer interface {
+	mustEmbedUnimplementedSecureServiceServer()
+}
+
+func RegisterSecureServiceServer(s grpc.ServiceRegistrar, srv SecureServiceServer) {
+	// If the following call panics, it indicates UnimplementedSecureServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SecureService_ServiceDesc, srv)
+}
+
+func _SecureService_SecureAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SecureAllRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SecureServiceServer).SecureAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SecureService_SecureAll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SecureServiceServer).SecureAll(ctx, req.(*SecureAllRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SecureService_SecureImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SecureImagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SecureServiceServer).SecureImages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SecureService_SecureImages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SecureServiceServer).SecureImages(ctx, req.(*SecureImagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SecureService_ListUnprotected_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListUnprotectedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SecureServiceServer).ListUnprotected(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SecureService_ListUnprotected_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SecureServiceServer).ListUnprotected(ctx, req.(*ListUnprotectedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SecureService_GetRunStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRunStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SecureServiceServer).GetRunStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SecureService_GetRunStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SecureServiceServer).GetRunStatus(ctx, req.(*GetRunStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SecureService_ServiceDesc is the grpc.ServiceDesc for SecureService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SecureService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "securecloudflareimg.v1.SecureService",
+	HandlerType: (*SecureServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SecureAll",
+			Handler:    _SecureService_SecureAll_Handler,
+		},
+		{
+			MethodName: "SecureImages",
+			Handler:    _SecureService_SecureImages_Handler,
+		},
+		{
+			MethodName: "ListUnprotected",
+			Handler:    _SecureService_ListUnprotected_Handler,
+		},
+		{
+			MethodName: "GetRunStatus",
+			Handler:    _SecureService_GetRunStatus_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "securerpc.proto",
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"google.golang.org/grpc"
+)
+
+// sentryDSNEnv is the environment variable the Sentry DSN is read from without -sentry-dsn.
+const sentryDSNEnv = "SENTRY_DSN"
+
+// errorReporter reports the failed runs and the panics to Sentry, with the context of the run,
+// for them not to only exist in the logs of the pods. Its methods are no-ops on a nil errorReporter.
+type errorReporter struct {
+	hub *sentry.Hub
+}
+
+func newErrorReporter(dsn, environment string) (*errorReporter, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:              dsn,
+		Release:          "securecloudflareimg@" + toolVersion(),
+		Environment:      environment,
+		AttachStacktrace: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid -sentry-dsn: %s", err)
+	}
+
+	hub := sentry.NewHub(client, sentry.NewScope())
+	if host, err := os.Hostname(); err == nil {
+		hub.Scope().SetTag("host", host)
+	}
+	return &errorReporter{hub: hub}, nil
+}
+
+// captureRun reports the error the run failed with, along with its summary if it got that far.
+func (r *errorReporter) captureRun(err error, accountID string, sum *runSummary) {
+	if r == nil {
+		return
+	}
+
+	r.hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("account_id", accountID)
+
+		if sum != nil {
+			if sum.Shard != "" {
+				scope.SetTag("shard", sum.Shard)
+			}
+
+			scope.SetContext("run", sentry.Context{
+				"started_at":            sum.StartedAt,
+				"duration":              sum.Duration.String(),
+				"interrupted":           sum.Interrupted,
+				"listed":                sum.Listed,
+				"secured":               sum.Secured,
+				"failed":                sum.Failed,
+				"skipped":               sum.Skipped,
+				"remaining_unprotected": sum.RemainingUnprotected,
+				"api_requests":          sum.APIRequests,
+				"api_rate_limited":      sum.APIRateLimited,
+			})
+		}
+		r.hub.CaptureException(err)
+	})
+}
+
+// recoverPanic reports the panic of the calling goroutine, if any, before panicking again.
+// It must be deferred.
+func (r *errorReporter) recoverPanic(accountID string) {
+	if r == nil {
+		return
+	}
+
+	if p := recover(); p != nil {
+		r.hub.WithScope(func(scope *sentry.Scope) {
+			scope.SetTag("account_id", accountID)
+			r.hub.Recover(p)
+		})
+		r.flush()
+		panic(p)
+	}
+}
+
+// recoverHandler reports the panics of the handler: they are recovered by the http server,
+// which only logs them.
+func (r *errorReporter) recoverHandler(accountID string, h http.Handler) http.Handler {
+	if r == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer r.recoverPanic(accountID)
+		h.ServeHTTP(w, req)
+	})
+}
+
+// unaryInterceptor reports the panics of the gRPC handlers, each running in its own goroutine.
+func (r *errorReporter) unaryInterceptor(accountID string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		defer r.recoverPanic(accountID)
+		return handler(ctx, req)
+	}
+}
+
+// flush waits for the events to be sent, for a little while.
+func (r *errorReporter) flush() {
+	if r == nil {
+		return
+	}
+	r.hub.Flush(5 * time.Second)
+}
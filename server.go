@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runStatus follows the progress of the passes for the http api.
+// Its methods are no-ops on a nil status.
+type runStatus struct {
+	mu        sync.Mutex
+	running   bool
+	startedAt time.Time
+	// progressAt is the last time the running pass made progress.
+	progressAt time.Time
+	total      int
+	processed  int
+	failed     int
+	last       *runSummary
+	lastErr    error
+}
+
+func (st *runStatus) begin() {
+	if st == nil {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.running = true
+	st.startedAt = time.Now().UTC()
+	st.progressAt = st.startedAt
+	st.total, st.processed, st.failed = 0, 0, 0
+}
+
+// planned adds n changes to the total of the pass, at once or as they are found.
+func (st *runStatus) planned(n int) {
+	if st == nil {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.total += n
+	st.progressAt = time.Now().UTC()
+}
+
+func (st *runStatus) observe(_ change, err error) {
+	if st == nil {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.processed++
+	if err != nil {
+		st.failed++
+	}
+	st.progressAt = time.Now().UTC()
+}
+
+// stalledFor returns how long the running pass has made no progress for, zero when none runs.
+func (st *runStatus) stalledFor() time.Duration {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if !st.running {
+		return 0
+	}
+	return time.Since(st.progressAt)
+}
+
+func (st *runStatus) end(sum *runSummary, err error) {
+	if st == nil {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.running = false
+	st.lastErr = err
+	if sum != nil {
+		st.last = sum
+	}
+}
+
+type statusResponse struct {
+	Running   bool       `json:"running"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	Total     int        `json:"total"`
+	Processed int        `json:"processed"`
+	Failed    int        `json:"failed"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+func (st *runStatus) response() statusResponse {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	resp := statusResponse{
+		Running:   st.running,
+		Total:     st.total,
+		Processed: st.processed,
+		Failed:    st.failed,
+	}
+
+	if !st.startedAt.IsZero() {
+		startedAt := st.startedAt
+		resp.StartedAt = &startedAt
+	}
+
+	if st.lastErr != nil {
+		resp.LastError = st.lastErr.Error()
+	}
+	return resp
+}
+
+func (st *runStatus) lastSummary() *runSummary {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return st.last
+}
+
+// registerRoutes registers the http api of the securer on the mux.
+// Runs triggered over http use the given context rather than the one of the request,
+// so they outlive it.
+func (s *securer) registerRoutes(ctx context.Context, mux *http.ServeMux) {
+	mux.HandleFunc("POST /v1/secure", func(w http.ResponseWriter, r *http.Request) {
+		if !s.tryPass(ctx) {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "a run is in progress"})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "started"})
+	})
+
+	mux.HandleFunc("GET /v1/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.status.response())
+	})
+
+	mux.HandleFunc("GET /v1/report", func(w http.ResponseWriter, r *http.Request) {
+		sum := s.status.lastSummary()
+		if sum == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no run finished yet"})
+			return
+		}
+		writeJSON(w, http.StatusOK, sum)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// readiness tells whether the daemon is ready to do its job: the credentials were checked
+// and a page of the images of the account could be listed.
+type readiness struct {
+	credentialsChecked atomic.Bool
+	listed             atomic.Bool
+}
+
+func (rd *readiness) ready() (bool, string) {
+	switch {
+	case !rd.credentialsChecked.Load():
+		return false, "credentials not checked"
+	case !rd.listed.Load():
+		return false, "images not listed yet"
+	default:
+		return true, ""
+	}
+}
+
+// warmUp checks the credentials and lists the images until both succeed, making the daemon ready.
+func (s *securer) warmUp(ctx context.Context) {
+	defer s.opts.errReporter.recoverPanic(s.opts.accountID)
+
+	const retryInterval = 30 * time.Second
+
+	for {
+		err := s.checkReady(ctx)
+		if err == nil {
+			slog.Info("ready")
+			return
+		}
+
+		slog.Error("not ready", "error", err)
+		if !sleepUntil(ctx, time.Now().Add(retryInterval)) {
+			return
+		}
+	}
+}
+
+func (s *securer) checkReady(ctx context.Context) error {
+	// Only api tokens can be verified, the listing proves the global api keys work.
+	if !s.readiness.credentialsChecked.Load() {
+		if s.opts.authKey == "" {
+			if _, err := s.cli.VerifyToken(ctx); err != nil {
+				return fmt.Errorf("failed to verify token: %s", err)
+			}
+		}
+		s.readiness.credentialsChecked.Store(true)
+	}
+
+	// The first image is enough to tell the listing works, the rest of the account isn't fetched.
+	if !s.readiness.listed.Load() {
+		for _, err := range s.cli.Images(ctx) {
+			if err != nil {
+				return fmt.Errorf("failed to list images: %s", err)
+			}
+			break
+		}
+		s.readiness.listed.Store(true)
+	}
+	return nil
+}
+
+// registerHealthRoutes registers the liveness and readiness probes on the mux.
+func (s *securer) registerHealthRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if ok, reason := s.readiness.ready(); !ok {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "not ready", "reason": reason})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	})
+}
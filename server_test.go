@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/cloudflareclient/cloudflaretest"
+)
+
+func TestCheckReadyListsOnePage(t *testing.T) {
+	images := make([]cloudflareclient.Image, 50)
+	for i := range images {
+		images[i] = cloudflareclient.Image{ID: fmt.Sprintf("image-%02d", i)}
+	}
+	srv := cloudflaretest.NewServer("account", "token", images...)
+	defer srv.Close()
+
+	s := &securer{
+		cli:  srv.Client(cloudflareclient.WithPageSize(10), cloudflareclient.WithListConcurrency(1)),
+		opts: &options{accountID: "account"},
+	}
+	if err := s.checkReady(context.Background()); err != nil {
+		t.Fatalf("checkReady: %s", err)
+	}
+	if ok, reason := s.readiness.ready(); !ok {
+		t.Errorf("not ready: %s", reason)
+	}
+
+	var pages int
+	for _, r := range srv.Requests() {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.Path, "/images/v1") {
+			pages++
+		}
+	}
+	if pages != 1 {
+		t.Errorf("%d pages listed, want 1", pages)
+	}
+}
+
+func TestCheckReadyEmptyAccount(t *testing.T) {
+	srv := cloudflaretest.NewServer("account", "token")
+	defer srv.Close()
+
+	s := &securer{cli: srv.Client(), opts: &options{accountID: "account"}}
+	if err := s.checkReady(context.Background()); err != nil {
+		t.Fatalf("checkReady: %s", err)
+	}
+	if ok, reason := s.readiness.ready(); !ok {
+		t.Errorf("not ready: %s", reason)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// signedURL is a freshly signed delivery URL of an image.
+type signedURL struct {
+	ImageID   string    `json:"image_id"`
+	Variant   string    `json:"variant"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// signURLsCmd writes signed delivery URLs of the images, expiring after -ttl, for the services
+// downstream to serve the images while they move to signing their URLs themselves.
+func signURLsCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sign-urls", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	idsFilePtr := fs.String("ids-file", "", "file with the ids or the delivery urls of the images to sign the urls of, one per line, or - for stdin")
+	accountHashPtr := fs.String("account-hash", "", "account hash of the delivery urls, shown on the images page of the dashboard")
+	variantPtr := fs.String("variant", "public", "comma separated variants of the images the urls are for, flexible variants included, or all for every variant of each image as listed by the api")
+	deliveryURLPtr := fs.String("delivery-url", "https://"+deliveryHost, "where the images are delivered from, or https://<zone>/cdn-cgi/imagedelivery to serve them from a zone of the account")
+	ttlPtr := fs.Duration("ttl", time.Hour, "how long the signed urls are valid for")
+	keyPtr := fs.String("signing-key", os.Getenv(signingKeyEnv), "signing key to sign the urls with, defaults to the "+signingKeyEnv+" environment variable, or else the one of the account named -signing-key-name")
+	keyNamePtr := fs.String("signing-key-name", "default", "name of the signing key of the account to sign the urls with, without -signing-key")
+	formatPtr := fs.String("format", "csv", "format of the signed urls, csv or json")
+	outPtr := fs.String("out", "", "file to write the signed urls to, defaults to stdout")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	switch {
+	case *idsFilePtr == "" || *accountHashPtr == "":
+		fs.Usage()
+		return errors.New("-ids-file and -account-hash are required")
+	case *ttlPtr <= 0:
+		return errors.New("-ttl must be positive")
+	case *formatPtr != "csv" && *formatPtr != "json":
+		return fmt.Errorf("invalid -format '%s', expected csv or json", *formatPtr)
+	}
+
+	base, err := url.Parse(strings.TrimSuffix(*deliveryURLPtr, "/"))
+	if err != nil || (base.Scheme != "https" && base.Scheme != "http") || base.Host == "" {
+		return fmt.Errorf("-delivery-url must be an http or https url: %q", *deliveryURLPtr)
+	}
+
+	ids, err := readIDsFile(*idsFilePtr, *accountHashPtr)
+	if err != nil {
+		return fmt.Errorf("failed to read ids file: %s", err)
+	}
+
+	variants, allVariants := splitList(*variantPtr), *variantPtr == "all"
+	if len(variants) == 0 {
+		return errors.New("-variant is required")
+	}
+
+	// The account is only needed for its signing keys and the variants of the images.
+	key := *keyPtr
+	var cli *cloudflareclient.Client
+	if key == "" || allVariants {
+		if cli, err = opts.signingClient(ctx, fs, "-signing-key and -variant other than all, or the credentials of the account, are required"); err != nil {
+			return err
+		}
+	}
+
+	if cli != nil {
+		if key == "" {
+			keys, err := cli.SigningKeys(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list signing keys: %s", err)
+			}
+
+			if key, err = pickSigningKey(keys, *keyNamePtr); err != nil {
+				return err
+			}
+		}
+
+		if !allVariants {
+			if err := checkVariants(ctx, cli, variants); err != nil {
+				return err
+			}
+		}
+	}
+
+	expiresAt := time.Now().Add(*ttlPtr).Truncate(time.Second).UTC()
+	urls := make([]signedURL, 0, len(ids))
+	for _, id := range ids {
+		imageVariants := variants
+		if allVariants {
+			img, err := cli.GetImage(ctx, id)
+			if err != nil {
+				return fmt.Errorf("failed to get variants of image %s: %s", id, err)
+			}
+			imageVariants = variantNames(img)
+		}
+
+		for _, v := range imageVariants {
+			u, err := cloudflareclient.SignURL(deliveryURL(base, *accountHashPtr, id, v), key, expiresAt)
+			if err != nil {
+				return fmt.Errorf("failed to sign url of image %s: %s", id, err)
+			}
+			urls = append(urls, signedURL{ImageID: id, Variant: v, URL: u, ExpiresAt: expiresAt})
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPtr != "" {
+		f, err := os.Create(*outPtr)
+		if err != nil {
+			return fmt.Errorf("failed to create signed urls file: %s", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if *formatPtr == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(urls)
+	}
+	return writeSignedURLsCSV(out, urls)
+}
+
+// pickSigningKey returns the key of the account named name.
+func pickSigningKey(keys []cloudflareclient.SigningKey, name string) (string, error) {
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k.Name == name {
+			return k.Value, nil
+		}
+		names = append(names, k.Name)
+	}
+	return "", fmt.Errorf("no signing key named '%s', the account has %s", name, strings.Join(names, ", "))
+}
+
+// checkVariants checks the variants are ones of the account, for the URLs to be the ones the
+// applications request. The flexible variants, the options of the resizing such as w=400,
+// aren't checked. The variants served publicly are reported, their URLs needing no signature.
+func checkVariants(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI, variants []string) error {
+	account, err := cli.ListVariants(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list variants: %s", err)
+	}
+
+	ids := make([]string, 0, len(account))
+	byID := map[string]cloudflareclient.Variant{}
+	for _, v := range account {
+		ids = append(ids, v.ID)
+		byID[v.ID] = v
+	}
+
+	for _, name := range variants {
+		if strings.Contains(name, "=") {
+			continue
+		}
+
+		v, ok := byID[name]
+		if !ok {
+			return fmt.Errorf("no variant named '%s', the account has %s", name, strings.Join(ids, ", "))
+		}
+
+		if v.NeverRequireSignedURLs {
+			slog.Warn("the variant is served publicly, its urls need no signature", "variant", name)
+		}
+	}
+	return nil
+}
+
+// variantNames returns the names of the variants of the image, the last segment of their delivery URLs.
+func variantNames(img *cloudflareclient.Image) []string {
+	names := make([]string, 0, len(img.Variants))
+	for _, v := range img.Variants {
+		p, _, _ := strings.Cut(v, "?")
+		names = append(names, path.Base(p))
+	}
+	return names
+}
+
+// deliveryURL returns the URL the variant of the image is delivered at, the segments of
+// the custom ids containing slashes escaped one by one.
+func deliveryURL(base *url.URL, accountHash, imageID, variant string) string {
+	segments := []string{url.PathEscape(accountHash)}
+	for _, s := range strings.Split(imageID, "/") {
+		segments = append(segments, url.PathEscape(s))
+	}
+	segments = append(segments, url.PathEscape(variant))
+	return base.String() + "/" + strings.Join(segments, "/")
+}
+
+func writeSignedURLsCSV(w io.Writer, urls []signedURL) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"image_id", "variant", "url", "expires_at"}); err != nil {
+		return err
+	}
+
+	for _, u := range urls {
+		if err := cw.Write([]string{u.ImageID, u.Variant, u.URL, u.ExpiresAt.Format(time.RFC3339)}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// snapshotCmd writes the inventory of the account to a file, without modifying the images,
+// for the diff command to compare with a later one.
+func snapshotCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	outPtr := fs.String("out", "", "file to write the inventory to")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	if *outPtr == "" {
+		fs.Usage()
+		return errors.New("-out is required")
+	}
+
+	cli, err := opts.connect(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	images, err := cli.ListImages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %s", err)
+	}
+
+	if err := writeInventory(*outPtr, newInventory(opts.accountID, images)); err != nil {
+		return err
+	}
+
+	slog.Info("snapshot written", "file", *outPtr, "images", len(images))
+	return nil
+}
+
+// diffCmd shows what changed between two inventories: the images added,
+// the ones removed and the ones whose protection flipped.
+func diffCmd(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s diff <old inventory> <new inventory>\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("two inventory files are required")
+	}
+
+	before, err := readInventory(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	after, err := readInventory(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if before.AccountID != after.AccountID {
+		return fmt.Errorf("inventories were taken for different accounts, '%s' and '%s'", before.AccountID, after.AccountID)
+	}
+
+	d := diffInventories(before, after)
+	current := after.byID()
+	for _, id := range d.added {
+		fmt.Printf("+ %s %s\n", id, protectionName(current[id].RequireSignedURLs))
+	}
+	for _, id := range d.removed {
+		fmt.Printf("- %s\n", id)
+	}
+	for _, id := range d.secured {
+		fmt.Printf("~ %s public -> signed\n", id)
+	}
+	for _, id := range d.exposed {
+		fmt.Printf("~ %s signed -> public\n", id)
+	}
+
+	fmt.Printf("between %s and %s: %d added, %d removed, %d secured, %d made public\n",
+		before.TakenAt.Format("2006-01-02 15:04:05 MST"), after.TakenAt.Format("2006-01-02 15:04:05 MST"),
+		len(d.added), len(d.removed), len(d.secured), len(d.exposed))
+	return nil
+}
+
+// inventoryDiff are the ids of the images that changed between two inventories, sorted.
+type inventoryDiff struct {
+	added   []string
+	removed []string
+	// secured are the images requiring signed URLs since, exposed the ones not requiring them anymore.
+	secured []string
+	exposed []string
+}
+
+func diffInventories(before, after *inventory) inventoryDiff {
+	var d inventoryDiff
+
+	old := before.byID()
+	for _, img := range after.Images {
+		prev, ok := old[img.ID]
+		switch {
+		case !ok:
+			d.added = append(d.added, img.ID)
+		case !prev.RequireSignedURLs && img.RequireSignedURLs:
+			d.secured = append(d.secured, img.ID)
+		case prev.RequireSignedURLs && !img.RequireSignedURLs:
+			d.exposed = append(d.exposed, img.ID)
+		}
+	}
+
+	current := after.byID()
+	for _, img := range before.Images {
+		if _, ok := current[img.ID]; !ok {
+			d.removed = append(d.removed, img.ID)
+		}
+	}
+
+	for _, ids := range [][]string{d.added, d.removed, d.secured, d.exposed} {
+		slices.Sort(ids)
+	}
+	return d
+}
+
+func protectionName(requireSignedURLs bool) string {
+	if requireSignedURLs {
+		return "signed"
+	}
+	return "public"
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// statsCmd shows the number of images stored against the plan allowance,
+// with the breakdown of the protected and unprotected ones.
+func statsCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	opts.registerSelectionFlags(fs)
+	breakdownPtr := fs.Bool("breakdown", true, "list the images to break them down by protection, which takes a while on large accounts")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	sel, err := opts.loadSelection()
+	if err != nil {
+		return err
+	}
+
+	cli, err := opts.connect(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	stats, err := cli.GetStats(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get stats: %s", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	usage := ""
+	if stats.Allowed > 0 {
+		usage = fmt.Sprintf(" (%.1f%%)", float64(stats.Current)*100/float64(stats.Allowed))
+	}
+	fmt.Fprintf(tw, "images stored\t%d of %d allowed%s\n", stats.Current, stats.Allowed, usage)
+
+	if *breakdownPtr {
+		images, err := cli.ListImages(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list images: %s", err)
+		}
+
+		changes, excluded, drafts, tooRecent := sel.filter.changes(images, sel.policy)
+		var unprotected int
+		for _, c := range changes {
+			if c.RequireSignedURLs {
+				unprotected++
+			}
+		}
+
+		protected := countProtected(images)
+		fmt.Fprintf(tw, "requiring signed URLs\t%d\n", protected)
+		fmt.Fprintf(tw, "public\t%d\n", len(images)-protected)
+		fmt.Fprintf(tw, "  unprotected against the policy\t%d\n", unprotected)
+		fmt.Fprintf(tw, "  intentionally public\t%d\n", len(excluded))
+		fmt.Fprintf(tw, "  drafts\t%d\n", len(drafts))
+		fmt.Fprintf(tw, "  uploaded less than -min-age ago\t%d\n", len(tooRecent))
+	}
+	return tw.Flush()
+}
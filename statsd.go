@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// maxStatsdPacket keeps the datagrams under the usual MTU, the metrics of a run
+// are split over several of them if needed.
+const maxStatsdPacket = 1432
+
+// statsdEmitter publishes the counters and timing of every run to a StatsD server,
+// in the DogStatsD format with the account and shard as tags, for Datadog.
+type statsdEmitter struct {
+	conn   net.Conn
+	prefix string
+}
+
+func newStatsdEmitter(addr, prefix string) (*statsdEmitter, error) {
+	// Dialing udp doesn't send anything, it only resolves the address.
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -statsd-addr: %s", err)
+	}
+
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	return &statsdEmitter{conn: conn, prefix: prefix}, nil
+}
+
+// emit sends the metrics of the run.
+func (e *statsdEmitter) emit(sum *runSummary) error {
+	tags := "|#account_id:" + sum.AccountID
+	if sum.Shard != "" {
+		tags += ",shard:" + sum.Shard
+	}
+
+	interrupted := 0
+	if sum.Interrupted {
+		interrupted = 1
+	}
+
+	metrics := []struct {
+		name  string
+		value int64
+		kind  string
+	}{
+		{"runs", 1, "c"},
+		{"runs.interrupted", int64(interrupted), "c"},
+		{"run.duration", sum.Duration.Milliseconds(), "ms"},
+		{"images.listed", int64(sum.Listed), "c"},
+		{"images.secured", int64(sum.Secured), "c"},
+		{"images.already_secured", int64(sum.AlreadySecured), "c"},
+		{"images.made_public", int64(sum.MadePublic), "c"},
+		{"images.failed", int64(sum.Failed), "c"},
+		{"images.skipped", int64(sum.Skipped), "c"},
+		{"images.excluded", int64(sum.Excluded), "c"},
+		{"images.drafts", int64(sum.Drafts), "c"},
+		{"images.too_recent", int64(sum.TooRecent), "c"},
+		{"images.deleted", int64(sum.Deleted), "c"},
+		{"images.remaining_unprotected", int64(sum.RemainingUnprotected), "g"},
+		{"images.remaining_protected", int64(sum.RemainingProtected), "g"},
+		{"api.requests", int64(sum.APIRequests), "c"},
+		{"api.rate_limited", int64(sum.APIRateLimited), "c"},
+	}
+
+	var packet strings.Builder
+	for _, m := range metrics {
+		line := fmt.Sprintf("%s%s:%d|%s%s", e.prefix, m.name, m.value, m.kind, tags)
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxStatsdPacket {
+			if err := e.send(packet.String()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	return e.send(packet.String())
+}
+
+func (e *statsdEmitter) send(packet string) error {
+	e.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	if _, err := e.conn.Write([]byte(packet)); err != nil {
+		return fmt.Errorf("failed to send statsd metrics: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runSummary is the outcome of a run.
+type runSummary struct {
+	AccountID string `json:"account_id"`
+	// Shard is the shard of the images the run operated on, as index/count, if distributed.
+	Shard       string        `json:"shard,omitempty"`
+	StartedAt   time.Time     `json:"started_at"`
+	Duration    time.Duration `json:"-"`
+	Interrupted bool          `json:"interrupted"`
+
+	// Listed are the images listed from the account, and AlreadyProtected those of them
+	// requiring signed URLs before the run. Both are zero when operating on explicit ids.
+	Listed           int `json:"listed"`
+	AlreadyProtected int `json:"already_protected"`
+
+	Secured int `json:"secured"`
+	// AlreadySecured are the images given to secure which required signed URLs already.
+	AlreadySecured int `json:"already_secured"`
+	MadePublic     int `json:"made_public"`
+	Failed         int `json:"failed"`
+	// FailureClasses are the numbers of failed changes by class of error: auth, not-found,
+	// rate-limited, server-error, network or other.
+	FailureClasses map[string]int `json:"failure_classes,omitempty"`
+	// Skipped are the changes not attempted because the run was interrupted,
+	// or held back after the canary or past -max-changes.
+	Skipped int `json:"skipped"`
+	// Excluded are the images left as they are because they are intentionally public.
+	Excluded int `json:"excluded"`
+	// Drafts are the images left as they are because their upload isn't completed yet.
+	Drafts int `json:"drafts"`
+	// TooRecent are the images left as they are because they were uploaded less than -min-age ago.
+	TooRecent int `json:"too_recent"`
+	// Unknown are the images given with -ids-file the account doesn't have.
+	Unknown int `json:"unknown"`
+	// Deleted are the dangerous images deleted at the end of their quarantine.
+	Deleted int `json:"deleted"`
+
+	// RemainingUnprotected and RemainingProtected are the images still not in the desired state
+	// at the end of the run. When interrupted they are estimated from the changes not applied.
+	RemainingUnprotected int `json:"remaining_unprotected"`
+	RemainingProtected   int `json:"remaining_protected"`
+
+	// APIRequests are the requests made to the API during the run, APIRateLimited the ones
+	// rejected by the rate limit. RateLimit and RateLimitPolicy are the last Ratelimit and
+	// Ratelimit-Policy headers returned by the API, telling the quota left.
+	APIRequests     int    `json:"api_requests"`
+	APIRateLimited  int    `json:"api_rate_limited"`
+	RateLimit       string `json:"rate_limit,omitempty"`
+	RateLimitPolicy string `json:"rate_limit_policy,omitempty"`
+
+	SecuredIDs              []string `json:"secured_ids"`
+	FailedIDs               []string `json:"failed_ids"`
+	UnknownIDs              []string `json:"unknown_ids,omitempty"`
+	RemainingUnprotectedIDs []string `json:"remaining_unprotected_ids"`
+
+	// usageStart is the usage of the API when the run started.
+	usageStart usageSnapshot
+}
+
+// MarshalJSON adds the duration of the run in seconds.
+func (s *runSummary) MarshalJSON() ([]byte, error) {
+	type summary runSummary
+	return json.Marshal(struct {
+		*summary
+		DurationSeconds float64 `json:"duration_seconds"`
+	}{
+		summary:         (*summary)(s),
+		DurationSeconds: s.Duration.Seconds(),
+	})
+}
+
+func newRunSummary(accountID string, startedAt time.Time, usageStart usageSnapshot, p *planned, res *applyResult) *runSummary {
+	sum := runSummary{
+		AccountID:  accountID,
+		StartedAt:  startedAt,
+		usageStart: usageStart,
+		Duration:   time.Since(startedAt),
+		Listed:     p.listed,
+		Failed:     len(res.failed),
+		Skipped:    len(res.skipped),
+		Excluded:   len(p.excluded),
+		Drafts:     len(p.drafts),
+		TooRecent:  len(p.tooRecent),
+		Unknown:    len(p.unknown),
+
+		AlreadyProtected: p.protected,
+		AlreadySecured:   len(res.alreadySecured),
+		FailureClasses:   res.failures,
+	}
+
+	for _, c := range res.applied {
+		if c.RequireSignedURLs {
+			sum.Secured++
+			sum.SecuredIDs = append(sum.SecuredIDs, c.ImageID)
+		} else {
+			sum.MadePublic++
+		}
+	}
+
+	for _, c := range res.failed {
+		sum.FailedIDs = append(sum.FailedIDs, c.ImageID)
+	}
+	sum.UnknownIDs = p.unknown
+
+	// Until the images are listed again, what is left is what wasn't applied.
+	sum.setRemaining(append(append([]change{}, res.failed...), res.skipped...))
+	return &sum
+}
+
+// setRemaining sets the images left not in the desired state from the changes still pending.
+func (s *runSummary) setRemaining(remaining []change) {
+	s.RemainingUnprotected, s.RemainingProtected = 0, 0
+	s.RemainingUnprotectedIDs = nil
+	for _, c := range remaining {
+		if c.RequireSignedURLs {
+			s.RemainingUnprotected++
+			s.RemainingUnprotectedIDs = append(s.RemainingUnprotectedIDs, c.ImageID)
+		} else {
+			s.RemainingProtected++
+		}
+	}
+}
+
+func (s *runSummary) log() {
+	slog.Info("run summary",
+		"secured", s.Secured,
+		"already_secured", s.AlreadySecured,
+		"made_public", s.MadePublic,
+		"failed", s.Failed,
+		"skipped", s.Skipped,
+		"excluded", s.Excluded,
+		"drafts", s.Drafts,
+		"too_recent", s.TooRecent,
+		"unknown", s.Unknown,
+		"deleted", s.Deleted,
+		"remaining_unprotected", s.RemainingUnprotected,
+		"remaining_protected", s.RemainingProtected,
+		"duration", s.Duration.Round(time.Millisecond),
+		"interrupted", s.Interrupted,
+		"api_requests", s.APIRequests,
+		"api_rate_limited", s.APIRateLimited,
+	)
+
+	if s.Failed > 0 {
+		slog.Warn("changes failed", "count", s.Failed, "classes", formatFailureClasses(s.FailureClasses))
+	}
+
+	if s.RateLimit != "" || s.RateLimitPolicy != "" {
+		slog.Info("api rate limit", "ratelimit", s.RateLimit, "ratelimit_policy", s.RateLimitPolicy)
+	}
+
+	if s.RemainingUnprotected > 0 {
+		slog.Warn("images left unprotected", "count", s.RemainingUnprotected)
+	}
+
+	if s.RemainingProtected > 0 {
+		slog.Warn("images left protected against the policy", "count", s.RemainingProtected)
+	}
+}
+
+// writeTable writes the summary as a table, for the operator to read at the end of the run.
+func (s *runSummary) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	row := func(name string, value any) {
+		fmt.Fprintf(tw, "%s\t%v\n", name, value)
+	}
+
+	if s.Listed > 0 {
+		row("total images", s.Listed)
+		row("already protected", s.AlreadyProtected)
+	}
+	row("secured", s.Secured)
+	if s.AlreadySecured > 0 {
+		row("already secured", s.AlreadySecured)
+	}
+	if s.MadePublic > 0 {
+		row("made public", s.MadePublic)
+	}
+	if s.Failed > 0 {
+		row("failed", fmt.Sprintf("%d (%s)", s.Failed, formatFailureClasses(s.FailureClasses)))
+	} else {
+		row("failed", s.Failed)
+	}
+	if s.Deleted > 0 {
+		row("deleted", s.Deleted)
+	}
+	row("skipped", fmt.Sprintf("%d (%d excluded, %d drafts, %d too recent, %d not attempted)", s.Excluded+s.Drafts+s.TooRecent+s.Skipped, s.Excluded, s.Drafts, s.TooRecent, s.Skipped))
+	if s.Unknown > 0 {
+		row("unknown", s.Unknown)
+	}
+	row("remaining unprotected", s.RemainingUnprotected)
+	row("duration", s.Duration.Round(time.Millisecond))
+	row("api requests", fmt.Sprintf("%d (%d rate limited)", s.APIRequests, s.APIRateLimited))
+	if s.RateLimit != "" {
+		row("api rate limit", s.RateLimit)
+	}
+	return tw.Flush()
+}
+
+// writeSummary writes the summary as JSON, replacing the file of a previous run only once complete.
+func writeSummary(name string, sum *runSummary) error {
+	data, err := json.MarshalIndent(sum, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode summary: %s", err)
+	}
+
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not write summary: %s", err)
+	}
+
+	if err := os.Rename(tmp, name); err != nil {
+		return fmt.Errorf("could not write summary: %s", err)
+	}
+	return nil
+}
+
+// report logs the summary of the run and delivers it where the options say.
+// Delivery failures are logged rather than failing the run, which already happened.
+func (o *options) report(ctx context.Context, sum *runSummary) {
+	o.usage.record(sum)
+	sum.log()
+
+	switch o.output {
+	case "terraform-external":
+		// Terraform reads the result from stdout, nothing else may be written to it.
+		if err := writeTerraformResult(os.Stdout, sum); err != nil {
+			slog.Error("failed to print terraform result", "error", err)
+		}
+	case "github":
+		if err := sum.writeTable(os.Stdout); err != nil {
+			slog.Error("failed to print run summary", "error", err)
+		}
+		if err := writeGitHubAnnotations(os.Stdout, sum); err != nil {
+			slog.Error("failed to print github annotations", "error", err)
+		}
+		if err := writeGitHubStepSummary(sum); err != nil {
+			slog.Error("failed to write github step summary", "error", err)
+		}
+	default:
+		if err := sum.writeTable(os.Stdout); err != nil {
+			slog.Error("failed to print run summary", "error", err)
+		}
+	}
+
+	if o.summaryOut != "" {
+		if err := writeSummary(o.summaryOut, sum); err != nil {
+			slog.Error("failed to write run summary", "file", o.summaryOut, "error", err)
+		}
+	}
+
+	// The run may have been interrupted, the report is delivered regardless.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	if o.notifyWebhook != "" {
+		if err := notifyWebhook(ctx, o.notifyWebhook, sum); err != nil {
+			slog.Error("failed to send webhook notification", "error", err)
+		}
+	}
+
+	if o.mailer != nil {
+		if err := o.mailer.report(sum); err != nil {
+			slog.Error("failed to send report email", "error", err)
+		}
+	}
+
+	o.ownerNotifier.notify(ctx, sum.AccountID)
+
+	if o.alerter != nil {
+		if err := alert(ctx, o.alerter, sum); err != nil {
+			slog.Error("failed to send alert", "error", err)
+		}
+	}
+
+	if o.statsd != nil {
+		if err := o.statsd.emit(sum); err != nil {
+			slog.Error("failed to publish run metrics", "error", err)
+		}
+	}
+
+	o.events.report(sum)
+
+	if o.reportWebhook != "" {
+		if err := postReport(ctx, o.reportWebhook, o.reportWebhookSecret, sum); err != nil {
+			slog.Error("failed to post run report", "error", err)
+		}
+	}
+}
+
+// readTerraformQuery sets the flags given by the query of a Terraform external data source,
+// a JSON object of strings read from stdin such as {"ids-file": "ids.txt", "min-age": "1h"},
+// as if they were given on the command line, which they can't be given on as well.
+func readTerraformQuery(r io.Reader, fs *flag.FlagSet) error {
+	var query map[string]string
+	if err := json.NewDecoder(r).Decode(&query); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid terraform query, expected a json object of strings: %s", err)
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for _, name := range slices.Sorted(maps.Keys(query)) {
+		switch {
+		case fs.Lookup(name) == nil:
+			return fmt.Errorf("invalid terraform query: unknown flag '%s'", name)
+		case set[name]:
+			return fmt.Errorf("invalid terraform query: -%s is given by the program already", name)
+		}
+		if err := fs.Set(name, query[name]); err != nil {
+			return fmt.Errorf("invalid terraform query: -%s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// writeTerraformResult writes the summary as the result of a Terraform external data source:
+// a single JSON object of strings, the ids being comma separated.
+func writeTerraformResult(w io.Writer, sum *runSummary) error {
+	result := map[string]string{
+		"account_id":                sum.AccountID,
+		"shard":                     sum.Shard,
+		"interrupted":               strconv.FormatBool(sum.Interrupted),
+		"listed":                    strconv.Itoa(sum.Listed),
+		"already_protected":         strconv.Itoa(sum.AlreadyProtected),
+		"secured":                   strconv.Itoa(sum.Secured),
+		"already_secured":           strconv.Itoa(sum.AlreadySecured),
+		"made_public":               strconv.Itoa(sum.MadePublic),
+		"failed":                    strconv.Itoa(sum.Failed),
+		"skipped":                   strconv.Itoa(sum.Skipped),
+		"excluded":                  strconv.Itoa(sum.Excluded),
+		"drafts":                    strconv.Itoa(sum.Drafts),
+		"too_recent":                strconv.Itoa(sum.TooRecent),
+		"remaining_unprotected":     strconv.Itoa(sum.RemainingUnprotected),
+		"remaining_protected":       strconv.Itoa(sum.RemainingProtected),
+		"all_protected":             strconv.FormatBool(sum.RemainingUnprotected == 0),
+		"failed_ids":                strings.Join(sum.FailedIDs, ","),
+		"remaining_unprotected_ids": strings.Join(sum.RemainingUnprotectedIDs, ","),
+	}
+	return json.NewEncoder(w).Encode(result)
+}
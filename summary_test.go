@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadTerraformQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		query   string
+		wantErr bool
+		minAge  time.Duration
+	}{
+		{name: "empty object", query: "{}"},
+		{name: "nothing", query: ""},
+		{name: "flag set", query: `{"min-age": "1h"}`, minAge: time.Hour},
+		{name: "flag of the program kept", args: []string{"-min-age", "2h"}, query: "{}", minAge: 2 * time.Hour},
+		{name: "flag given twice", args: []string{"-min-age", "2h"}, query: `{"min-age": "1h"}`, wantErr: true},
+		{name: "unknown flag", query: `{"max-age": "1h"}`, wantErr: true},
+		{name: "invalid value", query: `{"min-age": "soon"}`, wantErr: true},
+		{name: "not strings", query: `{"min-age": 3600}`, wantErr: true},
+		{name: "not an object", query: `["min-age"]`, wantErr: true},
+		{name: "invalid json", query: `{"min-age": `, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("secure", flag.ContinueOnError)
+			minAge := fs.Duration("min-age", 0, "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			err := readTerraformQuery(strings.NewReader(tt.query), fs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readTerraformQuery(%s) = %v, want an error: %t", tt.query, err, tt.wantErr)
+			}
+			if !tt.wantErr && *minAge != tt.minAge {
+				t.Errorf("-min-age %s, want %s", *minAge, tt.minAge)
+			}
+		})
+	}
+}
+
+func TestTerraformQueryLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := w.WriteString(`{"log-level": "debug", "log-format": "json"}`); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	ctx := context.WithValue(context.Background(), validatingConfigKey{}, true)
+	if err := secureCmd(ctx, []string{"-account-id", "account", "-api-key", "key", "-output", "terraform-external"}); err != nil {
+		t.Fatalf("secure: %s", err)
+	}
+
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		t.Error("-log-level debug of the terraform query not applied")
+	}
+	if _, ok := slog.Default().Handler().(*slog.JSONHandler); !ok {
+		t.Errorf("logging with %T, want the -log-format json of the terraform query", slog.Default().Handler())
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// defaultTerminationLog is where Kubernetes reads the termination message of a container from.
+const defaultTerminationLog = "/dev/termination-log"
+
+// terminationMessage is the outcome of a single pass, written to the termination log for the
+// status of the Kubernetes job to tell what happened.
+type terminationMessage struct {
+	// Outcome is succeeded, failed, gate_failed, interrupted or timed_out, matching the exit code.
+	Outcome  string `json:"outcome"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	// Summary is the summary of the run without the ids of the images, Kubernetes keeping
+	// 4096 bytes of the message at most.
+	Summary *runSummary `json:"summary,omitempty"`
+}
+
+var exitOutcomes = map[int]string{
+	exitOK:          "succeeded",
+	exitFailed:      "failed",
+	exitUsage:       "failed",
+	exitGate:        "gate_failed",
+	exitInterrupted: "interrupted",
+	exitTimedOut:    "timed_out",
+}
+
+// writeTerminationMessage writes the outcome of the pass to the termination log, if the file
+// exists, Kubernetes creating it for the containers. sum is nil when the run failed to start.
+func writeTerminationMessage(name string, sum *runSummary, runErr error) error {
+	if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	code := exitCode(runErr)
+	msg := terminationMessage{Outcome: exitOutcomes[code], ExitCode: code}
+	if runErr != nil {
+		msg.Error = runErr.Error()
+		if len(msg.Error) > 1024 {
+			msg.Error = msg.Error[:1024] + "..."
+		}
+	}
+
+	if sum != nil {
+		s := *sum
+		s.SecuredIDs, s.FailedIDs, s.UnknownIDs, s.RemainingUnprotectedIDs = nil, nil, nil, nil
+		msg.Summary = &s
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("could not encode termination message: %s", err)
+	}
+
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		return fmt.Errorf("could not write termination message: %s", err)
+	}
+	return nil
+}
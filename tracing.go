@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setupTracing exports the spans over OTLP/HTTP when an endpoint is configured with
+// the standard OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables,
+// which the exporter reads along with the other OTEL_EXPORTER_OTLP_* ones.
+// The returned function flushes the spans left and must be called before exiting.
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", "securecloudflareimg"),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return tp.Shutdown, nil
+}
+
+// startCommandSpan starts the span of a command. It is a child of the trace given in the
+// TRACEPARENT environment variable, if any, so a run can be correlated with the pipeline running it.
+func startCommandSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	if traceparent := os.Getenv("TRACEPARENT"); traceparent != "" {
+		carrier := propagation.MapCarrier{"traceparent": traceparent}
+		ctx = propagation.TraceContext{}.Extract(ctx, carrier)
+	}
+	return otel.Tracer("github.com/alesr/securecloudflareimage").Start(ctx, name)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/nats-io/nats.go"
+	"golang.org/x/oauth2/google"
+)
+
+// uploadSource receives the upload notifications from a message queue.
+type uploadSource interface {
+	// receive calls handle with the body of every message received until the context is done
+	// or the connection to the queue fails, acknowledging the messages handle returns true for.
+	receive(ctx context.Context, handle func(body []byte) bool) error
+}
+
+// newUploadSource returns the source of the upload notifications given with -upload-queue:
+//
+//	sqs://sqs.eu-west-1.amazonaws.com/123456789012/uploads  aws sqs queue, by its url without https
+//	pubsub://my-project/uploads                             gcp pub/sub subscription
+//	nats://localhost:4222/images.uploaded?queue=secure      nats subject, with an optional queue group
+func newUploadSource(ref string) (uploadSource, error) {
+	scheme, rest, ok := strings.Cut(ref, "://")
+	if !ok || rest == "" {
+		return nil, fmt.Errorf("invalid upload queue '%s', expected sqs://, pubsub:// or nats://", ref)
+	}
+
+	switch scheme {
+	case "sqs":
+		return sqsSource{queueURL: "https://" + rest}, nil
+	case "pubsub":
+		project, subscription, ok := strings.Cut(rest, "/")
+		if !ok || project == "" || subscription == "" {
+			return nil, errors.New("expected pubsub://<project>/<subscription>")
+		}
+		return pubsubSource{project: project, subscription: subscription}, nil
+	case "nats":
+		u, err := url.Parse(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upload queue '%s': %s", ref, err)
+		}
+
+		subject := strings.TrimPrefix(u.Path, "/")
+		if subject == "" {
+			return nil, errors.New("expected nats://<host>:<port>/<subject>[?queue=<group>]")
+		}
+		return natsSource{server: u.Scheme + "://" + u.Host, subject: subject, queue: u.Query().Get("queue"), user: u.User}, nil
+	default:
+		return nil, fmt.Errorf("invalid upload queue '%s', expected sqs://, pubsub:// or nats://", ref)
+	}
+}
+
+// consumeUploads queues the images of the notifications received from the source until the
+// context is done, reconnecting when the connection fails. The messages are acknowledged once
+// the images are queued, the ones not understood are left to the redelivery of the queue.
+func consumeUploads(ctx context.Context, src uploadSource, u *uploadSecurer) {
+	defer u.s.opts.errReporter.recoverPanic(u.s.opts.accountID)
+
+	handle := func(body []byte) bool {
+		ids, err := parseUploadEvent(body)
+		if err != nil {
+			slog.Error("invalid upload notification", "error", err)
+			return false
+		}
+
+		if !u.enqueue(ids...) {
+			slog.Warn("upload queue full, leaving the notification to the queue", "images", len(ids))
+			return false
+		}
+		return true
+	}
+
+	for ctx.Err() == nil {
+		if err := src.receive(ctx, handle); err != nil && ctx.Err() == nil {
+			slog.Error("failed to receive upload notifications, retrying", "error", err)
+			sleepUntil(ctx, time.Now().Add(uploadRetryDelay))
+		}
+	}
+}
+
+// errTooManyUploads is returned by parseUploadEvent for the notifications of more than
+// maxUploadEventImages images.
+var errTooManyUploads = fmt.Errorf("too many images in the notification, %d at most", maxUploadEventImages)
+
+// parseUploadEvent returns the image ids of an upload notification.
+func parseUploadEvent(body []byte) ([]string, error) {
+	var ev uploadEvent
+	if err := json.Unmarshal(body, &ev); err != nil {
+		return nil, fmt.Errorf("invalid body: %s", err)
+	}
+
+	ids := ev.ImageIDs
+	if ev.ImageID != "" {
+		ids = append(ids, ev.ImageID)
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("image_id or image_ids is required")
+	}
+	if len(ids) > maxUploadEventImages {
+		return nil, errTooManyUploads
+	}
+	return ids, nil
+}
+
+// sqsSource long polls an AWS SQS queue, with the credentials and region of the default AWS
+// configuration chain. The messages not acknowledged are received again after their
+// visibility timeout, or moved to the dead letter queue of the queue, if any.
+type sqsSource struct {
+	queueURL string
+}
+
+func (s sqsSource) receive(ctx context.Context, handle func([]byte) bool) error {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("could not load aws configuration: %s", err)
+	}
+	cli := sqs.NewFromConfig(cfg)
+
+	for ctx.Err() == nil {
+		out, err := cli.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(s.queueURL),
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     20,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range out.Messages {
+			if !handle([]byte(aws.ToString(msg.Body))) {
+				continue
+			}
+
+			if _, err := cli.DeleteMessage(context.WithoutCancel(ctx), &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(s.queueURL),
+				ReceiptHandle: msg.ReceiptHandle,
+			}); err != nil {
+				slog.Error("failed to acknowledge upload notification", "message_id", aws.ToString(msg.MessageId), "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// gcpPubSubURL is the base url of the Pub/Sub API, a variable for tests.
+var gcpPubSubURL = "https://pubsub.googleapis.com/v1"
+
+// pubsubSource pulls a GCP Pub/Sub subscription, with the application default credentials.
+// The messages not acknowledged are delivered again after the ack deadline of the subscription.
+type pubsubSource struct {
+	project, subscription string
+}
+
+func (s pubsubSource) receive(ctx context.Context, handle func([]byte) bool) error {
+	httpCli, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/pubsub")
+	if err != nil {
+		return fmt.Errorf("could not find gcp credentials: %s", err)
+	}
+
+	subscription := fmt.Sprintf("%s/projects/%s/subscriptions/%s", gcpPubSubURL, url.PathEscape(s.project), url.PathEscape(s.subscription))
+
+	for ctx.Err() == nil {
+		var pulled struct {
+			ReceivedMessages []struct {
+				AckID   string `json:"ackId"`
+				Message struct {
+					// Data is base64 encoded, which encoding/json decodes into a byte slice.
+					Data      []byte `json:"data"`
+					MessageID string `json:"messageId"`
+				} `json:"message"`
+			} `json:"receivedMessages"`
+		}
+		if err := postGCP(ctx, httpCli, subscription+":pull", map[string]any{"maxMessages": 100}, &pulled); err != nil {
+			return err
+		}
+
+		var ackIDs []string
+		for _, m := range pulled.ReceivedMessages {
+			if handle(m.Message.Data) {
+				ackIDs = append(ackIDs, m.AckID)
+			}
+		}
+
+		if len(ackIDs) == 0 {
+			continue
+		}
+
+		if err := postGCP(context.WithoutCancel(ctx), httpCli, subscription+":acknowledge", map[string]any{"ackIds": ackIDs}, nil); err != nil {
+			slog.Error("failed to acknowledge upload notifications", "messages", len(ackIDs), "error", err)
+		}
+	}
+	return nil
+}
+
+// postGCP posts the JSON body to a GCP API, decoding the JSON response into v if not nil.
+func postGCP(ctx context.Context, httpCli *http.Client, u string, body, v any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("could not encode request body: %s", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(string(b)))
+	if err != nil {
+		return fmt.Errorf("could not prepare request: %s", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if v == nil {
+		v = &struct{}{}
+	}
+	return getJSONWith(httpCli, req, v)
+}
+
+// natsSource subscribes to a NATS subject, in a queue group if given for several instances
+// to share the notifications. Core NATS doesn't redeliver the messages, the ones not handled
+// are left to the next pass.
+type natsSource struct {
+	server, subject, queue string
+	user                   *url.Userinfo
+}
+
+func (s natsSource) receive(ctx context.Context, handle func([]byte) bool) error {
+	opts := []nats.Option{nats.Name("securecloudflareimg")}
+	if s.user != nil {
+		password, _ := s.user.Password()
+		opts = append(opts, nats.UserInfo(s.user.Username(), password))
+	}
+
+	nc, err := nats.Connect(s.server, opts...)
+	if err != nil {
+		return fmt.Errorf("could not connect to nats: %s", err)
+	}
+	defer nc.Close()
+
+	closed := make(chan struct{})
+	nc.SetClosedHandler(func(*nats.Conn) { close(closed) })
+
+	sub, err := nc.QueueSubscribe(s.subject, s.queue, func(msg *nats.Msg) {
+		handle(msg.Data)
+	})
+	if err != nil {
+		return fmt.Errorf("could not subscribe to %s: %s", s.subject, err)
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-closed:
+		return errors.New("nats connection closed")
+	}
+}
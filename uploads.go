@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+const uploadsSecretEnv = "SECURECLOUDFLAREIMG_UPLOADS_SECRET"
+
+const (
+	// uploadQueueSize is the number of uploaded images waiting to be secured at most.
+	uploadQueueSize = 10000
+	// uploadRetryDelay is how long to wait before looking at an image again, when it is
+	// still a draft or failed to be secured.
+	uploadRetryDelay = 5 * time.Second
+	// maxUploadAttempts is how many times an image is looked at before leaving it to the next run.
+	maxUploadAttempts = 12
+	// maxUploadEventImages is the number of images of a notification at most, for it to fit in the queue.
+	maxUploadEventImages = 1000
+	// uploadLookupConcurrency is the number of uploaded images looked up concurrently.
+	uploadLookupConcurrency = 10
+)
+
+// uploadSecurer secures the images as their uploads are notified, within seconds rather than
+// at the next pass. The images are fetched first, the selection and policy deciding what to do
+// with them as for a pass.
+type uploadSecurer struct {
+	s   *securer
+	ids chan string
+	// queueMu is held while queueing, for the images of a notification to be queued all or none.
+	queueMu sync.Mutex
+
+	mu sync.Mutex
+	// attempts are the number of times the images waiting to be looked at again were attempted.
+	attempts map[string]int
+
+	// applier secures the images of every batch, for -max-changes to bound the changes made
+	// over the lifetime of the daemon rather than by each batch. Set up by the first batch.
+	applier *applier
+}
+
+func newUploadSecurer(s *securer) *uploadSecurer {
+	return &uploadSecurer{s: s, ids: make(chan string, uploadQueueSize), attempts: map[string]int{}}
+}
+
+// enqueue queues the images to be secured, returning false, without queueing any of them,
+// if the queue can't take them all: the notification is then sent again, its images
+// would be queued twice otherwise.
+func (u *uploadSecurer) enqueue(ids ...string) bool {
+	u.queueMu.Lock()
+	defer u.queueMu.Unlock()
+
+	// The images are only taken off the queue meanwhile, the free slots can't shrink.
+	if cap(u.ids)-len(u.ids) < len(ids) {
+		return false
+	}
+
+	for _, id := range ids {
+		u.ids <- id
+	}
+	return true
+}
+
+// run secures the queued images until the context is done, by batches of the images queued meanwhile.
+func (u *uploadSecurer) run(ctx context.Context) {
+	defer u.s.opts.errReporter.recoverPanic(u.s.opts.accountID)
+
+	for {
+		var batch []string
+		select {
+		case id := <-u.ids:
+			batch = append(batch, id)
+		case <-ctx.Done():
+			return
+		}
+
+	drain:
+		for len(batch) < uploadQueueSize {
+			select {
+			case id := <-u.ids:
+				batch = append(batch, id)
+			default:
+				break drain
+			}
+		}
+		u.secure(ctx, batch)
+	}
+}
+
+func (u *uploadSecurer) secure(ctx context.Context, ids []string) {
+	type lookup struct {
+		img *cloudflareclient.Image
+		err error
+	}
+
+	lookups := make([]lookup, len(ids))
+	sem := make(chan struct{}, uploadLookupConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			img, err := u.s.cli.GetImage(ctx, id)
+			lookups[i] = lookup{img, err}
+		}()
+	}
+	wg.Wait()
+
+	var changes []change
+	for i, id := range ids {
+		img, err := lookups[i].img, lookups[i].err
+		if code, ok := statusCode(err); ok && code == http.StatusNotFound {
+			slog.Warn("skipping uploaded image: not found", "image_id", id)
+			u.done(id)
+			continue
+		}
+		if err != nil {
+			slog.Error("failed to get uploaded image", "image_id", id, "error", err)
+			u.later(ctx, id, uploadRetryDelay)
+			continue
+		}
+
+		c, excluded, drafts, tooRecent := u.s.sel.filter.changes([]cloudflareclient.Image{*img}, u.s.sel.policy)
+		logExcluded(excluded)
+		switch {
+		case len(drafts) > 0:
+			u.later(ctx, id, uploadRetryDelay)
+		case len(tooRecent) > 0:
+			// Recent images are left to the application uploading them for -min-age.
+			u.later(ctx, id, time.Until(img.Uploaded.Add(u.s.sel.filter.minAge)))
+		default:
+			changes = append(changes, c...)
+			if len(c) == 0 {
+				u.done(id)
+			}
+		}
+	}
+
+	if len(changes) == 0 {
+		return
+	}
+
+	if u.applier == nil {
+		u.applier = u.s.opts.newApplier(u.s.cli)
+		if u.s.metrics != nil {
+			u.applier.observe(u.s.metrics.observeChange)
+		}
+	}
+
+	res := u.applier.apply(ctx, changes)
+	for _, c := range append(res.applied, res.alreadySecured...) {
+		u.done(c.ImageID)
+	}
+	for _, c := range res.failed {
+		u.later(ctx, c.ImageID, uploadRetryDelay)
+	}
+	for _, c := range res.skipped {
+		// Past -max-changes, no batch can secure the image anymore, the next pass may.
+		if u.applier.limitReached() {
+			slog.Warn("leaving uploaded image to the next run, reaching -max-changes", "image_id", c.ImageID)
+			u.done(c.ImageID)
+			continue
+		}
+		u.later(ctx, c.ImageID, uploadRetryDelay)
+	}
+	u.s.opts.ownerNotifier.notify(ctx, u.s.opts.accountID)
+}
+
+// later queues the image again after the delay, unless it was attempted too many times already,
+// leaving it to the next pass.
+func (u *uploadSecurer) later(ctx context.Context, id string, delay time.Duration) {
+	u.mu.Lock()
+	u.attempts[id]++
+	n := u.attempts[id]
+	if n >= maxUploadAttempts {
+		delete(u.attempts, id)
+	}
+	u.mu.Unlock()
+
+	if n >= maxUploadAttempts {
+		slog.Warn("giving up on uploaded image, leaving it to the next run", "image_id", id, "attempts", n)
+		return
+	}
+
+	time.AfterFunc(delay, func() {
+		if ctx.Err() == nil && !u.enqueue(id) {
+			slog.Warn("upload queue full, leaving the image to the next run", "image_id", id)
+		}
+	})
+}
+
+func (u *uploadSecurer) done(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.attempts, id)
+}
+
+// uploadEvent is the body of the upload notifications, with the id of a single image or of several.
+type uploadEvent struct {
+	ImageID  string   `json:"image_id"`
+	ImageIDs []string `json:"image_ids"`
+}
+
+// registerRoutes registers POST /v1/uploads, receiving the upload notifications.
+// When a secret is given, the body must be signed with HMAC-SHA256 like the reports are.
+func (u *uploadSecurer) registerRoutes(mux *http.ServeMux, secret string) {
+	mux.HandleFunc("POST /v1/uploads", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid body: " + err.Error()})
+			return
+		}
+
+		if secret != "" && !validSignature(secret, body, r.Header.Get("X-Signature-256")) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid signature"})
+			return
+		}
+
+		ids, err := parseUploadEvent(body)
+		if errors.Is(err, errTooManyUploads) {
+			// Sending the notification again wouldn't help, it never fits in the queue.
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if !u.enqueue(ids...) {
+			w.Header().Set("Retry-After", "5")
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "too many uploads waiting to be secured"})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]int{"queued": len(ids)})
+	})
+}
+
+// validSignature checks the signature of the body, given as "sha256=<hex>".
+func validSignature(secret string, body []byte, signature string) bool {
+	want := signatureHeader(secret, body).Get("X-Signature-256")
+	return hmac.Equal([]byte(signature), []byte(want))
+}
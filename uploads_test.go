@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+	"github.com/alesr/securecloudflareimage/cloudflareclient/cloudflaretest"
+	"github.com/alesr/securecloudflareimage/policy"
+)
+
+func TestUploadsNotificationSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		images int
+		want   int
+	}{
+		{"within the limit", maxUploadEventImages, http.StatusAccepted},
+		{"over the limit", maxUploadEventImages + 1, http.StatusRequestEntityTooLarge},
+		{"never fitting in the queue", uploadQueueSize + 1, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := newUploadSecurer(nil)
+			mux := http.NewServeMux()
+			u.registerRoutes(mux, "")
+
+			ev := uploadEvent{ImageIDs: make([]string, tt.images)}
+			for i := range ev.ImageIDs {
+				ev.ImageIDs[i] = fmt.Sprintf("image-%05d", i)
+			}
+			body, err := json.Marshal(ev)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/uploads", bytes.NewReader(body)))
+
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+
+			queued := len(u.ids)
+			if tt.want != http.StatusAccepted && queued != 0 {
+				t.Errorf("%d images queued, want none", queued)
+			}
+			if tt.want == http.StatusAccepted && queued != tt.images {
+				t.Errorf("%d images queued, want %d", queued, tt.images)
+			}
+		})
+	}
+}
+
+func TestUploadsMaxChanges(t *testing.T) {
+	srv := cloudflaretest.NewServer("account", "token",
+		cloudflareclient.Image{ID: "a"}, cloudflareclient.Image{ID: "b"}, cloudflareclient.Image{ID: "c"},
+	)
+	defer srv.Close()
+
+	s := &securer{
+		cli:  srv.Client(),
+		opts: &options{accountID: "account", concurrency: 2, maxChanges: 2},
+		sel:  &selection{policy: policy.Default()},
+	}
+	u := newUploadSecurer(s)
+
+	// The limit is shared by the batches, not given to each of them.
+	u.secure(context.Background(), []string{"a"})
+	u.secure(context.Background(), []string{"b", "c"})
+
+	var secured int
+	for _, img := range srv.Images() {
+		if img.RequireSignedURLs {
+			secured++
+		}
+	}
+	if secured != 2 {
+		t.Errorf("%d images secured, want 2", secured)
+	}
+
+	// The image held back is left to the next pass rather than looked at again.
+	if len(u.attempts) != 0 {
+		t.Errorf("images waiting to be looked at again: %v", u.attempts)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// apiUsage counts the requests made to the API and keeps the last rate-limit headers
+// returned, for operators to tell how much of the quota of the token a run consumes.
+// Its methods are no-ops on a nil apiUsage.
+type apiUsage struct {
+	mu          sync.Mutex
+	requests    int
+	rateLimited int
+	// rateLimit and rateLimitPolicy are the last Ratelimit and Ratelimit-Policy headers seen.
+	rateLimit       string
+	rateLimitPolicy string
+}
+
+// usageSnapshot is the usage at a point in time.
+type usageSnapshot struct {
+	requests    int
+	rateLimited int
+}
+
+// transport wraps an http transport to count the requests it makes.
+func (u *apiUsage) transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		u.requests++
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			u.rateLimited++
+		}
+
+		if v := resp.Header.Get("Ratelimit"); v != "" {
+			u.rateLimit = v
+		}
+		if v := resp.Header.Get("Ratelimit-Policy"); v != "" {
+			u.rateLimitPolicy = v
+		}
+		return resp, nil
+	})
+}
+
+func (u *apiUsage) snapshot() usageSnapshot {
+	if u == nil {
+		return usageSnapshot{}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return usageSnapshot{requests: u.requests, rateLimited: u.rateLimited}
+}
+
+// record sets the usage since the start of the run on its summary.
+func (u *apiUsage) record(sum *runSummary) {
+	if u == nil {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	sum.APIRequests = u.requests - sum.usageStart.requests
+	sum.APIRateLimited = u.rateLimited - sum.usageStart.rateLimited
+	sum.RateLimit = u.rateLimit
+	sum.RateLimitPolicy = u.rateLimitPolicy
+}
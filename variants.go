@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+// variantsCmd manages the variants of the images of the account, the delivery configuration
+// along with the protection of the images.
+func variantsCmd(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return listVariantsCmd(ctx, args[1:])
+		case "create", "update":
+			return saveVariantCmd(ctx, args[0], args[1:])
+		}
+	}
+	return errors.New("usage: variants list|create|update [flags]")
+}
+
+// listVariantsCmd lists the variants of the images of the account, or the delivery URLs of the
+// variants of an image, for the signed URLs to be of the variants the applications request.
+func listVariantsCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("variants list", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	imageIDPtr := fs.String("image-id", "", "image to list the delivery urls of the variants of, rather than the variants of the account")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	cli, err := opts.connect(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if *imageIDPtr != "" {
+		img, err := cli.GetImage(ctx, *imageIDPtr)
+		if err != nil {
+			return fmt.Errorf("failed to get image: %s", err)
+		}
+
+		names := variantNames(img)
+		for i, u := range img.Variants {
+			fmt.Fprintf(tw, "%s\t%s\n", names[i], u)
+		}
+		return tw.Flush()
+	}
+
+	variants, err := cli.ListVariants(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list variants: %s", err)
+	}
+
+	fmt.Fprintln(tw, "VARIANT\tFIT\tWIDTH\tHEIGHT\tMETADATA\tALWAYS PUBLIC")
+	for _, v := range variants {
+		public := "no"
+		if v.NeverRequireSignedURLs {
+			public = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\n", v.ID, v.Options.Fit, v.Options.Width, v.Options.Height, v.Options.Metadata, public)
+	}
+	return tw.Flush()
+}
+
+// saveVariantCmd creates a variant, or updates the options of a variant given with the flags,
+// keeping the others.
+func saveVariantCmd(ctx context.Context, action string, args []string) error {
+	fs := flag.NewFlagSet("variants "+action, flag.ExitOnError)
+
+	var (
+		opts    options
+		variant cloudflareclient.Variant
+	)
+	opts.registerClientFlags(fs)
+	fs.StringVar(&variant.ID, "id", "", "id of the variant, the name of the variant in the delivery urls")
+	fs.StringVar(&variant.Options.Fit, "fit", "scale-down", "how the images are resized to the width and the height, scale-down, contain, cover, crop or pad")
+	fs.IntVar(&variant.Options.Width, "width", 0, "maximum width of the images, in pixels")
+	fs.IntVar(&variant.Options.Height, "height", 0, "maximum height of the images, in pixels")
+	fs.StringVar(&variant.Options.Metadata, "metadata", "none", "exif metadata of the images kept, keep, copyright or none")
+	fs.BoolVar(&variant.NeverRequireSignedURLs, "never-require-signed-urls", false, "serve the variant publicly, even for the images requiring signed urls")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if variant.ID == "" {
+		fs.Usage()
+		return errors.New("-id is required")
+	}
+
+	if action == "create" && (variant.Options.Width <= 0 || variant.Options.Height <= 0) {
+		fs.Usage()
+		return errors.New("-width and -height are required")
+	}
+
+	if err := opts.validateClient(ctx); err != nil {
+		fs.Usage()
+		return err
+	}
+
+	cli, err := opts.connect(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	save := cli.CreateVariant
+	if action == "update" {
+		if variant, err = mergeVariant(ctx, cli, fs, variant); err != nil {
+			return err
+		}
+		save = cli.UpdateVariant
+	}
+
+	saved, err := save(ctx, variant)
+	if err != nil {
+		return fmt.Errorf("failed to %s variant: %s", action, err)
+	}
+
+	slog.Info("variant "+action+"d", "variant", saved.ID, "fit", saved.Options.Fit, "width", saved.Options.Width, "height", saved.Options.Height,
+		"metadata", saved.Options.Metadata, "never_require_signed_urls", saved.NeverRequireSignedURLs)
+	return nil
+}
+
+// mergeVariant returns the current variant with the options given with the flags, the update
+// replacing them as a whole.
+func mergeVariant(ctx context.Context, cli cloudflareclient.CloudflareImagesAPI, fs *flag.FlagSet, update cloudflareclient.Variant) (cloudflareclient.Variant, error) {
+	variants, err := cli.ListVariants(ctx)
+	if err != nil {
+		return update, fmt.Errorf("failed to list variants: %s", err)
+	}
+
+	i := slices.IndexFunc(variants, func(v cloudflareclient.Variant) bool { return v.ID == update.ID })
+	if i < 0 {
+		return update, fmt.Errorf("no variant named '%s'", update.ID)
+	}
+
+	v := variants[i]
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "fit":
+			v.Options.Fit = update.Options.Fit
+		case "width":
+			v.Options.Width = update.Options.Width
+		case "height":
+			v.Options.Height = update.Options.Height
+		case "metadata":
+			v.Options.Metadata = update.Options.Metadata
+		case "never-require-signed-urls":
+			v.NeverRequireSignedURLs = update.NeverRequireSignedURLs
+		}
+	})
+	return v, nil
+}
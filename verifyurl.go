@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/alesr/securecloudflareimage/cloudflareclient"
+)
+
+const signingKeyEnv = "SECURECLOUDFLAREIMG_SIGNING_KEY"
+
+// verifyURLCmd checks the signature of a signed delivery URL and tells when it expires, for
+// the 403s of the images once secured. Without -signing-key the URL is checked against
+// every signing key of the account.
+func verifyURLCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("verify-url", flag.ExitOnError)
+
+	var opts options
+	opts.registerClientFlags(fs)
+	keyPtr := fs.String("signing-key", os.Getenv(signingKeyEnv), "signing key to check the url against, defaults to the "+signingKeyEnv+" environment variable, or else every signing key of the account")
+	if err := opts.parse(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("usage: verify-url [flags] <signed url>")
+	}
+	rawURL := fs.Arg(0)
+
+	if hash, id, err := parseDeliveryURL(rawURL); err == nil {
+		fmt.Printf("image %s of account hash %s\n", id, hash)
+	}
+
+	keys, err := opts.signingKeys(ctx, fs, *keyPtr)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, k := range keys {
+		signer := "-signing-key"
+		if k.Name != "" {
+			signer = fmt.Sprintf("key '%s'", k.Name)
+		}
+
+		expiresAt, err := cloudflareclient.VerifyURL(rawURL, k.Value, now)
+		switch {
+		case errors.Is(err, cloudflareclient.ErrInvalidSignature):
+			continue
+		case errors.Is(err, cloudflareclient.ErrSignatureExpired):
+			fmt.Printf("signature valid, signed with %s, expired at %s (%s ago)\n", signer, expiresAt.Format(time.RFC3339), now.Sub(expiresAt).Round(time.Second))
+			return err
+		case err != nil:
+			return err
+		case expiresAt.IsZero():
+			fmt.Printf("signature valid, signed with %s, never expires\n", signer)
+		default:
+			fmt.Printf("signature valid, signed with %s, expires at %s (in %s)\n", signer, expiresAt.Format(time.RFC3339), expiresAt.Sub(now).Round(time.Second))
+		}
+		return nil
+	}
+
+	fmt.Printf("signature invalid, it matches none of the %d signing keys\n", len(keys))
+	return cloudflareclient.ErrInvalidSignature
+}
+
+// signingKeys returns the signing key given with -signing-key, or else the signing keys of the
+// account, listed with the credentials of the options.
+func (o *options) signingKeys(ctx context.Context, fs *flag.FlagSet, key string) ([]cloudflareclient.SigningKey, error) {
+	if key != "" {
+		return []cloudflareclient.SigningKey{{Value: key}}, nil
+	}
+
+	cli, err := o.signingClient(ctx, fs, "-signing-key, or the credentials to list the signing keys of the account, are required")
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := cli.SigningKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %s", err)
+	}
+	return keys, nil
+}
+
+// signingClient connects to the API for what the URLs are signed or checked with, failing
+// with why the credentials are needed when they aren't valid.
+func (o *options) signingClient(ctx context.Context, fs *flag.FlagSet, why string) (*cloudflareclient.Client, error) {
+	if err := o.validateClient(ctx); err != nil {
+		fs.Usage()
+		return nil, fmt.Errorf("%s: %w", why, err)
+	}
+	return o.connect(ctx, false)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is the version of the tool, set at build time with
+// -ldflags "-X main.version=v1.2.3", or taken from the build info otherwise.
+var version = ""
+
+// toolVersion returns the version of the tool: the one set at build time, the version
+// of the module when installed with go install, or the commit it was built from.
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value
+		}
+	}
+
+	if revision == "" {
+		return "devel"
+	}
+
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return "devel-" + revision
+}
+
+// userAgent identifies the requests of the tool to the api, in its logs and to support.
+func userAgent() string {
+	return "securecloudflareimg/" + toolVersion()
+}
+
+// versionCmd prints the version of the tool.
+func versionCmd(_ context.Context, _ []string) error {
+	fmt.Printf("securecloudflareimg %s %s %s/%s\n", toolVersion(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
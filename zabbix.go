@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// zabbixAccount is an account discovered by the low-level discovery of Zabbix, its id given
+// as a macro for the prototypes and its count read by the dependent items.
+type zabbixAccount struct {
+	AccountID   string `json:"{#ACCOUNT_ID}"`
+	Unprotected int    `json:"unprotected"`
+}
+
+// writeZabbixDiscovery prints the accounts and their counts as the json array of a low-level
+// discovery rule, the item prototypes reading the counts with the preprocessing of the rule's
+// master item, e.g. $[?(@.['{#ACCOUNT_ID}'] == '{#ACCOUNT_ID}')].unprotected.first().
+func writeZabbixDiscovery(w io.Writer, counts []accountCount) error {
+	accounts := make([]zabbixAccount, 0, len(counts))
+	for _, c := range counts {
+		accounts = append(accounts, zabbixAccount{AccountID: c.accountID, Unprotected: c.unprotected})
+	}
+
+	data, err := json.Marshal(accounts)
+	if err != nil {
+		return fmt.Errorf("could not encode zabbix discovery: %s", err)
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}